@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// parseBasicAuth parses a -basic-auth value of the form "user:pass". When
+// only the user name is given, prompt is asked for the password so that it
+// does not end up in the shell history.
+func parseBasicAuth(value string, prompt func(user string) (string, error)) (*url.Userinfo, error) {
+	user, pass, hasPass := strings.Cut(value, ":")
+	if user == "" {
+		return nil, fmt.Errorf("invalid -basic-auth %q: expected user:pass or user", value)
+	}
+	if !hasPass {
+		var err error
+		if pass, err = prompt(user); err != nil {
+			return nil, fmt.Errorf("-basic-auth: %w", err)
+		}
+	}
+	return url.UserPassword(user, pass), nil
+}
+
+// promptPassword reads a password from the terminal without echoing it.
+func promptPassword(user string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no password given and stdin is not a terminal to prompt on")
+	}
+	fmt.Fprintf(os.Stderr, "Password for %s: ", user)
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(pass), err
+}
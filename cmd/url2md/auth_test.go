@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBasicAuth(t *testing.T) {
+	noPrompt := func(string) (string, error) {
+		t.Fatalf("prompt called for a value with a password")
+		return "", nil
+	}
+	info, err := parseBasicAuth("alice:s3cr:et", noPrompt)
+	if err != nil {
+		t.Fatalf("parseBasicAuth returned error: %v", err)
+	}
+	if pass, _ := info.Password(); info.Username() != "alice" || pass != "s3cr:et" {
+		t.Fatalf("parseBasicAuth = %s:%s, expected alice:s3cr:et", info.Username(), pass)
+	}
+
+	info, err = parseBasicAuth("bob", func(user string) (string, error) { return "typed-" + user, nil })
+	if err != nil {
+		t.Fatalf("parseBasicAuth returned error: %v", err)
+	}
+	if pass, _ := info.Password(); pass != "typed-bob" {
+		t.Fatalf("password = %q, expected the prompted one", pass)
+	}
+
+	if _, err := parseBasicAuth("bob", func(string) (string, error) { return "", errors.New("no tty") }); err == nil {
+		t.Fatalf("parseBasicAuth returned no error when the prompt failed")
+	}
+	for _, value := range []string{"", ":pass"} {
+		if _, err := parseBasicAuth(value, noPrompt); err == nil {
+			t.Fatalf("parseBasicAuth(%q) returned no error", value)
+		}
+	}
+}
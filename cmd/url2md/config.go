@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/url2md/config.toml, falling back
+// to ~/.config/url2md/config.toml.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "url2md", "config.toml")
+}
+
+// configPathFromArgs looks for -config in args before the flags are parsed,
+// so the file can provide defaults that the remaining flags override.
+func configPathFromArgs(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// loadConfig sets flags from the TOML file at path. Keys are flag
+// names (underscores may be used in place of dashes) and arrays set
+// repeatable flags once per element. A missing file is ignored unless
+// required is set.
+func loadConfig(flags *flag.FlagSet, path string, required bool) error {
+	var values map[string]interface{}
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if flags.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		items, isList := values[key].([]interface{})
+		if !isList {
+			items = []interface{}{values[key]}
+		}
+		for _, item := range items {
+			if _, isTable := item.(map[string]interface{}); isTable {
+				return fmt.Errorf("%s: setting %q must be a string, number or boolean", path, key)
+			}
+			if err := flags.Set(name, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("%s: invalid value for %q: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigSetsDefaultsBeforeFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := `timeout = "10s"
+user_agent = "config-agent"
+no-proxy = true
+concurrency = 8
+exclude = ["nav", ".ads"]
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("url2md", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", 45*time.Second, "")
+	userAgent := flags.String("user-agent", "", "")
+	noProxy := flags.Bool("no-proxy", false, "")
+	concurrency := flags.Int("concurrency", 4, "")
+	var exclude []string
+	flags.Var((*stringsFlag)(&exclude), "exclude", "")
+	flags.String("config", "", "")
+
+	if err := loadConfig(flags, path, true); err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if err := flags.Parse([]string{"-config", path, "-timeout", "2m", "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if *timeout != 2*time.Minute {
+		t.Fatalf("timeout = %s, expected the command line value 2m", *timeout)
+	}
+	if *userAgent != "config-agent" || !*noProxy || *concurrency != 8 {
+		t.Fatalf("config values not applied: user-agent %q, no-proxy %v, concurrency %d", *userAgent, *noProxy, *concurrency)
+	}
+	if !reflect.DeepEqual(exclude, []string{"nav", ".ads"}) {
+		t.Fatalf("exclude = %q, expected both config entries", exclude)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	flags := flag.NewFlagSet("url2md", flag.ContinueOnError)
+	flags.Duration("timeout", time.Second, "")
+
+	if err := loadConfig(flags, filepath.Join(dir, "missing.toml"), false); err != nil {
+		t.Fatalf("loadConfig on a missing optional file returned error: %v", err)
+	}
+	if err := loadConfig(flags, filepath.Join(dir, "missing.toml"), true); err == nil {
+		t.Fatalf("loadConfig on a missing -config file returned no error")
+	}
+
+	for _, config := range []string{`unknown = 1`, `timeout = "soon"`, `[timeout]`, `timeout = `} {
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte(config), 0644)
+		if err := loadConfig(flags, path, false); err == nil {
+			t.Fatalf("loadConfig(%q) returned no error", config)
+		}
+	}
+}
+
+func TestConfigPathFromArgs(t *testing.T) {
+	cases := []struct {
+		args []string
+		path string
+		ok   bool
+	}{
+		{[]string{"-v", "-config", "a.toml", "https://example.com"}, "a.toml", true},
+		{[]string{"--config=b.toml"}, "b.toml", true},
+		{[]string{"-v", "https://example.com"}, "", false},
+		{[]string{"--", "-config", "c.toml"}, "", false},
+	}
+	for _, c := range cases {
+		path, ok := configPathFromArgs(c.args)
+		if path != c.path || ok != c.ok {
+			t.Fatalf("configPathFromArgs(%q) = %q, %v; expected %q, %v", c.args, path, ok, c.path, c.ok)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// loadCookies reads the Netscape cookies.txt file given with -cookies.
+func loadCookies(filename string) ([]*http.Cookie, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("-cookies: %w", err)
+	}
+	defer f.Close()
+	cookies, err := url2md.ParseCookieFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("-cookies: %s: %w", filename, err)
+	}
+	return cookies, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCookies(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "cookies.txt")
+	os.WriteFile(good, []byte("# Netscape HTTP Cookie File\n.example.com\tTRUE\t/\tTRUE\t0\tsession\tabc\n"), 0600)
+	cookies, err := loadCookies(good)
+	if err != nil {
+		t.Fatalf("loadCookies returned error: %v", err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Fatalf("loadCookies = %+v, expected the session cookie", cookies)
+	}
+
+	bad := filepath.Join(dir, "bad.txt")
+	os.WriteFile(bad, []byte("not a cookie file\n"), 0600)
+	for _, name := range []string{bad, filepath.Join(dir, "missing.txt")} {
+		if _, err := loadCookies(name); err == nil || !strings.HasPrefix(err.Error(), "-cookies: ") {
+			t.Fatalf("loadCookies(%s) error = %v, expected a -cookies error", name, err)
+		}
+	}
+}
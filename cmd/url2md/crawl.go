@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// crawl converts start and then, breadth first, the same-host pages it links
+// to, up to depth links away from start and maxPages pages in total (zero
+// for no limit). Each level is converted with processAll. It returns the
+// number of pages attempted and the number that failed.
+func crawl(ctx context.Context, start *url.URL, depth, maxPages int, opts *options) (int, int) {
+	hosts := map[string]bool{start.Host: true}
+	seen := map[string]bool{start.String(): true}
+	level := []string{start.String()}
+	pages, failed := 0, 0
+
+	for d := 0; len(level) > 0 && ctx.Err() == nil; d++ {
+		var mu sync.Mutex
+		var next []string
+		failed += processAll(ctx, level, opts, func(res url2md.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			// Follow a redirect on the start page, e.g. to the www. host.
+			if d == 0 {
+				hosts[res.FinalURL.Host] = true
+			}
+			seen[res.FinalURL.String()] = true
+			if d == depth {
+				return
+			}
+			for _, link := range res.Links {
+				u, err := url.Parse(link)
+				if err != nil || !hosts[u.Host] || seen[link] {
+					continue
+				}
+				seen[link] = true
+				next = append(next, link)
+			}
+		})
+		pages += len(level)
+
+		if maxPages > 0 && pages+len(next) > maxPages {
+			opts.logger.Info("Stopping crawl at the page limit", "pages", maxPages)
+			next = next[:maxPages-pages]
+		}
+		if len(next) > 0 {
+			opts.logger.Info("Crawling", "pages", len(next), "depth", d+1)
+		}
+		level = next
+	}
+	return pages, failed
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestCrawlFollowsSameHostLinksToDepth(t *testing.T) {
+	links := map[string][]string{
+		"/":  {"/a", "/b", "https://other.example.org/x", "/#top"},
+		"/a": {"/", "/c"},
+		"/b": {"/a"},
+		"/c": {"/d"},
+	}
+	var mu sync.Mutex
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Sec-Fetch-Mode") == "navigate" { // skip the warm-up request
+			mu.Lock()
+			fetched = append(fetched, r.URL.Path)
+			mu.Unlock()
+		}
+		var body strings.Builder
+		for _, target := range targets {
+			fmt.Fprintf(&body, `<a href="%s">link</a> `, target)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<h1>%s</h1><p>%s</p>", r.URL.Path, body.String())
+	}))
+	defer srv.Close()
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	start, _ := url.Parse(srv.URL + "/")
+	tests := []struct {
+		depth, maxPages int
+		want            []string
+	}{
+		{0, 0, []string{"/"}},
+		{1, 0, []string{"/", "/a", "/b"}},
+		{2, 0, []string{"/", "/a", "/b", "/c"}},
+		{5, 3, []string{"/", "/a", "/b"}},
+	}
+	for _, tt := range tests {
+		fetched = nil
+		opts := &options{ext: ".md", concurrency: 2, timeout: 5 * time.Second, logger: testLogger(t)}
+		opts.convert = url2md.Options{MaxRedirects: 10, NoProxy: true}
+
+		pages, failed := crawl(context.Background(), start, tt.depth, tt.maxPages, opts)
+		sort.Strings(fetched)
+		if fmt.Sprint(fetched) != fmt.Sprint(tt.want) {
+			t.Fatalf("depth %d, max %d: fetched %q, expected %q", tt.depth, tt.maxPages, fetched, tt.want)
+		}
+		if pages != len(tt.want) || failed != 0 {
+			t.Fatalf("depth %d: crawl = %d pages, %d failed; expected %d, 0", tt.depth, pages, failed, len(tt.want))
+		}
+	}
+
+	if _, err := os.Stat(outputFilename(start, ".md")); err != nil {
+		t.Fatalf("start page was not written: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// contentIndex remembers which file each converted Markdown was written to,
+// so that -crawl writes pages reached under several URLs (a trailing slash,
+// a query string) only once. It is safe for concurrent use by the
+// processAll workers.
+type contentIndex struct {
+	mu    sync.Mutex
+	files map[[sha256.Size]byte]string
+}
+
+func newContentIndex() *contentIndex {
+	return &contentIndex{files: map[[sha256.Size]byte]string{}}
+}
+
+// claim records filename as the file holding markdown and returns "", or
+// returns the file recorded by an earlier page with the same Markdown.
+func (c *contentIndex) claim(markdown, filename string) string {
+	sum := sha256.Sum256([]byte(markdown))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if first, ok := c.files[sum]; ok {
+		return first
+	}
+	c.files[sum] = filename
+	return ""
+}
+
+// release forgets markdown after writing its file failed, so that the next
+// page with the same content is written instead.
+func (c *contentIndex) release(markdown string) {
+	sum := sha256.Sum256([]byte(markdown))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, sum)
+}
+
+// writeSymlink makes filename a symbolic link to target, relative to the
+// directory of filename when possible so that the output tree can be moved.
+func writeSymlink(filename, target string, noClobber bool) error {
+	dir := filepath.Dir(filename)
+	if dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	link, err := filepath.Rel(dir, target)
+	if err != nil {
+		if link, err = filepath.Abs(target); err != nil {
+			return err
+		}
+	}
+	if !noClobber {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Symlink(link, filename)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestContentIndexClaim(t *testing.T) {
+	index := newContentIndex()
+	if first := index.claim("# Page\n", "a.md"); first != "" {
+		t.Fatalf("claim = %q, expected \"\" for new content", first)
+	}
+	if first := index.claim("# Page\n", "b.md"); first != "a.md" {
+		t.Fatalf("claim = %q, expected %q", first, "a.md")
+	}
+	if first := index.claim("# Other\n", "c.md"); first != "" {
+		t.Fatalf("claim = %q, expected \"\" for different content", first)
+	}
+	index.release("# Page\n")
+	if first := index.claim("# Page\n", "d.md"); first != "" {
+		t.Fatalf("claim after release = %q, expected \"\"", first)
+	}
+}
+
+func TestWriteResultDedup(t *testing.T) {
+	fetchedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, symlink := range []bool{false, true} {
+		dir := t.TempDir()
+		opts := &options{outTree: dir, ext: ".md", logger: testLogger(t), dedup: newContentIndex(), dedupSymlink: symlink}
+		var files []string
+		for _, raw := range []string{"https://example.com/docs", "https://example.com/docs/?ref=nav", "https://example.com/other"} {
+			u, _ := url.Parse(raw)
+			markdown := "# Docs\n"
+			if u.Path == "/other" {
+				markdown = "# Other\n"
+			}
+			if err := writeResult(url2md.Result{FinalURL: u, Markdown: markdown, FetchedAt: fetchedAt}, raw, opts); err != nil {
+				t.Fatalf("writeResult returned error: %v", err)
+			}
+			files = append(files, treeFilename(dir, u, fetchedAt, ".md"))
+		}
+
+		first, duplicate := files[0], files[1]
+		if _, err := os.Stat(first); err != nil {
+			t.Fatalf("first page not written: %v", err)
+		}
+		if _, err := os.Stat(files[2]); err != nil {
+			t.Fatalf("different page not written: %v", err)
+		}
+		link, err := os.Readlink(duplicate)
+		switch {
+		case !symlink && !os.IsNotExist(err):
+			t.Fatalf("duplicate page written (err %v), expected it to be skipped", err)
+		case symlink && err != nil:
+			t.Fatalf("duplicate page is not a link: %v", err)
+		case symlink && link != filepath.Join("..", "docs.md"):
+			t.Fatalf("link = %q, expected %q", link, filepath.Join("..", "docs.md"))
+		}
+		if symlink {
+			if data, err := os.ReadFile(duplicate); err != nil || string(data) != "# Docs\n" {
+				t.Fatalf("reading through the link = %q, %v", data, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// Exit codes reported by main.
+const (
+	exitFailure    = 1 // unclassified error, or every URL of a batch failed
+	exitUsage      = 2 // invalid arguments, options or input
+	exitNetwork    = 3 // DNS, connection or timeout failure
+	exitHTTPStatus = 4 // the origin answered with an error status
+	exitProxy      = 5 // the origin blocked the request and the proxy failed
+	exitConversion = 6 // the page could not be converted
+	exitWrite      = 7 // the result could not be written
+	exitDisallowed = 8 // robots.txt forbids the URL (-respect-robots)
+	exitTooLarge   = 9 // a response exceeded -max-size
+)
+
+// writeError marks a failure to write the converted output.
+type writeError struct{ err error }
+
+func (e *writeError) Error() string { return e.err.Error() }
+
+func (e *writeError) Unwrap() error { return e.err }
+
+// exitCode maps the error of a single-URL run to its exit code.
+func exitCode(err error) int {
+	var werr *writeError
+	switch {
+	case errors.Is(err, url2md.ErrDisallowed):
+		return exitDisallowed
+	case errors.As(err, &werr):
+		return exitWrite
+	}
+	switch url2md.Kind(err) {
+	case url2md.KindInvalidInput:
+		return exitUsage
+	case url2md.KindNetwork:
+		return exitNetwork
+	case url2md.KindHTTPStatus:
+		return exitHTTPStatus
+	case url2md.KindProxy:
+		return exitProxy
+	case url2md.KindConversion:
+		return exitConversion
+	case url2md.KindTooLarge:
+		return exitTooLarge
+	}
+	return exitFailure
+}
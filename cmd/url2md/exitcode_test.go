@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestExitCode(t *testing.T) {
+	classified := func(kind url2md.ErrorKind) error {
+		return fmt.Errorf("failed to download x: %w", &url2md.Error{Kind: kind, Err: errors.New("boom")})
+	}
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("unclassified"), exitFailure},
+		{classified(url2md.KindInvalidInput), exitUsage},
+		{classified(url2md.KindNetwork), exitNetwork},
+		{classified(url2md.KindHTTPStatus), exitHTTPStatus},
+		{classified(url2md.KindProxy), exitProxy},
+		{classified(url2md.KindConversion), exitConversion},
+		{classified(url2md.KindTooLarge), exitTooLarge},
+		{&writeError{errors.New("disk full")}, exitWrite},
+		{fmt.Errorf("skipping x: %w", url2md.ErrDisallowed), exitDisallowed},
+	}
+	for _, c := range cases {
+		if got := exitCode(c.err); got != c.want {
+			t.Fatalf("exitCode(%v) = %d, expected %d", c.err, got, c.want)
+		}
+	}
+}
+
+func TestReportErrorJSON(t *testing.T) {
+	err := fmt.Errorf("failed to download https://example.com/x: %w", &url2md.Error{Kind: url2md.KindNetwork, Err: errors.New("connection refused")})
+	out := captureStdout(t, func() {
+		reportError(err, "https://example.com/x", exitCode(err), &options{json: true})
+	})
+
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("output %q is not a JSON object: %v", out, err)
+	}
+	want := map[string]any{
+		"error": "failed to download https://example.com/x: connection refused",
+		"code":  float64(exitNetwork),
+		"url":   "https://example.com/x",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("error object = %v, expected %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("%s = %v, expected %v", key, got[key], value)
+		}
+	}
+	if out[len(out)-1] != '\n' {
+		t.Fatalf("output %q does not end with a newline", out)
+	}
+}
+
+func TestReportFlagErrorJSON(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-json", "-c", "0", "https://example.com"}, `{"error":"-c must be at least 1","code":2,"url":""}` + "\n"},
+		{[]string{"-json", "-nope", "https://example.com"}, `{"error":"flag provided but not defined: -nope","code":2,"url":""}` + "\n"},
+	} {
+		var output strings.Builder
+		fs := flag.NewFlagSet("url2md", flag.ContinueOnError)
+		fs.SetOutput(&output)
+		_, err := parseFlags(fs, tt.args)
+		if err == nil {
+			t.Fatalf("parseFlags(%q) returned no error", tt.args)
+		}
+		got := captureStdout(t, func() { reportFlagError(err, fs, []byte(output.String())) })
+		if string(got) != tt.want {
+			t.Fatalf("reportFlagError for %q printed %q, expected %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// parseFlags registers the command-line flags on fs, applies the config
+// file and parses args, the arguments after the program name. It checks
+// the values and how they combine, opens the files they name and returns
+// the settings of the run. Errors are meant to be printed as they are,
+// before exiting with exitUsage. With -version or -list-formats the other
+// flags are not checked.
+func parseFlags(fs *flag.FlagSet, args []string) (*options, error) {
+	opts := &options{}
+	var verbose bool
+	var logLevel string
+	var logFormat string
+	var headers headerFlags
+	var images string
+	var basicAuth string
+	var configPath string
+	var base string
+	var caCert string
+	var cookiesFile string
+	var userAgentFile string
+	var cookieJar string
+	var format string
+	var stripComments bool
+	var removeEmptyLinks bool
+	var dropHidden bool
+	var metadata string
+	var cacheDir string
+	var modifiedSince string
+	var outputTemplate string
+	var headingStyle string
+	var linkStyle string
+	var definitionListStyle string
+	var cleanParams []string
+	var warmup bool
+	fs.BoolVar(&verbose, "v", false, "enable verbose logging (same as -log-level debug)")
+	fs.StringVar(&logLevel, "log-level", "warn", "lowest level of the log messages printed on stderr: debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", "text", "format of the log messages: text or json (one object per line)")
+	fs.BoolVar(&opts.quiet, "quiet", false, "print nothing on stderr except errors")
+	fs.StringVar(&opts.output, "o", "", "output filename, or - for stdout (default: auto-generated from URL)")
+	fs.StringVar(&opts.output, "output", "", "alias for -o")
+	fs.StringVar(&opts.inputFile, "i", "", "read newline-delimited URLs from file")
+	fs.IntVar(&opts.concurrency, "c", 4, "number of URLs to process in parallel")
+	fs.IntVar(&opts.concurrency, "concurrency", 4, "alias for -c")
+	fs.DurationVar(&opts.timeout, "timeout", 45*time.Second, "timeout for each URL, e.g. 10s or 2m")
+	fs.DurationVar(&opts.convert.ConnectTimeout, "connect-timeout", 0, "timeout for resolving the host and opening each connection, e.g. 5s (default 30s)")
+	fs.StringVar(&opts.convert.UserAgent, "user-agent", "", "User-Agent header to send (default: $URL2MD_USER_AGENT or a desktop Chrome UA)")
+	fs.StringVar(&userAgentFile, "user-agent-file", "", "rotate through the User-Agent strings of `file`, one per line, giving each page the next one")
+	fs.BoolVar(&opts.frontMatter, "front-matter", false, "prepend YAML front matter with the source URL, title and fetch time")
+	fs.StringVar(&metadata, "metadata", "", "also write the Open Graph title, description, image, publication time and author: front-matter or json (a .meta.json file next to each page)")
+	fs.BoolVar(&opts.fragment, "fragment", false, "write a bare fragment to embed in another document: no front matter, no table of contents, no surrounding blank lines and no final newline")
+	fs.IntVar(&opts.convert.MaxRedirects, "max-redirects", 10, "maximum number of HTTP redirects to follow")
+	fs.BoolVar(&opts.convert.NoProxy, "no-proxy", false, "never fall back to the reader proxy when the origin blocks the request")
+	fs.Var(&headers, "H", "extra request header \"Name: Value\" (repeatable); not sent to the proxy")
+	fs.BoolVar(&opts.convert.Readability, "readability", false, "convert only the main article content, dropping navigation and other boilerplate")
+	fs.BoolVar(&opts.stdout, "stdout", false, "write the markdown to stdout instead of a file")
+	fs.StringVar(&images, "images", string(url2md.ImagesKeep), "how to handle images: keep, strip or download (into an assets/ folder next to the output)")
+	fs.BoolVar(&opts.convert.ImagesAll, "images-all", false, "with -images download, also save SVG images and inline data: URIs")
+	fs.BoolVar(&opts.json, "json", false, "print a JSON object per URL to stdout instead of writing a markdown file (unless -o is given)")
+	fs.BoolVar(&opts.convert.RespectRobots, "respect-robots", false, "skip URLs disallowed by the host's robots.txt (exit code 8)")
+	fs.IntVar(&opts.convert.Retries, "retries", 2, "retries on connection errors and 429/503 responses, with exponential backoff")
+	fs.StringVar(&opts.convert.Select, "select", "", "CSS selector; convert only the matching elements")
+	fs.BoolVar(&opts.interactive, "interactive", false, "list the elements with the most text and ask on the terminal which one to convert")
+	fs.StringVar(&opts.convert.Split, "split-selector", "", "CSS selector; write each matching element to a file of its own, named after its id or first heading")
+	fs.Var((*stringsFlag)(&opts.convert.Exclude), "exclude", "CSS selector of elements to drop before conversion (repeatable)")
+	fs.BoolVar(&opts.convert.TrimNav, "trim-nav", false, "remove navigation, headers, footers, sidebars and menus before conversion, keeping the main content (lighter than -readability)")
+	fs.Var((*stringsFlag)(&opts.convert.NavSelectors), "nav-selector", "CSS selector for -trim-nav to remove instead of the default ones (repeatable, implies -trim-nav)")
+	fs.IntVar(&opts.convert.MinLength, "min-length", 50, "warn when a page converts to fewer characters of Markdown than this (0 disables the check)")
+	fs.BoolVar(&opts.failOnEmpty, "fail-on-empty", false, "treat pages shorter than -min-length as failed instead of writing them")
+	fs.BoolVar(&opts.convert.ProxyOnEmpty, "proxy-on-empty", false, "retry through the reader proxy when a page converts to fewer characters than -min-length")
+	fs.StringVar(&opts.convert.AcceptLanguage, "lang", url2md.DefaultAcceptLanguage, "Accept-Language sent with every request, e.g. it-IT,it;q=0.9")
+	fs.StringVar(&opts.convert.Accept, "accept", url2md.DefaultAccept, "Accept header of the warm-up and page requests, e.g. text/markdown to let servers that support it send Markdown directly")
+	fs.BoolVar(&opts.convert.HeadCheck, "head-check", false, "send a HEAD request first and skip the download when the page is missing or not HTML (see -force)")
+	fs.BoolVar(&warmup, "warmup", true, "request the host root before each page to collect cookies (-warmup=false skips it)")
+	fs.StringVar(&opts.convert.ProxyURL, "proxy-url", "", "reader proxy the page URL is appended to when the origin blocks the request (default: $URL2MD_PROXY_URL or https://r.jina.ai/)")
+	fs.StringVar(&opts.convert.HTTPProxy, "proxy", "", "forward proxy for all requests, e.g. http://proxy:3128 or socks5://127.0.0.1:1080 (default: $HTTP_PROXY, $HTTPS_PROXY and $NO_PROXY)")
+	fs.BoolVar(&opts.convert.InsecureSkipVerify, "insecure", false, "skip TLS certificate verification for the fetched site (never for the reader proxy)")
+	fs.StringVar(&cookiesFile, "cookies", "", "Netscape cookies.txt `file` exported from a browser; its cookies are sent to the hosts they belong to")
+	fs.StringVar(&cookieJar, "cookie-jar", "", "cookies.txt `file` the cookies set by sites are shared between URLs in and saved to, for the next runs")
+	fs.StringVar(&caCert, "cacert", "", "PEM file with extra root CAs to trust for the fetched site")
+	fs.StringVar(&opts.convert.ProxyMethod, "proxy-method", "GET", "method of the reader proxy request; with POST or another method the page URL is sent in the body instead of the proxy URL")
+	fs.StringVar(&opts.convert.ProxyBodyTemplate, "proxy-body-template", "", "Go template of the body sent with -proxy-method, with the page URL as {{.URL}} and the json and query functions (default: "+url2md.DefaultProxyBody+")")
+	fs.BoolVar(&opts.convert.ProxyAuth, "proxy-auth", false, "send JINA_API_KEY to a custom -proxy-url as well")
+	fs.IntVar(&opts.convert.Wrap, "wrap", 0, "hard-wrap paragraph text at N columns (0 disables wrapping)")
+	fs.StringVar(&headingStyle, "heading-style", string(url2md.HeadingATX), "heading syntax: atx (# Title) or setext (underlined)")
+	fs.StringVar(&linkStyle, "link-style", string(url2md.LinkInline), "link syntax: inline ([text](url)) or referenced ([text][1] with definitions at the end)")
+	fs.StringVar(&definitionListStyle, "definition-list-style", string(url2md.DefinitionListBold), "definition list syntax: bold (bold terms, quoted definitions) or extra (\": definition\" lines, as in PHP Markdown Extra)")
+	fs.BoolVar(&opts.convert.DetailsHeadings, "details-headings", false, "write collapsible <details> sections as a heading from their <summary> instead of raw HTML")
+	fs.BoolVar(&opts.convert.AbsoluteLinks, "absolute-links", false, "rewrite relative href, src and srcset attributes to absolute URLs before conversion")
+	fs.BoolVar(&opts.convert.CleanLinks, "clean-links", false, "strip tracking query parameters (utm_*, fbclid, gclid, mc_*, ...) from links")
+	fs.Var((*stringsFlag)(&cleanParams), "clean-param", "extra query parameter to strip, with an optional trailing * (repeatable, implies -clean-links)")
+	fs.StringVar(&opts.convert.BulletChar, "bullet-char", "-", "marker for unordered list items: -, * or +")
+	fs.Var((*codeFenceFlag)(&opts.convert), "code-fence", "write <pre> blocks as fenced code with the language from their class; -code-fence=LANG sets the default language")
+	fs.BoolVar(&opts.convert.Figures, "figures", false, "keep <figcaption> captions as an italic line under the image (a blockquote for figures without one)")
+	fs.BoolVar(&opts.convert.EmojiImages, "strip-emoji-images", false, "replace emoji images such as <img class=\"emoji\" alt=\"😄\"> with the emoji of their alt text")
+	fs.BoolVar(&opts.convert.EmojiShortcodes, "emoji-shortcodes", false, "write common emoji as GitHub :shortcodes: (e.g. 🎉 as :tada:)")
+	fs.BoolVar(&stripComments, "strip-comments", true, "remove HTML comments and IE conditional comments before conversion")
+	fs.BoolVar(&opts.convert.KeepScripts, "keep-scripts", false, "keep <script>, <style>, <noscript> and <template> elements instead of removing them before conversion")
+	fs.BoolVar(&dropHidden, "drop-hidden", true, "remove elements marked aria-hidden=\"true\", hidden or with an inline display:none before conversion (-drop-hidden=false keeps them)")
+	fs.BoolVar(&removeEmptyLinks, "remove-empty-links", true, "remove links without text, such as [](url) from icon links (-remove-empty-links=false keeps them)")
+	fs.BoolVar(&opts.tidy, "tidy", true, "collapse blank lines, trim trailing whitespace and end the Markdown with one newline")
+	fs.BoolVar(&opts.convert.TOC, "toc", false, "prepend a table of contents linking to the page's headings")
+	fs.BoolVar(&opts.convert.Tables, "table-plugin", false, "convert <table> elements to GitHub-flavored pipe tables")
+	fs.BoolVar(&opts.sitemap, "sitemap", false, "treat the URL as a sitemap.xml and convert every page it lists")
+	fs.IntVar(&opts.maxPages, "max-pages", 500, "maximum number of pages converted in -sitemap and -crawl modes (0 for no limit)")
+	fs.BoolVar(&opts.crawlMode, "crawl", false, "convert the URL and then follow its same-host links up to -depth")
+	fs.IntVar(&opts.depth, "depth", 1, "with -crawl, how many links away from the start page to follow")
+	fs.BoolVar(&opts.dedupSymlink, "dedup-symlink", false, "with -crawl, write pages whose Markdown matches an already written page as symbolic links to its file instead of skipping them")
+	fs.Var(&opts.rateLimit, "rate-limit", "with several URLs, allow at most this many requests per host, e.g. 2/s, 30/m or 0.5/s")
+	fs.DurationVar(&opts.minDelay, "min-delay", 0, "with several URLs, pause at least this long before each URL after the first, e.g. 500ms")
+	fs.DurationVar(&opts.maxDelay, "max-delay", 0, "with several URLs, upper bound of the random pause before each URL (default -min-delay)")
+	fs.StringVar(&modifiedSince, "modified-since", "", "skip pages whose Last-Modified is not after this RFC 3339 time, e.g. 2024-06-01T00:00:00Z")
+	fs.StringVar(&cacheDir, "cache-dir", "", "directory remembering ETag/Last-Modified per URL; unchanged pages are skipped on later runs")
+	fs.BoolVar(&opts.keepHTML, "keep-html", false, "also save the downloaded HTML next to each Markdown file, with the .html extension")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "fetch and convert, but print the output filename and size to stderr instead of writing it")
+	fs.BoolVar(&opts.noClobber, "no-clobber", false, "skip pages whose output file already exists instead of overwriting it")
+	fs.StringVar(&opts.outTree, "out-tree", "", "save files under `dir`/YYYY/MM/<host>/<path> instead of flat names in the current directory")
+	fs.StringVar(&opts.ext, "ext", ".md", "extension of generated file names (ignored with -o)")
+	fs.StringVar(&outputTemplate, "output-template", "", "Go template naming the output files, with {{.Host}}, {{.Path}}, {{.Title}}, {{.Date}}, {{.Slug}} and {{.Ext}}, e.g. '{{.Date}}-{{.Title}}.md'")
+	fs.BoolVar(&opts.serverName, "use-server-name", false, "name output files after the filename suggested by the server's Content-Disposition header, when there is one")
+	fs.StringVar(&format, "format", defaultFormat, "output format: "+strings.Join(formatNames(), ", ")+" (see -list-formats)")
+	fs.BoolVar(&opts.showVersion, "version", false, "print the version, commit and build date and exit")
+	fs.BoolVar(&opts.listFormats, "list-formats", false, "print the available -format values and exit")
+	fs.BoolVar(&opts.convert.UseCanonical, "use-canonical", false, "name the output and resolve links after the page's same-host <link rel=\"canonical\">")
+	fs.StringVar(&basicAuth, "basic-auth", "", "HTTP Basic credentials \"user:pass\", or \"user\" to be prompted for the password; not sent to the proxy")
+	fs.StringVar(&base, "base", "", "absolute URL to resolve relative links and images against (default: the fetched URL or the local file)")
+	fs.StringVar(&configPath, "config", defaultConfigPath(), "TOML file with default flag values")
+
+	path, explicit := configPathFromArgs(args)
+	if !explicit {
+		path = configPath
+	}
+	if path != "" {
+		if err := loadConfig(fs, path, explicit); err != nil {
+			return nil, err
+		}
+	}
+	opts.convert.MaxSize = url2md.DefaultMaxSize
+	fs.Var((*byteSize)(&opts.convert.MaxSize), "max-size", "maximum size of a downloaded response, e.g. 500KB or 20MB (0 for no limit)")
+	fs.BoolVar(&opts.convert.Force, "force", false, "convert responses with a non-HTML content type (e.g. PDF or JSON) as HTML instead of failing")
+	fs.BoolVar(&opts.convert.FollowMetaRefresh, "follow-meta-refresh", false, "follow <meta http-equiv=\"refresh\"> redirects (counted against -max-redirects)")
+	fs.BoolVar(&opts.convert.PreferCanonicalFromAMP, "prefer-canonical-from-amp", false, "for AMP pages (<html amp>), convert the canonical page they link to instead, falling back to the AMP page")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if opts.showVersion || opts.listFormats {
+		return opts, nil
+	}
+
+	opts.args = fs.Args()
+	if (opts.inputFile == "" && len(opts.args) != 1) || (opts.inputFile != "" && len(opts.args) != 0) {
+		prog := filepath.Base(fs.Name())
+		return nil, fmt.Errorf("usage: %s [-v] [-o <file>|-] <url>\n       %s [-v] -i <file>\n       %s [-v] - < urls.txt\n       %s [-v] -sitemap <sitemap-url>\n       %s [-v] -crawl [-depth <n>] <url>", prog, prog, prog, prog, prog)
+	}
+
+	if opts.sitemap && (opts.inputFile != "" || opts.args[0] == "-") {
+		return nil, errors.New("-sitemap takes the sitemap URL as its only argument")
+	}
+	if opts.crawlMode && (opts.inputFile != "" || opts.args[0] == "-" || opts.sitemap) {
+		return nil, errors.New("-crawl takes the start URL as its only argument and cannot be combined with -sitemap")
+	}
+	if opts.crawlMode && cacheDir != "" {
+		return nil, errors.New("-cache-dir cannot be combined with -crawl")
+	}
+	if opts.dedupSymlink && !opts.crawlMode {
+		return nil, errors.New("-dedup-symlink can only be used with -crawl")
+	}
+	if opts.crawlMode && modifiedSince != "" {
+		return nil, errors.New("-modified-since cannot be combined with -crawl")
+	}
+	if opts.depth < 0 {
+		return nil, errors.New("-depth cannot be negative")
+	}
+	if opts.maxPages < 0 {
+		return nil, errors.New("-max-pages cannot be negative")
+	}
+
+	if verbose && opts.quiet {
+		return nil, errors.New("-v and -quiet are mutually exclusive")
+	}
+	if opts.concurrency < 1 {
+		return nil, errors.New("-c must be at least 1")
+	}
+	if opts.convert.ConnectTimeout < 0 {
+		return nil, fmt.Errorf("invalid -connect-timeout %s: must not be negative", opts.convert.ConnectTimeout)
+	}
+	if opts.timeout <= 0 {
+		return nil, fmt.Errorf("invalid -timeout %s: must be positive", opts.timeout)
+	}
+
+	f, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid -format %q: must be one of %s", format, strings.Join(formatNames(), ", "))
+	}
+	opts.format = f
+	if !isFlagSet(fs, "ext") {
+		opts.ext = f.ext
+	}
+	switch metadata {
+	case "", metadataJSON:
+	case metadataFrontMatter:
+		if opts.fragment {
+			return nil, errors.New("-metadata front-matter cannot be used with -fragment")
+		}
+		opts.frontMatter = true
+	default:
+		return nil, fmt.Errorf("invalid -metadata %q: must be front-matter or json", metadata)
+	}
+	opts.metadata = metadata
+	if opts.fragment {
+		// The document a fragment is embedded in has its own front matter
+		// and table of contents.
+		opts.frontMatter, opts.convert.TOC = false, false
+	}
+	if opts.frontMatter && !f.frontMatter {
+		return nil, fmt.Errorf("-front-matter cannot be used with -format %s", f.name)
+	}
+	if opts.minDelay < 0 || opts.maxDelay < 0 {
+		return nil, errors.New("-min-delay and -max-delay cannot be negative")
+	}
+	if opts.maxDelay == 0 {
+		opts.maxDelay = opts.minDelay
+	}
+	if opts.maxDelay < opts.minDelay {
+		return nil, fmt.Errorf("-max-delay %s is shorter than -min-delay %s", opts.maxDelay, opts.minDelay)
+	}
+
+	opts.ext = strings.TrimSpace(opts.ext)
+	if opts.ext == "" || strings.ContainsAny(opts.ext, `/\`) {
+		return nil, fmt.Errorf("invalid -ext %q", opts.ext)
+	}
+	if !strings.HasPrefix(opts.ext, ".") {
+		opts.ext = "." + opts.ext
+	}
+
+	if opts.output == "-" {
+		opts.stdout, opts.output = true, ""
+	}
+	if opts.stdout && opts.output != "" {
+		return nil, errors.New("-stdout and -o <file> are mutually exclusive")
+	}
+	if opts.outTree != "" && (opts.output != "" || opts.stdout) {
+		return nil, errors.New("-out-tree cannot be combined with -o or -stdout")
+	}
+	if opts.json && opts.stdout {
+		return nil, errors.New("-json and -stdout are mutually exclusive")
+	}
+	switch mode := url2md.ImageMode(images); mode {
+	case url2md.ImagesKeep, url2md.ImagesStrip, url2md.ImagesDownload:
+		opts.convert.Images = mode
+	default:
+		return nil, fmt.Errorf("invalid -images %q: must be keep, strip or download", images)
+	}
+	if outputTemplate != "" {
+		if opts.output != "" || opts.stdout || opts.outTree != "" || opts.serverName {
+			return nil, errors.New("-output-template cannot be combined with -o, -stdout, -out-tree or -use-server-name")
+		}
+		if strings.ContainsAny(outputTemplate, `/\`) && opts.convert.Images == url2md.ImagesDownload {
+			// As with -out-tree, the directory is only known once the
+			// page is fetched.
+			return nil, errors.New("-output-template with directories cannot be combined with -images download")
+		}
+		tmpl, err := parseOutputTemplate(outputTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -output-template: %v", err)
+		}
+		opts.outputTemplate = tmpl
+	}
+	if opts.outTree != "" && opts.convert.Images == url2md.ImagesDownload {
+		// The directory of each file is only known once the page is fetched,
+		// too late to save its images next to it.
+		return nil, errors.New("-out-tree cannot be combined with -images download")
+	}
+	switch style := url2md.HeadingStyle(headingStyle); style {
+	case url2md.HeadingATX, url2md.HeadingSetext:
+		opts.convert.HeadingStyle = style
+	default:
+		return nil, fmt.Errorf("invalid -heading-style %q: must be atx or setext", headingStyle)
+	}
+	switch style := url2md.LinkStyle(linkStyle); style {
+	case url2md.LinkInline, url2md.LinkReferenced:
+		opts.convert.LinkStyle = style
+	default:
+		return nil, fmt.Errorf("invalid -link-style %q: must be inline or referenced", linkStyle)
+	}
+	switch style := url2md.DefinitionListStyle(definitionListStyle); style {
+	case url2md.DefinitionListBold, url2md.DefinitionListExtra:
+		opts.convert.DefinitionListStyle = style
+	default:
+		return nil, fmt.Errorf("invalid -definition-list-style %q: must be bold or extra", definitionListStyle)
+	}
+	switch opts.convert.BulletChar {
+	case "-", "*", "+":
+	default:
+		return nil, fmt.Errorf("invalid -bullet-char %q: must be -, * or +", opts.convert.BulletChar)
+	}
+	if len(opts.convert.NavSelectors) > 0 {
+		opts.convert.TrimNav = true
+	}
+	if len(cleanParams) > 0 {
+		opts.convert.CleanLinks = true
+		opts.convert.TrackingParams = append(append([]string{}, url2md.DefaultTrackingParams...), cleanParams...)
+	}
+	opts.convert.NoWarmup = !warmup
+	opts.convert.KeepComments = !stripComments
+	opts.convert.KeepEmptyLinks = !removeEmptyLinks
+	opts.convert.KeepHidden = !dropHidden
+	opts.convert.Header = http.Header(headers)
+	if opts.convert.MinLength < 0 {
+		return nil, errors.New("-min-length cannot be negative")
+	}
+	if opts.convert.ProxyOnEmpty && (opts.convert.MinLength == 0 || opts.convert.NoProxy) {
+		return nil, errors.New("-proxy-on-empty needs a -min-length above 0 and the proxy enabled (no -no-proxy)")
+	}
+	if opts.convert.Retries < 0 {
+		return nil, errors.New("-retries cannot be negative")
+	}
+	if opts.convert.Wrap < 0 {
+		return nil, errors.New("-wrap cannot be negative")
+	}
+	if opts.convert.MaxRedirects < 0 {
+		return nil, errors.New("-max-redirects cannot be negative")
+	}
+	if opts.convert.MaxRedirects == 0 {
+		opts.convert.MaxRedirects = -1
+	}
+	if opts.convert.MaxSize == 0 {
+		opts.convert.MaxSize = -1
+	}
+
+	if userAgentFile != "" {
+		if opts.convert.UserAgent != "" {
+			return nil, errors.New("-user-agent and -user-agent-file are mutually exclusive")
+		}
+		agents, err := loadUserAgents(userAgentFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.convert.UserAgents = url2md.NewUserAgentRotation(agents)
+	}
+	if opts.convert.UserAgent == "" {
+		opts.convert.UserAgent = strings.TrimSpace(os.Getenv("URL2MD_USER_AGENT"))
+	}
+
+	if opts.convert.ProxyURL == "" {
+		opts.convert.ProxyURL = strings.TrimSpace(os.Getenv("URL2MD_PROXY_URL"))
+	}
+	if opts.convert.ProxyURL != "" {
+		if err := url2md.ValidateProxyURL(opts.convert.ProxyURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := url2md.ValidateAcceptLanguage(opts.convert.AcceptLanguage); err != nil {
+		return nil, err
+	}
+	if err := url2md.ValidateAccept(opts.convert.Accept); err != nil {
+		return nil, err
+	}
+	if err := url2md.ValidateProxyRequest(opts.convert.ProxyMethod, opts.convert.ProxyBodyTemplate); err != nil {
+		return nil, err
+	}
+	if opts.convert.HTTPProxy != "" {
+		if err := url2md.ValidateHTTPProxy(opts.convert.HTTPProxy); err != nil {
+			return nil, err
+		}
+	}
+
+	if cookiesFile != "" {
+		cookies, err := loadCookies(cookiesFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.convert.Cookies = cookies
+	}
+	if cookieJar != "" {
+		jar, err := url2md.OpenCookieJar(cookieJar)
+		if err != nil {
+			return nil, err
+		}
+		jar.Add(opts.convert.Cookies)
+		opts.convert.CookieJar = jar
+	}
+
+	if caCert != "" {
+		pool, err := loadCACert(caCert)
+		if err != nil {
+			return nil, err
+		}
+		opts.convert.RootCAs = pool
+	}
+	if modifiedSince != "" {
+		since, err := time.Parse(time.RFC3339, modifiedSince)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -modified-since %q: must be an RFC 3339 time like 2024-06-01T00:00:00Z", modifiedSince)
+		}
+		opts.convert.ModifiedSince = since
+	}
+
+	if cacheDir != "" {
+		cache, err := url2md.OpenCache(cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		opts.convert.Cache = cache
+	}
+
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil || !u.IsAbs() || (u.Host == "" && u.Scheme != "file") {
+			return nil, fmt.Errorf("invalid -base %q: must be an absolute URL", base)
+		}
+		opts.convert.BaseURL = u
+	}
+
+	if basicAuth != "" {
+		info, err := parseBasicAuth(basicAuth, promptPassword)
+		if err != nil {
+			return nil, err
+		}
+		opts.convert.BasicAuth = info
+	}
+
+	// One transport for the whole run, so that a batch against the same
+	// site reuses its connections instead of dialing for every URL.
+	transport := url2md.NewTransport(opts.convert)
+	transport.MaxIdleConnsPerHost = max(transport.MaxIdleConnsPerHost, opts.concurrency)
+	opts.convert.Transport = transport
+	proxyTransport := url2md.NewProxyTransport(opts.convert)
+	proxyTransport.MaxIdleConnsPerHost = max(proxyTransport.MaxIdleConnsPerHost, opts.concurrency)
+	opts.convert.ProxyTransport = proxyTransport
+
+	switch {
+	case verbose:
+		logLevel = "debug"
+	case opts.quiet:
+		logLevel = "error"
+	}
+	logger, err := newLogger(stderr, logLevel, logFormat)
+	if err != nil {
+		return nil, err
+	}
+	opts.logger = logger
+	opts.convert.Logger = logger
+	return opts, nil
+}
+
+// isFlagSet reports whether the named flag was given on the command line or
+// in the config file.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
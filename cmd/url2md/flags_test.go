@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testParseFlags runs parseFlags on a fresh flag set, without the user's
+// config file and environment.
+func testParseFlags(t *testing.T, args ...string) (*options, error) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("URL2MD_USER_AGENT", "")
+	t.Setenv("URL2MD_PROXY_URL", "")
+	fs := flag.NewFlagSet("url2md", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return parseFlags(fs, args)
+}
+
+func TestParseFlagsDefaults(t *testing.T) {
+	opts, err := testParseFlags(t, "https://example.com")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if opts.concurrency != 4 || opts.ext != ".md" || opts.format.name != "markdown" || !opts.tidy {
+		t.Fatalf("defaults = concurrency %d, ext %q, format %q, tidy %v", opts.concurrency, opts.ext, opts.format.name, opts.tidy)
+	}
+	if opts.convert.MaxRedirects != 10 || opts.convert.NoWarmup || opts.convert.KeepComments || opts.convert.KeepEmptyLinks || opts.convert.KeepHidden {
+		t.Fatalf("convert defaults = %+v", opts.convert)
+	}
+	if !slices.Equal(opts.args, []string{"https://example.com"}) {
+		t.Fatalf("args = %q, expected the URL", opts.args)
+	}
+	if opts.logger == nil || opts.convert.Transport == nil || opts.convert.ProxyTransport == nil {
+		t.Fatal("parseFlags left the logger or the transport unset")
+	}
+}
+
+func TestParseFlagsNormalizes(t *testing.T) {
+	tests := []struct {
+		args  []string
+		check func(*options) bool
+	}{
+		{[]string{"-ext", "txt"}, func(o *options) bool { return o.ext == ".txt" }},
+		{[]string{"-format", "json"}, func(o *options) bool { return o.ext == ".json" }},
+		{[]string{"-format", "json", "-ext", ".out"}, func(o *options) bool { return o.ext == ".out" }},
+		{[]string{"-o", "-"}, func(o *options) bool { return o.stdout && o.output == "" }},
+		{[]string{"-max-redirects", "0"}, func(o *options) bool { return o.convert.MaxRedirects == -1 }},
+		{[]string{"-max-size", "0"}, func(o *options) bool { return o.convert.MaxSize == -1 }},
+		{[]string{"-min-delay", "2s"}, func(o *options) bool { return o.maxDelay == o.minDelay }},
+		{[]string{"-warmup=false", "-strip-comments=false"}, func(o *options) bool { return o.convert.NoWarmup && o.convert.KeepComments }},
+		{[]string{"-remove-empty-links=false"}, func(o *options) bool { return o.convert.KeepEmptyLinks }},
+		{[]string{"-drop-hidden=false"}, func(o *options) bool { return o.convert.KeepHidden }},
+		{[]string{"-metadata", "front-matter"}, func(o *options) bool { return o.frontMatter && o.metadata == metadataFrontMatter }},
+		{[]string{"-nav-selector", ".toolbar"}, func(o *options) bool {
+			return o.convert.TrimNav && slices.Equal(o.convert.NavSelectors, []string{".toolbar"})
+		}},
+		{[]string{"-fragment", "-front-matter", "-toc"}, func(o *options) bool { return o.fragment && !o.frontMatter && !o.convert.TOC }},
+		{[]string{"-fragment", "-front-matter", "-format", "json"}, func(o *options) bool { return !o.frontMatter }},
+		{[]string{"-v", "-log-level", "error"}, func(o *options) bool { return o.logger.Enabled(context.Background(), slog.LevelDebug) }},
+		{[]string{"-clean-param", "ref"}, func(o *options) bool {
+			return o.convert.CleanLinks && slices.Contains(o.convert.TrackingParams, "ref") && slices.Contains(o.convert.TrackingParams, "utm_*")
+		}},
+	}
+	for _, tt := range tests {
+		opts, err := testParseFlags(t, append(tt.args, "https://example.com")...)
+		if err != nil {
+			t.Fatalf("parseFlags(%q) returned error: %v", tt.args, err)
+		}
+		if !tt.check(opts) {
+			t.Fatalf("parseFlags(%q) = %+v, not normalized as expected", tt.args, opts)
+		}
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "usage:"},
+		{[]string{"https://example.com", "https://example.org"}, "usage:"},
+		{[]string{"-i", "urls.txt", "https://example.com"}, "usage:"},
+		{[]string{"-nope", "https://example.com"}, "flag provided but not defined"},
+		{[]string{"-c", "0", "https://example.com"}, "-c must be at least 1"},
+		{[]string{"-v", "-quiet", "https://example.com"}, "mutually exclusive"},
+		{[]string{"-timeout", "0s", "https://example.com"}, "invalid -timeout"},
+		{[]string{"-connect-timeout", "-1s", "https://example.com"}, "invalid -connect-timeout"},
+		{[]string{"-o", "page.md", "-stdout", "https://example.com"}, "-stdout and -o <file> are mutually exclusive"},
+		{[]string{"-json", "-stdout", "https://example.com"}, "-json and -stdout are mutually exclusive"},
+		{[]string{"-sitemap", "-"}, "-sitemap takes the sitemap URL"},
+		{[]string{"-crawl", "-sitemap", "https://example.com"}, "-crawl takes the start URL"},
+		{[]string{"-crawl", "-cache-dir", dir, "https://example.com"}, "-cache-dir cannot be combined with -crawl"},
+		{[]string{"-dedup-symlink", "https://example.com"}, "-dedup-symlink can only be used with -crawl"},
+		{[]string{"-depth", "-1", "https://example.com"}, "-depth cannot be negative"},
+		{[]string{"-format", "pdf", "https://example.com"}, "invalid -format \"pdf\""},
+		{[]string{"-front-matter", "-format", "json", "https://example.com"}, "-front-matter cannot be used with -format json"},
+		{[]string{"-min-delay", "2s", "-max-delay", "1s", "https://example.com"}, "-max-delay 1s is shorter than -min-delay 2s"},
+		{[]string{"-ext", "a/b", "https://example.com"}, "invalid -ext"},
+		{[]string{"-images", "inline", "https://example.com"}, "invalid -images"},
+		{[]string{"-out-tree", dir, "-images", "download", "https://example.com"}, "-out-tree cannot be combined with -images download"},
+		{[]string{"-output-template", "{{.Nope}}", "https://example.com"}, "invalid -output-template"},
+		{[]string{"-output-template", "{{.Host}}.md", "-o", "x.md", "https://example.com"}, "-output-template cannot be combined"},
+		{[]string{"-heading-style", "bold", "https://example.com"}, "invalid -heading-style"},
+		{[]string{"-definition-list-style", "indent", "https://example.com"}, "invalid -definition-list-style"},
+		{[]string{"-metadata", "yaml", "https://example.com"}, "invalid -metadata"},
+		{[]string{"-proxy-on-empty", "-no-proxy", "https://example.com"}, "-proxy-on-empty needs"},
+		{[]string{"-proxy-on-empty", "-min-length", "0", "https://example.com"}, "-proxy-on-empty needs"},
+		{[]string{"-metadata", "front-matter", "-fragment", "https://example.com"}, "-metadata front-matter cannot be used with -fragment"},
+		{[]string{"-metadata", "front-matter", "-format", "json", "https://example.com"}, "-front-matter cannot be used with -format json"},
+		{[]string{"-bullet-char", "x", "https://example.com"}, "invalid -bullet-char"},
+		{[]string{"-lang", "italian language", "https://example.com"}, "invalid Accept-Language"},
+		{[]string{"-accept", "markdown", "https://example.com"}, "invalid Accept"},
+		{[]string{"-modified-since", "yesterday", "https://example.com"}, "invalid -modified-since"},
+		{[]string{"-base", "/docs/", "https://example.com"}, "invalid -base"},
+		{[]string{"-cookies", filepath.Join(dir, "missing.txt"), "https://example.com"}, "-cookies"},
+		{[]string{"-user-agent-file", filepath.Join(dir, "missing.txt"), "https://example.com"}, "-user-agent-file"},
+		{[]string{"-user-agent", "curl/8.0", "-user-agent-file", filepath.Join(dir, "missing.txt"), "https://example.com"}, "mutually exclusive"},
+		{[]string{"-log-format", "xml", "https://example.com"}, "xml"},
+		{[]string{"-max-size", "9999999999GB", "https://example.com"}, "size \"9999999999GB\" is too large"},
+	}
+	for _, tt := range tests {
+		_, err := testParseFlags(t, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("parseFlags(%q) error = %v, expected it to contain %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsVersionNeedsNoURL(t *testing.T) {
+	for _, arg := range []string{"-version", "-list-formats"} {
+		opts, err := testParseFlags(t, arg)
+		if err != nil {
+			t.Fatalf("parseFlags(%s) returned error: %v", arg, err)
+		}
+		if !opts.showVersion && !opts.listFormats {
+			t.Fatalf("parseFlags(%s) did not record the flag", arg)
+		}
+	}
+}
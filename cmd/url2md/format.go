@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// Writer renders a converted page in one output format.
+type Writer interface {
+	Write(res url2md.Result, w io.Writer) error
+}
+
+// WriterFunc adapts a function to the Writer interface.
+type WriterFunc func(res url2md.Result, w io.Writer) error
+
+func (f WriterFunc) Write(res url2md.Result, w io.Writer) error { return f(res, w) }
+
+// outputFormat is an entry of the -format registry.
+type outputFormat struct {
+	name        string
+	description string
+	// ext is the default extension of generated file names.
+	ext string
+	// frontMatter reports whether -front-matter can be prepended to the
+	// output.
+	frontMatter bool
+	writer      Writer
+}
+
+// defaultFormat is the -format used when none is given.
+const defaultFormat = "markdown"
+
+var formats = map[string]outputFormat{}
+
+// registerFormat adds f to the formats selectable with -format. It panics if
+// the name is already taken.
+func registerFormat(f outputFormat) {
+	if _, ok := formats[f.name]; ok {
+		panic("url2md: format " + f.name + " registered twice")
+	}
+	formats[f.name] = f
+}
+
+// formatNames returns the registered format names in alphabetical order.
+func formatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listFormats prints one line per registered format, as shown by
+// -list-formats.
+func listFormats(w io.Writer) {
+	for _, name := range formatNames() {
+		f := formats[name]
+		fmt.Fprintf(w, "%-10s %-6s %s\n", f.name, f.ext, f.description)
+	}
+}
+
+func init() {
+	registerFormat(outputFormat{
+		name:        "markdown",
+		description: "the converted Markdown",
+		ext:         ".md",
+		frontMatter: true,
+		writer: WriterFunc(func(res url2md.Result, w io.Writer) error {
+			_, err := io.WriteString(w, res.Markdown)
+			return err
+		}),
+	})
+	registerFormat(outputFormat{
+		name:        "text",
+		description: "plain text without markup, e.g. for search indexes",
+		ext:         ".txt",
+		frontMatter: true,
+		writer: WriterFunc(func(res url2md.Result, w io.Writer) error {
+			_, err := io.WriteString(w, url2md.PlainText(res.Markdown))
+			return err
+		}),
+	})
+	registerFormat(outputFormat{
+		name:        "json",
+		description: "a JSON object with the URL, title and Markdown of the page",
+		ext:         ".json",
+		writer: WriterFunc(func(res url2md.Result, w io.Writer) error {
+			data, err := json.MarshalIndent(newJSONResult(res, ""), "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		}),
+	})
+}
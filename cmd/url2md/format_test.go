@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestFormats(t *testing.T) {
+	u, _ := url.Parse("https://example.com/docs")
+	res := url2md.Result{
+		Markdown:  "# Docs\n\nSee [the guide](https://example.com/guide).",
+		FinalURL:  u,
+		Title:     "Docs",
+		FetchedAt: time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC),
+	}
+	tests := map[string]string{
+		"markdown": res.Markdown,
+		"text":     "Docs\n\nSee the guide.",
+	}
+	for name, want := range tests {
+		var out strings.Builder
+		if err := formats[name].writer.Write(res, &out); err != nil {
+			t.Fatalf("%s: Write returned error: %v", name, err)
+		}
+		if out.String() != want {
+			t.Fatalf("%s: output = %q, expected %q", name, out.String(), want)
+		}
+	}
+
+	var out strings.Builder
+	if err := formats["json"].writer.Write(res, &out); err != nil {
+		t.Fatalf("json: Write returned error: %v", err)
+	}
+	var decoded jsonResult
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("json: output %q is not valid JSON: %v", out.String(), err)
+	}
+	if decoded.URL != u.String() || decoded.Title != "Docs" || decoded.Markdown != res.Markdown {
+		t.Fatalf("json: decoded %+v, expected the page URL, title and Markdown", decoded)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	registerFormat(outputFormat{
+		name: "test-upper",
+		ext:  ".up",
+		writer: WriterFunc(func(res url2md.Result, w io.Writer) error {
+			_, err := io.WriteString(w, strings.ToUpper(res.Markdown))
+			return err
+		}),
+	})
+	defer delete(formats, "test-upper")
+
+	var list strings.Builder
+	listFormats(&list)
+	if !strings.Contains(list.String(), "test-upper") || !strings.Contains(list.String(), "markdown") {
+		t.Fatalf("listFormats = %q, expected the registered formats", list.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("registering markdown twice did not panic")
+		}
+	}()
+	registerFormat(outputFormat{name: "markdown"})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// The values of -metadata.
+const (
+	metadataFrontMatter = "front-matter"
+	metadataJSON        = "json"
+)
+
+// pageMetadata is the document information written to the front matter.
+type pageMetadata struct {
+	title       string
+	description string
+	// openGraph is set with -metadata front-matter.
+	openGraph url2md.Metadata
+}
+
+// frontMatter renders a YAML front matter block for a page fetched from
+// source. The title falls back to the URL host when the page has none; the
+// url field is omitted when source is nil, as for HTML read from stdin.
+func frontMatter(source *url.URL, meta pageMetadata, fetchedAt time.Time) string {
+	title := meta.title
+	if title == "" && source != nil {
+		title = source.Host
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	if source != nil {
+		b.WriteString("url: " + yamlString(source.String()) + "\n")
+	}
+	b.WriteString("title: " + yamlString(title) + "\n")
+	if meta.description != "" {
+		b.WriteString("description: " + yamlString(meta.description) + "\n")
+	}
+	og := meta.openGraph
+	if og.Title != "" && og.Title != title {
+		b.WriteString("og_title: " + yamlString(og.Title) + "\n")
+	}
+	if og.Description != "" && og.Description != meta.description {
+		b.WriteString("og_description: " + yamlString(og.Description) + "\n")
+	}
+	if og.Image != "" {
+		b.WriteString("image: " + yamlString(og.Image) + "\n")
+	}
+	if og.PublishedTime != "" {
+		b.WriteString("published_time: " + yamlString(og.PublishedTime) + "\n")
+	}
+	if og.Author != "" {
+		b.WriteString("author: " + yamlString(og.Author) + "\n")
+	}
+	b.WriteString("fetched_at: " + fetchedAt.UTC().Format(time.RFC3339) + "\n")
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar. The escapes produced by
+// strconv.Quote are a subset of those YAML accepts.
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
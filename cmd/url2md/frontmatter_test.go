@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestFrontMatter(t *testing.T) {
+	u, _ := url.Parse("https://example.com/docs")
+	fetchedAt := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	got := frontMatter(u, pageMetadata{title: `Say "hi"`, description: "Intro"}, fetchedAt)
+	expected := "---\n" +
+		"url: \"https://example.com/docs\"\n" +
+		"title: \"Say \\\"hi\\\"\"\n" +
+		"description: \"Intro\"\n" +
+		"fetched_at: 2024-06-01T12:30:00Z\n" +
+		"---\n\n"
+	if got != expected {
+		t.Fatalf("frontMatter = %q, expected %q", got, expected)
+	}
+}
+
+func TestFrontMatterFallsBackToHost(t *testing.T) {
+	u, _ := url.Parse("https://example.com/docs")
+
+	got := frontMatter(u, pageMetadata{}, time.Unix(0, 0))
+	expected := "---\n" +
+		"url: \"https://example.com/docs\"\n" +
+		"title: \"example.com\"\n" +
+		"fetched_at: 1970-01-01T00:00:00Z\n" +
+		"---\n\n"
+	if got != expected {
+		t.Fatalf("frontMatter = %q, expected %q", got, expected)
+	}
+}
+
+func TestFrontMatterOpenGraph(t *testing.T) {
+	u, _ := url.Parse("https://example.com/blog/release-2")
+	meta := pageMetadata{
+		title:       "Release 2.0",
+		description: "What is new in 2.0.",
+		openGraph: url2md.Metadata{
+			Title:         "Release 2.0",
+			Description:   "Faster conversions and a new CLI.",
+			Image:         "https://example.com/img/release-2.png",
+			PublishedTime: "2024-06-03T10:00:00+02:00",
+		},
+	}
+
+	got := frontMatter(u, meta, time.Unix(0, 0))
+	expected := "---\n" +
+		"url: \"https://example.com/blog/release-2\"\n" +
+		"title: \"Release 2.0\"\n" +
+		"description: \"What is new in 2.0.\"\n" +
+		"og_description: \"Faster conversions and a new CLI.\"\n" +
+		"image: \"https://example.com/img/release-2.png\"\n" +
+		"published_time: \"2024-06-03T10:00:00+02:00\"\n" +
+		"fetched_at: 1970-01-01T00:00:00Z\n" +
+		"---\n\n"
+	if got != expected {
+		t.Fatalf("frontMatter = %q, expected %q", got, expected)
+	}
+}
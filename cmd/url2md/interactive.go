@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// promptContent returns the url2md.Options.PickContent used by -interactive:
+// it lists the candidates on out and reads the number of the chosen one from
+// in. An empty answer or the end of input converts the whole page.
+func promptContent(in io.Reader, out io.Writer) func([]url2md.Candidate) (int, error) {
+	reader := bufio.NewReader(in)
+	return func(candidates []url2md.Candidate) (int, error) {
+		fmt.Fprintln(out, "Content candidates:")
+		fmt.Fprintf(out, "%3d) the whole page\n", 0)
+		for i, c := range candidates {
+			fmt.Fprintf(out, "%3d) %s (%d characters)\n     %s\n", i+1, c.Selector, c.TextLength, c.Preview)
+		}
+		for {
+			fmt.Fprintf(out, "Convert which one? [0-%d, default 0]: ", len(candidates))
+			line, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			answer := strings.TrimSpace(line)
+			if answer == "" {
+				if errors.Is(err, io.EOF) {
+					fmt.Fprintln(out)
+				}
+				return -1, nil
+			}
+			if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 0 && n <= len(candidates) {
+				return n - 1, nil
+			}
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(out)
+				return -1, nil
+			}
+			fmt.Fprintf(out, "Please enter a number between 0 and %d.\n", len(candidates))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestPromptContent(t *testing.T) {
+	candidates := []url2md.Candidate{
+		{Selector: "main#content", TextLength: 1200, Preview: "Article text"},
+		{Selector: "body > nav", TextLength: 40, Preview: "Home About"},
+	}
+	cases := map[string]int{
+		"1\n":          0,
+		"2\n":          1,
+		" 2 \n":        1,
+		"0\n":          -1,
+		"\n":           -1,
+		"":             -1,
+		"x\n9\n1\n":    0,
+		"nope":         -1,
+		"-1\n\n":       -1,
+		"2":            1,
+		"abc\n2\nxx\n": 1,
+	}
+	for input, want := range cases {
+		var out strings.Builder
+		got, err := promptContent(strings.NewReader(input), &out)(candidates)
+		if err != nil {
+			t.Fatalf("input %q: promptContent returned error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("input %q: picked %d, expected %d", input, got, want)
+		}
+		if !strings.Contains(out.String(), "  1) main#content (1200 characters)\n     Article text\n") {
+			t.Fatalf("input %q: candidates not listed:\n%s", input, out.String())
+		}
+	}
+
+	var out strings.Builder
+	promptContent(strings.NewReader("x\n1\n"), &out)(candidates)
+	if !strings.Contains(out.String(), "Please enter a number between 0 and 2.") {
+		t.Fatalf("invalid answer not reported:\n%s", out.String())
+	}
+}
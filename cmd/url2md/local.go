@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// localSource reports whether arg names a local HTML file, either as a
+// file:// URL or as the path of an existing regular file, and returns its
+// path.
+func localSource(arg string) (string, bool) {
+	if strings.HasPrefix(arg, "file://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return "", false
+		}
+		return filepath.FromSlash(u.Path), true
+	}
+	if info, err := os.Stat(arg); err == nil && info.Mode().IsRegular() {
+		return arg, true
+	}
+	return "", false
+}
+
+// looksLikeHTML reports whether the buffered input starts, after any BOM
+// and whitespace, with '<', which tells HTML on stdin apart from a URL list.
+func looksLikeHTML(r *bufio.Reader) bool {
+	head, _ := r.Peek(512)
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(head) > 0 && head[0] == '<'
+}
+
+// processFile converts the HTML file at name without any network request.
+func processFile(ctx context.Context, name string, opts *options) error {
+	page, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	source := &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	return processHTML(ctx, page, source, source.String(), opts)
+}
+
+// processHTML converts page, which was read from source rather than fetched,
+// and writes the result like processURL. source may be nil.
+func processHTML(parent context.Context, page []byte, source *url.URL, requested string, opts *options) error {
+	ctx, cancel := context.WithTimeout(parent, opts.timeout)
+	defer cancel()
+
+	opts.logger.Info("Converting local HTML", "file", requested)
+	res, err := url2md.ConvertHTML(ctx, page, source, opts.convertOptions())
+	if err = allowEmpty(err, requested, opts); err != nil {
+		return err
+	}
+	return writeResult(res, requested, opts)
+}
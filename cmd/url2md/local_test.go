@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalSource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(file, []byte("<p>hi</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		arg  string
+		path string
+		ok   bool
+	}{
+		{file, file, true},
+		{"file://" + filepath.ToSlash(file), file, true},
+		{dir, "", false},
+		{"example.com/docs", "", false},
+		{"https://example.com", "", false},
+	}
+	for _, c := range cases {
+		path, ok := localSource(c.arg)
+		if path != c.path || ok != c.ok {
+			t.Fatalf("localSource(%q) = %q, %v; expected %q, %v", c.arg, path, ok, c.path, c.ok)
+		}
+	}
+}
+
+func TestLooksLikeHTML(t *testing.T) {
+	cases := map[string]bool{
+		"<!doctype html><p>x</p>":              true,
+		"\xef\xbb\xbf\n  <html>":               true,
+		"https://example.com/a\nexample.com/b": false,
+		"# comment\n<not html>":                false,
+		"":                                     false,
+	}
+	for input, want := range cases {
+		r := bufio.NewReader(strings.NewReader(input))
+		if got := looksLikeHTML(r); got != want {
+			t.Fatalf("looksLikeHTML(%q) = %v, expected %v", input, got, want)
+		}
+		if rest, _ := r.ReadString(0); rest != input {
+			t.Fatalf("looksLikeHTML consumed input: %q left of %q", rest, input)
+		}
+	}
+}
+
+func TestOutputFilenameForLocalFiles(t *testing.T) {
+	cases := map[string]string{
+		"file:///tmp/saved.html":     "saved.md",
+		"file:///tmp/archive.v2.htm": "archive.v2.md",
+		"file:///":                   "output.md",
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := outputFilename(u, ".md"); got != want {
+			t.Fatalf("outputFilename(%q) = %q, expected %q", raw, got, want)
+		}
+	}
+}
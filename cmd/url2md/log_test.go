@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var out strings.Builder
+	logger, err := newLogger(&out, "info", "json")
+	if err != nil {
+		t.Fatalf("newLogger returned error: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Info("Fetched page", "bytes", 42)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+		t.Fatalf("log output %q is not a single JSON object: %v", out.String(), err)
+	}
+	if entry["level"] != "INFO" || entry["msg"] != "Fetched page" || entry["bytes"] != float64(42) {
+		t.Fatalf("log entry = %v, expected the info message with its bytes", entry)
+	}
+
+	for _, args := range [][2]string{{"verbose", "text"}, {"debug", "xml"}} {
+		if _, err := newLogger(&out, args[0], args[1]); err == nil {
+			t.Fatalf("newLogger(%q, %q) returned no error", args[0], args[1])
+		}
+	}
+}
+
+// testLogger returns a logger that writes every message through t.Log.
+func testLogger(t *testing.T) *slog.Logger {
+	return slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
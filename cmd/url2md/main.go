@@ -1,177 +1,712 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"mime"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
+	"golang.org/x/term"
+
+	"url-to-markdown/pkg/url2md"
 )
 
+// options holds the settings shared by every URL processed in a run.
+type options struct {
+	output      string
+	concurrency int
+	timeout     time.Duration
+	frontMatter bool
+	stdout      bool
+	json        bool
+	dryRun      bool
+	noClobber   bool
+	quiet       bool
+	failOnEmpty bool
+	outTree     string
+	ext         string
+	format      outputFormat
+	serverName  bool
+	tidy        bool
+	keepHTML    bool
+	fragment    bool
+	metadata    string
+	convert     url2md.Options
+	logger      *slog.Logger
+	minDelay    time.Duration
+	maxDelay    time.Duration
+	// dispatched is set once processAll has handed out a URL, so that the
+	// first URL of a run starts without a -min-delay pause.
+	dispatched bool
+	stats      *batchStats
+	progress   *progress
+
+	// outputTemplate names the output files with -output-template.
+	outputTemplate *template.Template
+
+	// dedup is set by -crawl to skip pages whose Markdown was already
+	// written, or to link them to it with -dedup-symlink.
+	dedup        *contentIndex
+	dedupSymlink bool
+
+	// What to convert and how, as given on the command line: args are the
+	// positional arguments, a URL, a local file or "-".
+	args        []string
+	inputFile   string
+	sitemap     bool
+	crawlMode   bool
+	depth       int
+	maxPages    int
+	interactive bool
+	rateLimit   rateFlag
+	showVersion bool
+	listFormats bool
+}
+
 func main() {
-	var verbose bool
-	var outputFile string
-	flag.BoolVar(&verbose, "v", false, "enable verbose logging")
-	flag.StringVar(&outputFile, "o", "", "output filename (default: auto-generated from URL)")
-	flag.Parse()
+	// The flag package's own messages are held back, so that with -json
+	// they can be replaced by a jsonError.
+	var flagOutput bytes.Buffer
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.CommandLine.SetOutput(&flagOutput)
+	opts, err := parseFlags(flag.CommandLine, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		stderr.Write(flagOutput.Bytes())
+		return
+	}
+	if err != nil {
+		reportFlagError(err, flag.CommandLine, flagOutput.Bytes())
+		os.Exit(exitUsage)
+	}
+	if opts.showVersion {
+		fmt.Println(versionString())
+		return
+	}
+	if opts.listFormats {
+		listFormats(os.Stdout)
+		return
+	}
+	if opts.convert.InsecureSkipVerify && !opts.quiet {
+		fmt.Fprintln(stderr, "WARNING: -insecure disables TLS certificate verification; responses may be intercepted or forged")
+	}
 
-	args := flag.Args()
-	if len(args) != 1 {
-		fmt.Fprintf(os.Stderr, "usage: %s [-v] [-o <file>] <url>\n", filepath.Base(os.Args[0]))
-		os.Exit(2)
+	if opts.interactive {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			opts.convert.PickContent = promptContent(os.Stdin, stderr)
+		} else {
+			opts.logger.Warn("stdin is not a terminal, converting the whole page without -interactive")
+		}
 	}
-	rawURL := args[0]
 
-	logger := func(string, ...interface{}) {}
-	if verbose {
-		logger = func(format string, values ...interface{}) {
-			fmt.Fprintf(os.Stderr, format+"\n", values...)
+	if opts.inputFile == "" && !opts.sitemap && !opts.crawlMode {
+		if name, ok := localSource(opts.args[0]); ok {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := processFile(ctx, name, opts)
+			stop()
+			if err != nil {
+				reportError(err, opts.args[0], exitCode(err), opts)
+				os.Exit(exitCode(err))
+			}
+			return
 		}
 	}
 
-	parsed, err := parseURL(rawURL)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "invalid url: %v\n", err)
-		os.Exit(2)
+	stdin := bufio.NewReader(os.Stdin)
+	if opts.inputFile == "" && opts.args[0] == "-" && looksLikeHTML(stdin) {
+		page, err := io.ReadAll(stdin)
+		if err != nil {
+			reportError(fmt.Errorf("failed to read input: %w", err), "-", exitUsage, opts)
+			os.Exit(exitUsage)
+		}
+		if opts.output == "" && !opts.json {
+			opts.stdout = true
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = processHTML(ctx, page, nil, "-", opts)
+		stop()
+		if err != nil {
+			reportError(err, "-", exitCode(err), opts)
+			os.Exit(exitCode(err))
+		}
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
-	defer cancel()
+	if opts.inputFile == "" && opts.args[0] != "-" && !opts.sitemap && !opts.crawlMode {
+		parsed, err := url2md.ParseURL(opts.args[0])
+		if err != nil {
+			reportError(fmt.Errorf("invalid url: %w", err), opts.args[0], exitUsage, opts)
+			os.Exit(exitUsage)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		_, err = processURL(ctx, parsed, opts)
+		stop()
+		saveCache(opts)
+		saveCookieJar(opts)
+		if err != nil {
+			reportError(err, parsed.String(), exitCode(err), opts)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
 
-	logger("Fetching %s …", parsed.String())
-	body, isHTML, err := fetchHTML(ctx, parsed, logger)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to download %s: %v\n", parsed, err)
-		os.Exit(1)
+	if opts.output != "" {
+		reportError(errors.New("-o cannot be used when converting multiple URLs"), "", exitUsage, opts)
+		os.Exit(exitUsage)
+	}
+	if opts.interactive {
+		reportError(errors.New("-interactive cannot be used when converting multiple URLs"), "", exitUsage, opts)
+		os.Exit(exitUsage)
+	}
+	if opts.rateLimit > 0 {
+		opts.convert.RateLimiter = url2md.NewRateLimiter(time.Duration(opts.rateLimit))
+	}
+	if opts.convert.RespectRobots {
+		opts.convert.Robots = url2md.NewRobotsCache()
 	}
 
-	var markdown string
-	if isHTML {
-		logger("Converting HTML to Markdown")
-		markdown, err = convertToMarkdown(parsed, body)
+	if opts.crawlMode {
+		start, err := url2md.ParseURL(opts.args[0])
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to convert markup: %v\n", err)
-			os.Exit(1)
+			reportError(fmt.Errorf("invalid url: %w", err), opts.args[0], exitUsage, opts)
+			os.Exit(exitUsage)
 		}
-	} else {
-		logger("Using preformatted Markdown response")
-		markdown = string(body)
+		opts.stats = newBatchStats()
+		opts.progress = newProgress(stderr, opts)
+		opts.dedup = newContentIndex()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		pages, failed := crawl(ctx, start, opts.depth, opts.maxPages, opts)
+		stop()
+		opts.progress.stop()
+		saveCookieJar(opts)
+		printSummary(opts.stats, opts.quiet)
+		if failed == pages {
+			os.Exit(exitFailure)
+		}
+		return
 	}
 
-	var filename string
-	if outputFile != "" {
-		filename = outputFile
+	var rawURLs []string
+	if opts.sitemap {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+		var err error
+		rawURLs, err = url2md.SitemapURLs(ctx, opts.args[0], opts.maxPages, opts.convert)
+		cancel()
+		if err != nil {
+			reportError(err, opts.args[0], exitCode(err), opts)
+			os.Exit(exitCode(err))
+		}
 	} else {
-		filename = outputFilename(parsed)
+		input := io.Reader(stdin)
+		if opts.inputFile != "" {
+			f, err := os.Open(opts.inputFile)
+			if err != nil {
+				reportError(fmt.Errorf("failed to open input: %w", err), opts.inputFile, exitUsage, opts)
+				os.Exit(exitUsage)
+			}
+			defer f.Close()
+			input = f
+		}
+
+		var err error
+		rawURLs, err = readURLs(input)
+		if err != nil {
+			reportError(fmt.Errorf("failed to read input: %w", err), cmp.Or(opts.inputFile, "-"), exitUsage, opts)
+			os.Exit(exitUsage)
+		}
+	}
+	if len(rawURLs) == 0 {
+		reportError(errors.New("no URLs to convert"), cmp.Or(opts.inputFile, "-"), exitUsage, opts)
+		os.Exit(exitUsage)
+	}
+
+	opts.stats = newBatchStats()
+	if len(rawURLs) > 1 {
+		opts.progress = newProgress(stderr, opts)
 	}
-	logger("Saving to %s", filename)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	failed := processAll(ctx, rawURLs, opts, nil)
+	stop()
+	opts.progress.stop()
+	saveCache(opts)
+	saveCookieJar(opts)
+	printSummary(opts.stats, opts.quiet)
+	if failed == len(rawURLs) {
+		os.Exit(exitFailure)
+	}
+}
 
-	if err := os.WriteFile(filename, []byte(markdown), 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to write file: %v\n", err)
-		os.Exit(1)
+// saveCache writes the -cache-dir index back to disk, if one is in use and
+// this is not a -dry-run. A failure is reported but does not change the
+// exit code.
+func saveCache(opts *options) {
+	if opts.convert.Cache == nil || opts.dryRun {
+		return
 	}
+	if err := opts.convert.Cache.Save(); err != nil {
+		reportError(err, "", exitWrite, opts)
+	}
+}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Done. Wrote %s\n", filename)
+// saveCookieJar writes the -cookie-jar file back to disk, if one is in use.
+// A failure is reported but does not change the exit code.
+func saveCookieJar(opts *options) {
+	if opts.convert.CookieJar == nil {
+		return
+	}
+	if err := opts.convert.CookieJar.Save(); err != nil {
+		reportError(err, "", exitWrite, opts)
 	}
 }
 
-func parseURL(raw string) (*url.URL, error) {
-	parsed, err := url.Parse(raw)
-	if err != nil {
-		return nil, err
+// randomDelay returns a random duration between low and high, inclusive.
+func randomDelay(low, high time.Duration) time.Duration {
+	if high <= low {
+		return low
+	}
+	return low + rand.N(high-low+1)
+}
+
+// printSummary reports the outcome of a batch run on stderr. Runs that
+// converted a single URL, or quiet runs, print nothing.
+func printSummary(stats *batchStats, quiet bool) {
+	if !quiet && stats.total() > 1 {
+		fmt.Fprintln(stderr, stats.summary())
 	}
-	if parsed.Scheme == "" {
-		parsed.Scheme = "https"
+}
+
+// processAll converts rawURLs using a pool of opts.concurrency workers and
+// returns the number of URLs that failed, recording each outcome in
+// opts.stats and opts.progress when they are set. visit, when non-nil, is
+// called from the workers with the result of every successful conversion.
+// Cancelling ctx stops in-flight downloads and prevents pending URLs from
+// being started.
+func processAll(ctx context.Context, rawURLs []string, opts *options, visit func(url2md.Result)) int {
+	opts.progress.add(len(rawURLs))
+	jobs := make(chan string, opts.concurrency)
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawURL := range jobs {
+				parsed, err := url2md.ParseURL(rawURL)
+				if err != nil {
+					reportError(fmt.Errorf("invalid url %q: %w", rawURL, err), rawURL, exitUsage, opts)
+					failed.Add(1)
+					opts.progress.end(err)
+					if opts.stats != nil {
+						opts.stats.record(url2md.Result{}, err)
+					}
+					continue
+				}
+				opts.progress.begin(parsed)
+				res, err := processURL(ctx, parsed, opts)
+				if opts.stats != nil {
+					opts.stats.record(res, err)
+				}
+				opts.progress.end(err)
+				if err != nil {
+					reportError(err, rawURL, exitCode(err), opts)
+					failed.Add(1)
+					continue
+				}
+				if visit != nil {
+					visit(res)
+				}
+			}
+		}()
+	}
+
+	queued := 0
+feed:
+	for _, rawURL := range rawURLs {
+		if opts.dispatched && opts.maxDelay > 0 {
+			delay := randomDelay(opts.minDelay, opts.maxDelay)
+			opts.logger.Debug("Pausing before the next URL", "delay", delay.Round(time.Millisecond))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				break feed
+			}
+		}
+		select {
+		case jobs <- rawURL:
+			queued++
+			opts.dispatched = true
+		case <-ctx.Done():
+			break feed
+		}
 	}
+	close(jobs)
+	wg.Wait()
+
+	return int(failed.Load()) + len(rawURLs) - queued
+}
+
+// processURL downloads a single page, converts it and writes the result with
+// writeResult. Pages that -cache-dir reports as not modified are skipped
+// without an error. The conversion result is returned even when writing it fails.
+func processURL(parent context.Context, parsed *url.URL, opts *options) (url2md.Result, error) {
+	ctx, cancel := context.WithTimeout(parent, opts.timeout)
+	defer cancel()
 
-	if parsed.Host == "" {
-		guessed, guessErr := url.Parse(parsed.Scheme + "://" + raw)
-		if guessErr == nil && guessed.Host != "" {
-			return guessed, nil
+	start := time.Now()
+	res, err := url2md.Convert(ctx, parsed.String(), opts.convertOptions())
+	opts.logger.Info("Processed", "url", parsed.String(), "duration", time.Since(start).Round(time.Millisecond))
+	err = allowEmpty(err, parsed.String(), opts)
+	if errors.Is(err, url2md.ErrNotModified) {
+		if !opts.quiet {
+			fmt.Fprintf(stderr, "Skipping %s: not modified\n", parsed)
 		}
-		return nil, errors.New("missing host")
+		return res, nil
 	}
-	return parsed, nil
+	if err != nil {
+		return res, err
+	}
+	if err := writeResult(res, parsed.String(), opts); err != nil {
+		return res, err
+	}
+	// Only pages that were written are skipped by the next run.
+	opts.convert.Cache.Store(res.Validators)
+	return res, nil
 }
 
-func fetchHTML(ctx context.Context, target *url.URL, logf func(string, ...interface{})) ([]byte, bool, error) {
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{Jar: jar}
+// allowEmpty turns the url2md.ErrEmptyContent of a page that converted to
+// almost nothing into a warning, so the result is still written, unless
+// -fail-on-empty is set. Other errors are returned unchanged.
+func allowEmpty(err error, source string, opts *options) error {
+	if !errors.Is(err, url2md.ErrEmptyContent) {
+		return err
+	}
+	if opts.failOnEmpty {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if !opts.quiet {
+		fmt.Fprintf(stderr, "Warning: %s: %v; the page may be rendered with JavaScript, try -readability or the reader proxy\n", source, err)
+	}
+	return nil
+}
 
-	hostBase := target.Scheme + "://" + target.Host
+// convertOptions returns the library options for one conversion, adjusted
+// for where the output goes.
+func (o *options) convertOptions() url2md.Options {
+	convertOpts := o.convert
+	if o.writesFile() {
+		convertOpts.OutputDir = filepath.Dir(o.output)
+	}
+	if o.dryRun && convertOpts.Images == url2md.ImagesDownload {
+		o.logger.Debug("Dry run: keeping remote image links instead of downloading")
+		convertOpts.Images = url2md.ImagesKeep
+	}
+	return convertOpts
+}
+
+// writesFile reports whether results are written to markdown files rather
+// than only to stdout.
+func (o *options) writesFile() bool {
+	return !o.stdout && !(o.json && o.output == "")
+}
 
-	// Warm-up request to capture any cookies/challenges that are required for the main document.
-	if warmupReq, err := http.NewRequestWithContext(ctx, http.MethodGet, hostBase+"/", nil); err == nil {
-		applyBrowserHeaders(warmupReq, target, false)
-		if resp, err := client.Do(warmupReq); err == nil {
-			io.Copy(io.Discard, resp.Body)
-			resp.Body.Close()
+// writeResult writes a converted page requested as source to opts.output,
+// or to a name derived from its URL when opts.output is empty, and to stdout
+// with -json or -stdout. With -split-selector every section is written on its
+// own instead, to a file name suffixed with the section ID.
+func writeResult(res url2md.Result, source string, opts *options) error {
+	if len(res.Sections) == 0 {
+		if err := writeOutput(res, source, "", opts); err != nil {
+			return err
+		}
+	}
+	for _, section := range res.Sections {
+		part := res
+		part.Markdown, part.Sections = section.Markdown, nil
+		if section.Title != "" {
+			part.Title = section.Title
+		}
+		if err := writeOutput(part, source, section.ID, opts); err != nil {
+			return err
 		}
 	}
+	if opts.keepHTML {
+		if err := writeHTML(res, opts); err != nil {
+			return err
+		}
+	}
+	if opts.metadata == metadataJSON {
+		return writeMetadata(res, source, opts)
+	}
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+// writeHTML saves the downloaded HTML of res for -keep-html, next to its
+// Markdown file and named like it, with the .html extension.
+func writeHTML(res url2md.Result, opts *options) error {
+	if !opts.writesFile() {
+		return nil
+	}
+	if res.HTML == nil {
+		switch {
+		case res.ViaProxy:
+			opts.logger.Info("No HTML to keep, the reader proxy returned Markdown", "url", res.FinalURL.String())
+		case res.Header != nil:
+			opts.logger.Info("No HTML to keep, the server sent Markdown", "url", res.FinalURL.String())
+		}
+		return nil
+	}
+	name, err := outputName(res, opts)
 	if err != nil {
-		return nil, false, err
+		return &writeError{fmt.Errorf("failed to name output file: %w", err)}
 	}
-	applyBrowserHeaders(req, target, true)
+	return writeSidecar(htmlFilename(name), string(res.HTML), opts)
+}
+
+// jsonMetadata is the object of a -metadata json sidecar file. The title and
+// description fall back to the page's own when it has no Open Graph ones.
+type jsonMetadata struct {
+	URL           string `json:"url"`
+	Title         string `json:"title,omitempty"`
+	Description   string `json:"description,omitempty"`
+	Image         string `json:"image,omitempty"`
+	PublishedTime string `json:"publishedTime,omitempty"`
+	Author        string `json:"author,omitempty"`
+}
 
-	resp, err := client.Do(req)
+// writeMetadata saves the metadata of res for -metadata json, next to its
+// Markdown file and named like it, with the .meta.json extension.
+func writeMetadata(res url2md.Result, source string, opts *options) error {
+	if !opts.writesFile() {
+		return nil
+	}
+	name, err := outputName(res, opts)
+	if err != nil {
+		return &writeError{fmt.Errorf("failed to name output file: %w", err)}
+	}
+	meta := jsonMetadata{
+		URL:           newJSONResult(res, source).URL,
+		Title:         cmp.Or(res.Metadata.Title, res.Title),
+		Description:   cmp.Or(res.Metadata.Description, res.Description),
+		Image:         res.Metadata.Image,
+		PublishedTime: res.Metadata.PublishedTime,
+		Author:        res.Metadata.Author,
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
-		return nil, false, err
+		return &writeError{fmt.Errorf("failed to encode metadata: %w", err)}
 	}
-	defer resp.Body.Close()
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	return writeSidecar(base+".meta.json", string(data)+"\n", opts)
+}
 
-	isCloudflare := strings.Contains(strings.ToLower(resp.Header.Get("Server")), "cloudflare")
-	switch resp.StatusCode {
-	case http.StatusForbidden, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable:
-		reason := fmt.Sprintf("Received %d from origin", resp.StatusCode)
-		if isCloudflare {
-			reason = "Hit Cloudflare challenge"
+// writeSidecar writes a file that accompanies a converted page, such as
+// its -keep-html copy, honouring -dry-run and -no-clobber.
+func writeSidecar(name, content string, opts *options) error {
+	if opts.dryRun {
+		fmt.Fprintf(stderr, "%s (%d bytes)\n", name, len(content))
+		return nil
+	}
+	if err := writeFile(name, content, opts.noClobber); err != nil {
+		if opts.noClobber && errors.Is(err, fs.ErrExist) {
+			if !opts.quiet {
+				fmt.Fprintf(stderr, "Skipping %s: file already exists\n", name)
+			}
+			return nil
 		}
-		if fallback, err := fetchViaProxy(ctx, target); err == nil {
-			logf("%s, fetched content via proxy", reason)
-			return fallback, false, nil
-		} else {
-			logf("%s, proxy fallback failed: %v", reason, err)
-			return nil, false, fmt.Errorf("%s and proxy fallback failed: %w", resp.Status, err)
+		return &writeError{fmt.Errorf("failed to write file: %w", err)}
+	}
+	opts.logger.Info("Wrote", "file", name)
+	return nil
+}
+
+// htmlFilename returns the -keep-html file name for the Markdown file
+// filename: the same name with the .html extension, or ending in
+// .source.html when filename is an .html file itself.
+func htmlFilename(filename string) string {
+	ext := filepath.Ext(filename)
+	if strings.EqualFold(ext, ".html") {
+		return strings.TrimSuffix(filename, ext) + ".source.html"
+	}
+	return strings.TrimSuffix(filename, ext) + ".html"
+}
+
+// writeOutput writes one document for writeResult. section is the ID of the
+// section res holds, or "" for a whole page.
+func writeOutput(res url2md.Result, source, section string, opts *options) error {
+	if opts.tidy {
+		res.Markdown = url2md.Tidy(res.Markdown)
+	}
+	if opts.fragment {
+		res.Markdown = strings.TrimSpace(res.Markdown)
+	}
+	fm := ""
+	if opts.frontMatter {
+		meta := pageMetadata{title: res.Title, description: res.Description}
+		if opts.metadata == metadataFrontMatter {
+			meta.openGraph = res.Metadata
 		}
+		fm = frontMatter(res.FinalURL, meta, res.FetchedAt)
+	}
+	format := opts.format
+	if format.writer == nil {
+		format = formats[defaultFormat]
+	}
+	var out strings.Builder
+	if format.frontMatter {
+		out.WriteString(fm)
 	}
+	if err := format.writer.Write(res, &out); err != nil {
+		return &writeError{fmt.Errorf("failed to write %s output: %w", format.name, err)}
+	}
+	content := out.String()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, false, fmt.Errorf("HTTP status %s", resp.Status)
+	if opts.json {
+		jr := newJSONResult(res, source)
+		jr.Markdown = fm + res.Markdown
+		jr.Section = section
+		if format.name == "text" {
+			jr.Text = content
+		}
+		if err := writeJSON(jr); err != nil {
+			return &writeError{fmt.Errorf("failed to write output: %w", err)}
+		}
+	}
+	if !opts.writesFile() {
+		if opts.stdout {
+			if err := writeStdout(content, opts.fragment); err != nil {
+				return &writeError{fmt.Errorf("failed to write output: %w", err)}
+			}
+		}
+		return nil
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	filename, err := outputName(res, opts)
 	if err != nil {
-		return nil, false, err
+		return &writeError{fmt.Errorf("failed to name output file: %w", err)}
+	}
+	if section != "" {
+		filename = sectionFilename(filename, section)
 	}
+	if opts.dedup != nil {
+		if first := opts.dedup.claim(res.Markdown, filename); first != "" && first != filename {
+			return writeDuplicate(filename, first, len(content), opts)
+		}
+	}
+	if opts.dryRun {
+		fmt.Fprintf(stderr, "%s (%d bytes)\n", filename, len(content))
+		return nil
+	}
+	opts.logger.Debug("Saving", "file", filename)
 
-	// Check if the content is already Markdown (skip HTML conversion)
-	contentType := resp.Header.Get("Content-Type")
-	isMarkdown := strings.HasSuffix(strings.ToLower(target.Path), ".md") ||
-		strings.Contains(contentType, "text/markdown") ||
-		strings.Contains(contentType, "text/x-markdown")
+	if err := writeFile(filename, content, opts.noClobber); err != nil {
+		if opts.noClobber && errors.Is(err, fs.ErrExist) {
+			if !opts.quiet {
+				fmt.Fprintf(stderr, "Skipping %s: file already exists\n", filename)
+			}
+			return nil
+		}
+		if opts.dedup != nil {
+			opts.dedup.release(res.Markdown)
+		}
+		return &writeError{fmt.Errorf("failed to write file: %w", err)}
+	}
 
-	return data, !isMarkdown, nil
+	opts.logger.Info("Wrote", "file", filename)
+	return nil
 }
 
-func convertToMarkdown(base *url.URL, html []byte) (string, error) {
-	converter := md.NewConverter(base.String(), true, nil)
-	return converter.ConvertString(string(html))
+// writeDuplicate handles a page whose Markdown was already written to first:
+// it is skipped, or with -dedup-symlink filename is made a link to first.
+func writeDuplicate(filename, first string, size int, opts *options) error {
+	if opts.dryRun {
+		fmt.Fprintf(stderr, "%s (%d bytes, duplicate of %s)\n", filename, size, first)
+		return nil
+	}
+	if !opts.dedupSymlink {
+		opts.logger.Info("Skipping duplicate page", "file", filename, "duplicate_of", first)
+		return nil
+	}
+	if err := writeSymlink(filename, first, opts.noClobber); err != nil {
+		if opts.noClobber && errors.Is(err, fs.ErrExist) {
+			if !opts.quiet {
+				fmt.Fprintf(stderr, "Skipping %s: file already exists\n", filename)
+			}
+			return nil
+		}
+		return &writeError{fmt.Errorf("failed to link duplicate file: %w", err)}
+	}
+	opts.logger.Info("Linked duplicate page", "file", filename, "duplicate_of", first)
+	return nil
+}
+
+// outputName returns the name of the file res is written to: opts.output,
+// or a name from -output-template, -out-tree, -use-server-name or the URL.
+func outputName(res url2md.Result, opts *options) (string, error) {
+	switch {
+	case opts.output != "":
+		return opts.output, nil
+	case opts.outputTemplate != nil:
+		return templateFilename(opts.outputTemplate, res.FinalURL, res.Title, res.FetchedAt, opts.ext)
+	case opts.outTree != "":
+		return treeFilename(opts.outTree, res.FinalURL, res.FetchedAt, opts.ext), nil
+	case opts.serverName && serverFilename(res.Header, opts.ext) != "":
+		return serverFilename(res.Header, opts.ext), nil
+	default:
+		return outputFilename(res.FinalURL, opts.ext), nil
+	}
 }
 
-func outputFilename(u *url.URL) string {
+// readURLs returns the URLs listed one per line in r, skipping blank lines
+// and lines starting with '#'.
+func readURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// outputFilename derives a file name from the host and path of u, ending in
+// ext (which includes the leading dot). Local file:// URLs are named after
+// the file itself.
+func outputFilename(u *url.URL, ext string) string {
+	if u.Scheme == "file" {
+		name := path.Base(u.Path)
+		if name = strings.TrimSuffix(name, path.Ext(name)); name == "" || name == "/" || name == "." {
+			name = "output"
+		}
+		return name + ext
+	}
+
 	base := u.Host + u.Path
 	base = strings.Trim(base, "/")
 
@@ -187,55 +722,367 @@ func outputFilename(u *url.URL) string {
 		base = "output"
 	}
 
-	return base + ".md"
+	return base + ext
 }
 
-func applyBrowserHeaders(req *http.Request, target *url.URL, includeNavigation bool) {
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Sec-CH-UA", "\"Not/A)Brand\";v=\"8\", \"Chromium\";v=\"126\", \"Google Chrome\";v=\"126\"")
-	req.Header.Set("Sec-CH-UA-Mobile", "?0")
-	req.Header.Set("Sec-CH-UA-Platform", "\"macOS\"")
-	if includeNavigation {
-		req.Header.Set("Sec-Fetch-Dest", "document")
-		req.Header.Set("Sec-Fetch-Mode", "navigate")
-		req.Header.Set("Sec-Fetch-Site", "none")
-		req.Header.Set("Sec-Fetch-User", "?1")
-		req.Header.Set("Upgrade-Insecure-Requests", "1")
+// serverFilename returns the filename suggested by the Content-Disposition
+// header in header, with its extension replaced by ext, or "" when there is
+// none. Only the last path element is kept and it is sanitized, so the
+// result always names a file in the current directory.
+func serverFilename(header http.Header, ext string) string {
+	disposition := header.Get("Content-Disposition")
+	if disposition == "" {
+		return ""
 	}
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+	name = sanitizeSegment(strings.TrimSuffix(name, path.Ext(name)))
+	if name == "" || name == "_" {
+		return ""
+	}
+	return name + ext
 }
 
-func fetchViaProxy(ctx context.Context, target *url.URL) ([]byte, error) {
-	proxyURL := "https://r.jina.ai/" + target.String()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proxyURL, nil)
-	if err != nil {
-		return nil, err
+var unsafeSegmentChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// treeFilename returns the path, under root, that -out-tree saves the page
+// fetched from u at fetchedAt to: root/YYYY/MM/host/path with the extension
+// ext. Each segment is sanitized on its own, so the result never leaves
+// root. Directory URLs are saved as index files and a query string is folded
+// into the last segment.
+func treeFilename(root string, u *url.URL, fetchedAt time.Time, ext string) string {
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+	dir := []string{root, fetchedAt.Format("2006"), fetchedAt.Format("01")}
+	if u == nil {
+		return filepath.Join(append(dir, "output"+ext)...)
 	}
-	req.Header.Set("User-Agent", "url2md-proxy/1.0 (+https://github.com)")
-	if key := strings.TrimSpace(os.Getenv("JINA_API_KEY")); key != "" {
-		req.Header.Set("Authorization", "Bearer "+key)
+	if u.Scheme == "file" {
+		return filepath.Join(append(dir, outputFilename(u, ext))...)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	segments := []string{u.Host}
+	segments = append(segments, strings.Split(u.Path, "/")...)
+	if strings.HasSuffix(u.Path, "/") || strings.Trim(u.Path, "/") == "" {
+		segments = append(segments, "index")
+	}
+	last := len(segments) - 1
+	for _, htmlExt := range []string{".html", ".htm"} {
+		if strings.HasSuffix(strings.ToLower(segments[last]), htmlExt) && len(segments[last]) > len(htmlExt) {
+			segments[last] = segments[last][:len(segments[last])-len(htmlExt)]
+			break
+		}
+	}
+	if u.RawQuery != "" {
+		segments[last] += "_" + u.RawQuery
+	}
+
+	for _, segment := range segments {
+		if segment = sanitizeSegment(segment); segment != "" {
+			dir = append(dir, segment)
+		}
+	}
+	dir[len(dir)-1] += ext
+	return filepath.Join(dir...)
+}
+
+// sectionFilename inserts "-" and the section ID before the extension of
+// filename, so that out.md becomes out-intro.md for the section "intro".
+func sectionFilename(filename, section string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + sanitizeSegment(section) + ext
+}
+
+// sanitizeSegment makes one path segment safe for any file system: runs of
+// other characters become "_", and segments made only of dots, such as
+// "..", are replaced.
+func sanitizeSegment(segment string) string {
+	segment = strings.Trim(unsafeSegmentChars.ReplaceAllString(segment, "_"), "_")
+	if strings.Trim(segment, ".") == "" && segment != "" {
+		return "_"
+	}
+	return segment
+}
+
+// writeFile writes markdown to filename, creating any missing parent
+// directories. With noClobber an existing file is left alone and an error
+// satisfying errors.Is(err, fs.ErrExist) is returned; the file is opened with
+// O_EXCL so concurrent writers cannot both create it.
+func writeFile(filename, markdown string, noClobber bool) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if noClobber {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(filename, flags, 0644)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
+	if _, err := f.WriteString(markdown); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, _ := io.ReadAll(resp.Body)
-		if len(data) > 0 {
-			detail := strings.TrimSpace(string(data))
-			if len(detail) > 256 {
-				detail = detail[:256] + "…"
-			}
-			return nil, fmt.Errorf("proxy request status %s: %s", resp.Status, detail)
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ", ") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// codeFenceFlag sets Options.CodeFence. Given as -code-fence=LANG it also
+// sets the default language of blocks without a language class.
+type codeFenceFlag url2md.Options
+
+func (c *codeFenceFlag) String() string {
+	if c == nil || !c.CodeFence {
+		return "false"
+	}
+	if c.CodeLanguage != "" {
+		return c.CodeLanguage
+	}
+	return "true"
+}
+
+func (c *codeFenceFlag) Set(value string) error {
+	switch value {
+	case "true":
+		c.CodeFence = true
+	case "false":
+		c.CodeFence, c.CodeLanguage = false, ""
+	default:
+		if strings.ContainsAny(value, " \t`") {
+			return fmt.Errorf("invalid language %q", value)
 		}
-		return nil, fmt.Errorf("proxy request status %s", resp.Status)
+		c.CodeFence, c.CodeLanguage = true, value
 	}
+	return nil
+}
+
+func (c *codeFenceFlag) IsBoolFlag() bool { return true }
+
+// byteSize is a flag holding a size in bytes. It accepts a plain number or
+// one with a KB, MB or GB suffix (powers of 1024).
+type byteSize int64
+
+func (b *byteSize) String() string { return strconv.FormatInt(int64(*b), 10) }
+
+func (b *byteSize) Set(value string) error {
+	number := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return fmt.Errorf("size %q is too large", value)
+	}
+	*b = byteSize(n * multiplier)
+	return nil
+}
+
+// rateFlag is a flag holding a request rate, such as "2/s", "30/m" or
+// "1/h", stored as the interval between two requests. A bare number is per
+// second.
+type rateFlag time.Duration
+
+func (r *rateFlag) String() string {
+	if *r == 0 {
+		return ""
+	}
+	return time.Duration(*r).String()
+}
+
+func (r *rateFlag) Set(value string) error {
+	count, unit, ok := strings.Cut(strings.TrimSpace(value), "/")
+	per := time.Second
+	if ok {
+		switch strings.TrimSpace(unit) {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return fmt.Errorf("invalid rate %q: unit must be s, m or h", value)
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return fmt.Errorf("invalid rate %q, expected e.g. 2/s", value)
+	}
+	*r = rateFlag(float64(per) / n)
+	return nil
+}
+
+// headerFlags collects repeated -H "Name: Value" flags.
+type headerFlags http.Header
+
+func (h *headerFlags) String() string {
+	var parts []string
+	for name, values := range *h {
+		for _, v := range values {
+			parts = append(parts, name+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("malformed header %q, expected \"Name: Value\"", value)
+	}
+	if *h == nil {
+		*h = headerFlags{}
+	}
+	http.Header(*h).Add(name, strings.TrimSpace(v))
+	return nil
+}
 
-	return io.ReadAll(resp.Body)
+// jsonResult is the object printed for each URL in -json mode.
+type jsonResult struct {
+	URL       string    `json:"url"`
+	FinalURL  string    `json:"finalUrl"`
+	Title     string    `json:"title"`
+	Markdown  string    `json:"markdown"`
+	Text      string    `json:"text,omitempty"`
+	Section   string    `json:"section,omitempty"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	ViaProxy  bool      `json:"viaProxy"`
+
+	// The counts of the converted Markdown, without front matter.
+	WordCount  int `json:"wordCount"`
+	LinkCount  int `json:"linkCount"`
+	ImageCount int `json:"imageCount"`
+	ByteSize   int `json:"byteSize"`
+}
+
+// newJSONResult returns the -json object for res, requested as source. An
+// empty source stands for the final URL.
+func newJSONResult(res url2md.Result, source string) jsonResult {
+	finalURL := ""
+	if res.FinalURL != nil {
+		finalURL = res.FinalURL.String()
+	}
+	if source == "" {
+		source = finalURL
+	}
+	counts := countMarkdown(res.Markdown)
+	return jsonResult{
+		URL:        source,
+		FinalURL:   finalURL,
+		Title:      res.Title,
+		Markdown:   res.Markdown,
+		FetchedAt:  res.FetchedAt.UTC(),
+		ViaProxy:   res.ViaProxy,
+		WordCount:  counts.words,
+		LinkCount:  counts.links,
+		ImageCount: counts.images,
+		ByteSize:   len(res.Markdown),
+	}
+}
+
+// jsonError is the object printed instead of the error message when a URL
+// fails in -json mode, so that consumers read failures from the same JSON
+// Lines as the results. Code is the exit code the error maps to, which is
+// also the exit code of a single-URL run.
+type jsonError struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+	URL   string `json:"url"`
+}
+
+// reportError prints the error of source, which exits with code in a
+// single-URL run: as a jsonError on stdout with -json, or on stderr.
+func reportError(err error, source string, code int, opts *options) {
+	if !opts.json {
+		fmt.Fprintln(stderr, err)
+		return
+	}
+	if werr := writeJSON(jsonError{Error: err.Error(), Code: code, URL: source}); werr != nil {
+		fmt.Fprintln(stderr, err)
+	}
+}
+
+// reportFlagError prints the error parseFlags returned for fs, along with
+// output, what the flag package wrote about it. With -json, when it was
+// parsed before the error, only a jsonError is printed, on stdout.
+func reportFlagError(err error, fs *flag.FlagSet, output []byte) {
+	if f := fs.Lookup("json"); f != nil && f.Value.String() == "true" {
+		reportError(err, "", exitUsage, &options{json: true})
+		return
+	}
+	if len(output) > 0 {
+		// The flag package already printed the error and the usage.
+		stderr.Write(output)
+		return
+	}
+	fmt.Fprintln(stderr, err)
+}
+
+// writeJSON prints v as a single line, so batch runs produce JSON Lines.
+func writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
+
+// stdoutMu keeps documents written by concurrent workers from interleaving.
+var stdoutMu sync.Mutex
+
+// stdoutFragments counts the -fragment documents written to stdout. It is
+// guarded by stdoutMu.
+var stdoutFragments int
+
+// writeStdout prints markdown, ending it with a newline unless it is a
+// -fragment. Fragments after the first are separated by a blank line
+// instead.
+func writeStdout(markdown string, fragment bool) error {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	if fragment {
+		if stdoutFragments > 0 {
+			markdown = "\n\n" + markdown
+		}
+		stdoutFragments++
+		_, err := io.WriteString(os.Stdout, markdown)
+		return err
+	}
+	if _, err := io.WriteString(os.Stdout, markdown); err != nil {
+		return err
+	}
+	if !strings.HasSuffix(markdown, "\n") {
+		_, err := io.WriteString(os.Stdout, "\n")
+		return err
+	}
+	return nil
 }
@@ -1,40 +1,528 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-to-markdown/pkg/url2md"
+)
 
 func TestOutputFilename(t *testing.T) {
 	cases := map[string]string{
-		"https://springdoc.org":        "springdoc_org.md",
-		"https://example.com/docs":     "example_com_docs.md",
-		"https://example.com/docs/":    "example_com_docs.md",
-		"https://docs.example.com":     "docs_example_com.md",
-		"https://example.com/a/b?x=1":  "example_com_a_b.md",
-		"https://example.com/a-b_c/d/": "example_com_a_b_c_d.md",
+		"https://springdoc.org":        "springdoc_org",
+		"https://example.com/docs":     "example_com_docs",
+		"https://example.com/docs/":    "example_com_docs",
+		"https://docs.example.com":     "docs_example_com",
+		"https://example.com/a/b?x=1":  "example_com_a_b",
+		"https://example.com/a-b_c/d/": "example_com_a_b_c_d",
+		"https://example.com/notes.md": "example_com_notes_md",
+	}
+
+	for _, ext := range []string{".md", ".markdown", ".txt"} {
+		for raw, slug := range cases {
+			u, err := url2md.ParseURL(raw)
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned error: %v", raw, err)
+			}
+
+			expected := slug + ext
+			if got := outputFilename(u, ext); got != expected {
+				t.Fatalf("outputFilename(%q, %q) = %q, expected %q", raw, ext, got, expected)
+			}
+		}
+	}
+}
+
+func TestTreeFilename(t *testing.T) {
+	fetchedAt := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	cases := map[string]string{
+		"https://example.com":                  "example.com/index.md",
+		"https://example.com/docs/intro":       "example.com/docs/intro.md",
+		"https://example.com/docs/":            "example.com/docs/index.md",
+		"https://example.com/a/page.html?x=1":  "example.com/a/page_x_1.md",
+		"http://localhost:8080/v1.2/notes":     "localhost_8080/v1.2/notes.md",
+		"https://example.com/../../etc/passwd": "example.com/_/_/etc/passwd.md",
+		"https://example.com/a%2F..%5Cb/c d":   "example.com/a/.._b/c_d.md",
+	}
+	root := filepath.Join("out", "archive")
+	for raw, rel := range cases {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) returned error: %v", raw, err)
+		}
+		want := filepath.Join(root, "2024", "06", filepath.FromSlash(rel))
+		if got := treeFilename(root, u, fetchedAt, ".md"); got != want {
+			t.Fatalf("treeFilename(%q) = %q, expected %q", raw, got, want)
+		}
+	}
+}
+
+func TestServerFilename(t *testing.T) {
+	cases := map[string]string{
+		"":       "",
+		"inline": "",
+		`attachment; filename="Annual Report.pdf"`:    "Annual_Report.md",
+		"attachment; filename*=UTF-8''caf%C3%A9.html": "caf.md",
+		`attachment; filename="../../etc/passwd"`:     "passwd.md",
+		`attachment; filename="..\\..\\boot.ini"`:     "boot.md",
+		`attachment; filename=".."`:                   "",
+	}
+	for disposition, want := range cases {
+		header := http.Header{}
+		if disposition != "" {
+			header.Set("Content-Disposition", disposition)
+		}
+		if got := serverFilename(header, ".md"); got != want {
+			t.Fatalf("serverFilename(%q) = %q, expected %q", disposition, got, want)
+		}
+	}
+}
+
+func TestSectionFilename(t *testing.T) {
+	cases := map[[2]string]string{
+		{"out.md", "intro"}:                     "out-intro.md",
+		{"example_com_docs.md", "install-1"}:    "example_com_docs-install-1.md",
+		{"site/a/b.md", "../etc"}:               "site/a/b-.._etc.md",
+		{"notes", "Getting Started"}:            "notes-Getting_Started",
+		{"example_com_ref.txt", "api.v2/users"}: "example_com_ref-api.v2_users.txt",
+	}
+	for in, want := range cases {
+		if got := sectionFilename(in[0], in[1]); got != want {
+			t.Fatalf("sectionFilename(%q, %q) = %q, expected %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestWriteResultSections(t *testing.T) {
+	dir := t.TempDir()
+	res := url2md.Result{
+		Markdown: "whole page",
+		Sections: []url2md.Section{{ID: "intro", Markdown: "# Intro\n"}, {ID: "usage", Markdown: "# Usage\n"}},
+	}
+	opts := &options{output: filepath.Join(dir, "ref.md"), logger: testLogger(t)}
+	if err := writeResult(res, "https://example.com/ref", opts); err != nil {
+		t.Fatalf("writeResult returned error: %v", err)
+	}
+	for name, want := range map[string]string{"ref-intro.md": "# Intro\n", "ref-usage.md": "# Usage\n"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("section file not written: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s = %q, expected %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(opts.output); err == nil {
+		t.Fatalf("%s written, expected only the section files", opts.output)
+	}
+}
+
+func TestWriteResultKeepHTML(t *testing.T) {
+	dir := t.TempDir()
+	page, _ := url.Parse("https://example.com/page")
+	res := url2md.Result{FinalURL: page, Markdown: "# Title\n", HTML: []byte("<h1>Title</h1>")}
+	opts := &options{output: filepath.Join(dir, "page.md"), keepHTML: true, logger: testLogger(t)}
+	if err := writeResult(res, "https://example.com/page", opts); err != nil {
+		t.Fatalf("writeResult returned error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "page.html"))
+	if err != nil {
+		t.Fatalf("HTML file not written: %v", err)
+	}
+	if string(got) != "<h1>Title</h1>" {
+		t.Fatalf("page.html = %q, expected %q", got, "<h1>Title</h1>")
+	}
+
+	res.HTML, res.ViaProxy = nil, true
+	opts.output = filepath.Join(dir, "proxied.md")
+	if err := writeResult(res, "https://example.com/proxied", opts); err != nil {
+		t.Fatalf("writeResult returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "proxied.html")); err == nil {
+		t.Fatalf("proxied.html written for a Markdown result")
 	}
+}
+
+func TestWriteResultFragment(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "part.md")
+	res := url2md.Result{Markdown: "\n\n## Usage\n\nRun it.\n\n\n"}
+	opts := &options{output: filename, fragment: true, tidy: true, logger: testLogger(t)}
+	if err := writeResult(res, "https://example.com/usage", opts); err != nil {
+		t.Fatalf("writeResult returned error: %v", err)
+	}
+	if got, _ := os.ReadFile(filename); string(got) != "## Usage\n\nRun it." {
+		t.Fatalf("part.md = %q, expected the trimmed fragment", got)
+	}
+}
 
-	for raw, expected := range cases {
-		u, err := parseURL(raw)
+func TestWriteResultStdout(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment bool
+		pages    []string
+		want     string
+	}{
+		{"newline added", false, []string{"# One"}, "# One\n"},
+		{"newline kept", false, []string{"# One\n"}, "# One\n"},
+		{"several URLs", false, []string{"# One", "# Two\n"}, "# One\n# Two\n"},
+		{"fragment", true, []string{"\n# One\n\n"}, "# One"},
+		{"several fragments", true, []string{"# One\n", "# Two", "\n# Three\n"}, "# One\n\n# Two\n\n# Three"},
+	}
+	for _, tt := range tests {
+		stdoutFragments = 0
+		opts := &options{stdout: true, fragment: tt.fragment, logger: testLogger(t)}
+		var err error
+		got := captureStdout(t, func() {
+			for i, page := range tt.pages {
+				if err == nil {
+					err = writeResult(url2md.Result{Markdown: page}, fmt.Sprintf("https://example.com/%d", i), opts)
+				}
+			}
+		})
 		if err != nil {
-			t.Fatalf("parseURL(%q) returned error: %v", raw, err)
+			t.Fatalf("%s: writeResult returned error: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%s: stdout = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+	stdoutFragments = 0
+}
+
+func TestWriteResultMetadataJSON(t *testing.T) {
+	dir := t.TempDir()
+	page, _ := url.Parse("https://example.com/blog/release-2")
+	res := url2md.Result{
+		FinalURL: page,
+		Markdown: "# Release 2.0\n",
+		Title:    "Release 2.0 | Example Blog",
+		Metadata: url2md.Metadata{Description: "Faster conversions.", Author: "Ada Lovelace"},
+	}
+	opts := &options{output: filepath.Join(dir, "release-2.md"), metadata: metadataJSON, logger: testLogger(t)}
+	if err := writeResult(res, "https://example.com/blog/release-2", opts); err != nil {
+		t.Fatalf("writeResult returned error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "release-2.meta.json"))
+	if err != nil {
+		t.Fatalf("metadata file not written: %v", err)
+	}
+	want := `{
+  "url": "https://example.com/blog/release-2",
+  "title": "Release 2.0 | Example Blog",
+  "description": "Faster conversions.",
+  "author": "Ada Lovelace"
+}
+`
+	if string(got) != want {
+		t.Fatalf("release-2.meta.json = %q, expected %q", got, want)
+	}
+}
+
+func TestHTMLFilename(t *testing.T) {
+	cases := map[string]string{
+		"page.md":          "page.html",
+		"site/2024/a.txt":  "site/2024/a.html",
+		"notes":            "notes.html",
+		"example_com.html": "example_com.source.html",
+		"dir.v2/readme.MD": "dir.v2/readme.html",
+	}
+	for in, want := range cases {
+		if got := htmlFilename(in); got != want {
+			t.Fatalf("htmlFilename(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteFileCreatesParentDirs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "docs", "nested", "page.md")
+	if err := writeFile(filename, "# Title\n", false); err != nil {
+		t.Fatalf("writeFile returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading written file: %v", err)
+	}
+	if string(data) != "# Title\n" {
+		t.Fatalf("content = %q, expected %q", data, "# Title\n")
+	}
+}
+
+func TestWriteFileNoClobber(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "page.md")
+	if err := writeFile(filename, "edited by hand\n", true); err != nil {
+		t.Fatalf("writeFile returned error: %v", err)
+	}
+	if err := writeFile(filename, "# Title\n", true); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("writeFile error = %v, expected fs.ErrExist", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "edited by hand\n" {
+		t.Fatalf("content = %q, expected the original file to be kept", data)
+	}
+
+	if err := writeFile(filename, "# Title\n", false); err != nil {
+		t.Fatalf("writeFile returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "# Title\n" {
+		t.Fatalf("content = %q, expected the file to be overwritten", data)
+	}
+}
+
+func TestByteSizeSet(t *testing.T) {
+	cases := map[string]int64{"0": 0, "512": 512, "500KB": 500 << 10, "20mb": 20 << 20, "1 GB": 1 << 30, "64B": 64}
+	for value, want := range cases {
+		var b byteSize
+		if err := b.Set(value); err != nil {
+			t.Fatalf("Set(%q) returned error: %v", value, err)
+		}
+		if int64(b) != want {
+			t.Fatalf("Set(%q) = %d, expected %d", value, b, want)
+		}
+	}
+	for _, value := range []string{"", "MB", "-1", "1.5MB", "10TB", "9999999999GB", "9223372036854775807KB"} {
+		var b byteSize
+		if err := b.Set(value); err == nil {
+			t.Fatalf("Set(%q) returned no error", value)
+		}
+	}
+}
+
+func TestRateFlagSet(t *testing.T) {
+	cases := map[string]time.Duration{"2/s": 500 * time.Millisecond, "30/m": 2 * time.Second, "1/h": time.Hour, "0.5/s": 2 * time.Second, "4": 250 * time.Millisecond}
+	for value, want := range cases {
+		var r rateFlag
+		if err := r.Set(value); err != nil {
+			t.Fatalf("Set(%q) returned error: %v", value, err)
+		}
+		if time.Duration(r) != want {
+			t.Fatalf("Set(%q) = %s, expected %s", value, time.Duration(r), want)
+		}
+	}
+	for _, value := range []string{"", "0/s", "-1/s", "2/d", "fast"} {
+		var r rateFlag
+		if err := r.Set(value); err == nil {
+			t.Fatalf("Set(%q) returned no error", value)
+		}
+	}
+}
+
+func TestCodeFenceFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		on   bool
+		lang string
+	}{
+		{nil, false, ""},
+		{[]string{"-code-fence"}, true, ""},
+		{[]string{"-code-fence=go"}, true, "go"},
+		{[]string{"-code-fence=go", "-code-fence=false"}, false, ""},
+	}
+	for _, tt := range tests {
+		var opts url2md.Options
+		flags := flag.NewFlagSet("url2md", flag.ContinueOnError)
+		flags.Var((*codeFenceFlag)(&opts), "code-fence", "")
+		if err := flags.Parse(append(tt.args, "https://example.com")); err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.args, err)
 		}
+		if opts.CodeFence != tt.on || opts.CodeLanguage != tt.lang || flags.NArg() != 1 {
+			t.Fatalf("Parse(%q) = %v %q, expected %v %q", tt.args, opts.CodeFence, opts.CodeLanguage, tt.on, tt.lang)
+		}
+	}
+}
+
+func TestReadURLsSkipsBlankAndComments(t *testing.T) {
+	input := "https://example.com/a\n\n# a comment\n  https://example.com/b  \n   # indented comment\n"
+	got, err := readURLs(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readURLs returned error: %v", err)
+	}
+
+	expected := []string{"https://example.com/a", "https://example.com/b"}
+	if len(got) != len(expected) {
+		t.Fatalf("readURLs = %q, expected %q", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("readURLs = %q, expected %q", got, expected)
+		}
+	}
+}
+
+func TestHeaderFlagsSet(t *testing.T) {
+	var h headerFlags
+	for _, v := range []string{"Cookie: a=1", "authorization:Bearer x", "Cookie: b=2"} {
+		if err := h.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned error: %v", v, err)
+		}
+	}
+
+	if got := http.Header(h).Values("Cookie"); len(got) != 2 || got[0] != "a=1" || got[1] != "b=2" {
+		t.Fatalf("Cookie = %q, expected [a=1 b=2]", got)
+	}
+	if got := http.Header(h).Get("Authorization"); got != "Bearer x" {
+		t.Fatalf("Authorization = %q, expected %q", got, "Bearer x")
+	}
 
-		if got := outputFilename(u); got != expected {
-			t.Fatalf("outputFilename(%q) = %q, expected %q", raw, got, expected)
+	for _, v := range []string{"no colon", ": value"} {
+		if err := h.Set(v); err == nil {
+			t.Fatalf("Set(%q) returned nil error, expected a parse error", v)
 		}
 	}
 }
 
-func TestParseURLAddsScheme(t *testing.T) {
-	u, err := parseURL("example.com/path")
+func TestProcessURLDryRunWritesNothing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<h1>Title</h1><img src="/logo.png">`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "page.md")
+	opts := &options{output: output, timeout: 5 * time.Second, dryRun: true, logger: testLogger(t)}
+	opts.convert = url2md.Options{MaxRedirects: 10, NoProxy: true, Images: url2md.ImagesDownload}
+
+	target, _ := url.Parse(srv.URL + "/page")
+	res, err := processURL(context.Background(), target, opts)
 	if err != nil {
-		t.Fatalf("parseURL returned error: %v", err)
+		t.Fatalf("processURL returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "# Title") {
+		t.Fatalf("markdown = %q, expected the converted page", res.Markdown)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("dry run wrote %d entries to %s", len(entries), dir)
+	}
+
+	srv.Close()
+	if _, err := processURL(context.Background(), target, opts); err == nil {
+		t.Fatalf("processURL on a closed server returned no error")
+	}
+}
+
+func TestProcessURLCachesWrittenPagesOnly(t *testing.T) {
+	var conditional int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "<h1>Title</h1>")
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL + "/page")
+
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	run := func(output string, dryRun bool) error {
+		cache, err := url2md.OpenCache(cacheDir)
+		if err != nil {
+			t.Fatalf("OpenCache returned error: %v", err)
+		}
+		opts := &options{output: output, timeout: 5 * time.Second, dryRun: dryRun, logger: testLogger(t)}
+		opts.convert = url2md.Options{MaxRedirects: 10, NoProxy: true, NoWarmup: true, Cache: cache}
+		_, err = processURL(context.Background(), target, opts)
+		saveCache(opts)
+		return err
+	}
+
+	// Neither a dry run nor a failed write records the page.
+	if err := run(filepath.Join(dir, "page.md"), true); err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+	if err := run(filepath.Join(dir, "file", "page.md"), false); err == nil {
+		t.Fatalf("processURL writing below a file returned no error")
+	}
+	if conditional != 0 {
+		t.Fatalf("%d conditional requests before the page was written, expected none", conditional)
+	}
+
+	if err := run(filepath.Join(dir, "page.md"), false); err != nil {
+		t.Fatalf("processURL returned error: %v", err)
+	}
+	if err := run(filepath.Join(dir, "page.md"), false); err != nil {
+		t.Fatalf("processURL of an unmodified page returned error: %v", err)
+	}
+	if conditional != 1 {
+		t.Fatalf("%d conditional requests after the page was written, expected 1", conditional)
+	}
+}
+
+func TestRandomDelay(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := randomDelay(10*time.Millisecond, 20*time.Millisecond); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("randomDelay = %s, expected between 10ms and 20ms", d)
+		}
+	}
+	if d := randomDelay(5*time.Millisecond, 5*time.Millisecond); d != 5*time.Millisecond {
+		t.Fatalf("randomDelay = %s, expected 5ms", d)
 	}
+}
 
-	if u.Scheme != "https" {
-		t.Fatalf("scheme = %q, expected https", u.Scheme)
+func TestProcessAllMinDelay(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<h1>Title</h1>")
+		if r.Header.Get("Sec-Fetch-Mode") == "navigate" {
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+		}
+	}))
+	defer srv.Close()
+
+	opts := &options{concurrency: 3, timeout: 5 * time.Second, dryRun: true, minDelay: 40 * time.Millisecond, maxDelay: 60 * time.Millisecond, logger: testLogger(t)}
+	opts.convert = url2md.Options{MaxRedirects: 10, NoProxy: true, NoWarmup: true}
+	urls := []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+	if failed := processAll(context.Background(), urls, opts, nil); failed != 0 {
+		t.Fatalf("processAll failed %d URLs", failed)
+	}
+	if len(times) != 3 {
+		t.Fatalf("server saw %d page requests, expected 3", len(times))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 35*time.Millisecond {
+			t.Fatalf("requests %d and %d were %s apart, expected at least -min-delay", i-1, i, gap)
+		}
+	}
+}
+
+func TestAllowEmpty(t *testing.T) {
+	empty := fmt.Errorf("%w: only 3 characters of Markdown", url2md.ErrEmptyContent)
+	other := errors.New("boom")
+
+	opts := &options{quiet: true}
+	if err := allowEmpty(empty, "https://example.com", opts); err != nil {
+		t.Fatalf("allowEmpty = %v, expected the empty result to be accepted", err)
+	}
+	if err := allowEmpty(other, "https://example.com", opts); err != other {
+		t.Fatalf("allowEmpty = %v, expected other errors unchanged", err)
 	}
 
-	if u.Host != "example.com" {
-		t.Fatalf("host = %q, expected example.com", u.Host)
+	opts.failOnEmpty = true
+	if err := allowEmpty(empty, "https://example.com", opts); !errors.Is(err, url2md.ErrEmptyContent) {
+		t.Fatalf("allowEmpty with -fail-on-empty = %v, expected ErrEmptyContent", err)
 	}
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// stderr is where the command prints its messages and logs. It behaves as
+// os.Stderr until a progress status line is shown.
+var stderr = &statusWriter{w: os.Stderr}
+
+// eraseLine moves the cursor back to the start of the line and clears it.
+const eraseLine = "\r\x1b[K"
+
+// statusWriter writes to w below a status line kept at the bottom of the
+// terminal: every write first erases the line and then draws it again, so
+// that log lines and messages never run into it.
+type statusWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	status string // the line on screen, "" when there is none
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == "" {
+		return s.w.Write(p)
+	}
+	io.WriteString(s.w, eraseLine)
+	n, err := s.w.Write(p)
+	io.WriteString(s.w, s.status)
+	return n, err
+}
+
+// setStatus replaces the status line with line. An empty line removes it.
+func (s *statusWriter) setStatus(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if line == s.status {
+		return
+	}
+	io.WriteString(s.w, eraseLine+line)
+	s.status = line
+}
+
+// progressInterval is how often progress is reported when stderr is not a
+// terminal.
+const progressInterval = 10 * time.Second
+
+// progress reports how far a batch run or a crawl has got: on a terminal as
+// a status line updated in place, elsewhere as a line every interval. Its
+// methods are safe for concurrent use and do nothing on a nil *progress.
+type progress struct {
+	out      *statusWriter
+	tty      bool
+	width    int
+	interval time.Duration
+
+	mu       sync.Mutex
+	total    int // grows as a crawl discovers pages
+	done     int
+	ok       int
+	failed   int
+	host     string // host of the URL being fetched last
+	reported time.Time
+}
+
+// newProgress returns the progress reporter for a run writing to out, or
+// nil with -quiet. The status line is only used when stderr is a terminal
+// and stdout does not print the results on the same screen.
+func newProgress(out *statusWriter, opts *options) *progress {
+	if opts.quiet {
+		return nil
+	}
+	p := &progress{out: out, interval: progressInterval, reported: time.Now(), width: 80}
+	fd := int(os.Stderr.Fd())
+	printsResults := opts.stdout || (opts.json && opts.output == "")
+	if term.IsTerminal(fd) && !(printsResults && term.IsTerminal(int(os.Stdout.Fd()))) {
+		p.tty = true
+		if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+			p.width = width
+		}
+	}
+	return p
+}
+
+// add counts n more URLs to process.
+func (p *progress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+	p.draw()
+}
+
+// begin records that target is being fetched.
+func (p *progress) begin(target *url.URL) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.host = target.Host
+	p.draw()
+}
+
+// end records the outcome of a URL; err is its error, if any.
+func (p *progress) end(err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if err != nil {
+		p.failed++
+	} else {
+		p.ok++
+	}
+	if p.tty {
+		p.draw()
+		return
+	}
+	if time.Since(p.reported) >= p.interval && p.done < p.total {
+		p.reported = time.Now()
+		fmt.Fprintf(p.out, "Progress: %s\n", p.counts())
+	}
+}
+
+// stop removes the status line, before the summary is printed.
+func (p *progress) stop() {
+	if p == nil || !p.tty {
+		return
+	}
+	p.out.setStatus("")
+}
+
+// draw shows the current state on the status line. p.mu must be held.
+func (p *progress) draw() {
+	if !p.tty {
+		return
+	}
+	line := p.counts()
+	if p.host != "" {
+		line += " · " + p.host
+	}
+	if utf8.RuneCountInString(line) >= p.width {
+		// Below two columns only the ellipsis is left.
+		runes := []rune(line)
+		line = string(runes[:max(p.width-2, 0)]) + "…"
+	}
+	p.out.setStatus(line)
+}
+
+// counts formats the numbers of the run, as in "12/30, 10 ok, 2 failed".
+// p.mu must be held.
+func (p *progress) counts() string {
+	return fmt.Sprintf("%d/%d, %d ok, %d failed", p.done, p.total, p.ok, p.failed)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStatusWriterKeepsStatusBelowOutput(t *testing.T) {
+	var buf strings.Builder
+	w := &statusWriter{w: &buf}
+	fmt.Fprintln(w, "before")
+	w.setStatus("1/2")
+	fmt.Fprintln(w, "log line")
+	w.setStatus("")
+
+	want := "before\n" + eraseLine + "1/2" + eraseLine + "log line\n1/2" + eraseLine
+	if got := buf.String(); got != want {
+		t.Fatalf("output = %q, expected %q", got, want)
+	}
+}
+
+func TestProgressStatusLine(t *testing.T) {
+	var buf strings.Builder
+	w := &statusWriter{w: &buf}
+	p := &progress{out: w, tty: true, width: 80}
+	p.add(3)
+	p.begin(&url.URL{Scheme: "https", Host: "example.com"})
+	p.end(nil)
+	p.begin(&url.URL{Scheme: "https", Host: "example.org"})
+	p.end(errors.New("boom"))
+
+	if got, want := w.status, "2/3, 1 ok, 1 failed · example.org"; got != want {
+		t.Fatalf("status = %q, expected %q", got, want)
+	}
+	p.width = 10
+	p.draw()
+	if got, want := w.status, "2/3, 1 o…"; got != want {
+		t.Fatalf("truncated status = %q, expected %q", got, want)
+	}
+	for _, width := range []int{2, 1, 0} {
+		p.width = width
+		p.draw()
+		if got, want := w.status, "…"; got != want {
+			t.Fatalf("status at width %d = %q, expected %q", width, got, want)
+		}
+	}
+	p.stop()
+	if w.status != "" {
+		t.Fatalf("status after stop = %q, expected none", w.status)
+	}
+}
+
+func TestProgressLogsWithoutTerminal(t *testing.T) {
+	var buf strings.Builder
+	p := &progress{out: &statusWriter{w: &buf}}
+	p.add(3)
+	p.end(nil)
+	p.end(nil)
+	p.end(nil)
+
+	// With no interval every URL is reported, except the last one that
+	// the summary covers.
+	want := "Progress: 1/3, 1 ok, 0 failed\nProgress: 2/3, 2 ok, 0 failed\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("output = %q, expected %q", got, want)
+	}
+
+	var nilProgress *progress
+	nilProgress.add(1)
+	nilProgress.end(nil)
+	nilProgress.stop()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+// batchStats counts the outcome of the conversions in a batch run. It is
+// safe for concurrent use by the processAll workers.
+type batchStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	ok      int
+	failed  int
+	proxied int
+	bytes   int64
+}
+
+func newBatchStats() *batchStats {
+	return &batchStats{start: time.Now()}
+}
+
+// record adds the outcome of one URL. res is ignored when err is non-nil.
+func (s *batchStats) record(res url2md.Result, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed++
+		return
+	}
+	s.ok++
+	if res.ViaProxy {
+		s.proxied++
+	}
+	s.bytes += int64(len(res.Markdown))
+}
+
+// total returns the number of URLs recorded so far.
+func (s *batchStats) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ok + s.failed
+}
+
+// summary formats the counts for the end-of-run report.
+func (s *batchStats) summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start).Round(time.Millisecond)
+	return fmt.Sprintf("Summary: %d ok, %d failed, %d via proxy, %d bytes in %s", s.ok, s.failed, s.proxied, s.bytes, elapsed)
+}
+
+// markdownCounts are the counts of a converted page reported by -json.
+type markdownCounts struct {
+	words  int
+	links  int
+	images int
+}
+
+// countMarkdown counts the words, links and images of markdown in a single
+// pass. Words are runs of letters and digits, apostrophes included, outside
+// link destinations and reference definitions. Links are inline, reference
+// and autolinks; an image inside a link counts as both.
+func countMarkdown(markdown string) markdownCounts {
+	var counts markdownCounts
+	var open []bool // one per unclosed "[", whether it starts an image
+	inWord, lineStart := false, true
+	var skipTo byte // the byte ending the destination or definition being skipped
+	for i := 0; i < len(markdown); {
+		r, size := utf8.DecodeRuneInString(markdown[i:])
+		if skipTo != 0 {
+			if markdown[i] == skipTo {
+				skipTo = 0
+			}
+			lineStart = markdown[i] == '\n'
+			i += size
+			continue
+		}
+		if lineStart && r == '[' && isLinkDefinition(markdown[i:]) {
+			skipTo = '\n'
+			continue
+		}
+		lineStart = r == '\n'
+
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if letter && !inWord {
+			counts.words++
+		}
+		inWord = letter || inWord && (r == '\'' || r == '’')
+
+		rest := markdown[i+size:]
+		switch {
+		case r == '\\' && rest != "":
+			// An escaped character is text, never markup.
+			_, next := utf8.DecodeRuneInString(rest)
+			size += next
+		case r == '!' && strings.HasPrefix(rest, "["):
+			open = append(open, true)
+			size++
+		case r == '[':
+			open = append(open, false)
+		case r == ']' && len(open) > 0:
+			image := open[len(open)-1]
+			open = open[:len(open)-1]
+			if rest == "" || (rest[0] != '(' && rest[0] != '[') {
+				break
+			}
+			if image {
+				counts.images++
+			} else {
+				counts.links++
+			}
+			skipTo = ')'
+			if rest[0] == '[' {
+				skipTo = ']'
+			}
+			size++
+		case r == '<' && (strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")):
+			counts.links++
+			skipTo = '>'
+		}
+		i += size
+	}
+	return counts
+}
+
+// isLinkDefinition reports whether line starts with a reference definition
+// such as "[1]: https://example.com".
+func isLinkDefinition(line string) bool {
+	end := strings.IndexByte(line, ']')
+	return end > 1 && strings.HasPrefix(line[end:], "]:") && !strings.Contains(line[:end], "\n")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestBatchStats(t *testing.T) {
+	stats := newBatchStats()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			switch i % 3 {
+			case 0:
+				stats.record(url2md.Result{}, errors.New("boom"))
+			case 1:
+				stats.record(url2md.Result{Markdown: "# Hi", ViaProxy: true}, nil)
+			default:
+				stats.record(url2md.Result{Markdown: "# Hello"}, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := stats.total(); got != 10 {
+		t.Fatalf("total = %d, expected 10", got)
+	}
+	if got, want := stats.summary(), "Summary: 6 ok, 4 failed, 3 via proxy, 33 bytes in "; !strings.HasPrefix(got, want) {
+		t.Fatalf("summary = %q, expected prefix %q", got, want)
+	}
+}
+
+func TestCountMarkdown(t *testing.T) {
+	markdown, err := os.ReadFile(filepath.Join("testdata", "stats.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := countMarkdown(string(markdown))
+	if want := (markdownCounts{words: 17, links: 4, images: 2}); got != want {
+		t.Fatalf("countMarkdown = %+v, expected %+v", got, want)
+	}
+
+	res := url2md.Result{Markdown: string(markdown)}
+	jr := newJSONResult(res, "https://example.com/start")
+	if jr.WordCount != 17 || jr.LinkCount != 4 || jr.ImageCount != 2 || jr.ByteSize != len(markdown) {
+		t.Fatalf("newJSONResult counts = %d words, %d links, %d images, %d bytes", jr.WordCount, jr.LinkCount, jr.ImageCount, jr.ByteSize)
+	}
+}
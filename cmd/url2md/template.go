@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFields are the fields available to -output-template. Every one is
+// sanitized with sanitizeSegment, so only the literal text of the template
+// can add directories to the file name.
+type templateFields struct {
+	// Host is the host of the final URL, with its port.
+	Host string
+	// Path is the path of the final URL without the leading and trailing
+	// slashes.
+	Path string
+	// Title is the page title, or "untitled".
+	Title string
+	// Date is the day the page was fetched, as YYYY-MM-DD.
+	Date string
+	// Slug is the name outputFilename would give the page, without Ext.
+	Slug string
+	// Ext is the extension of the output format, with its dot.
+	Ext string
+}
+
+// parseOutputTemplate parses the -output-template text and checks it by
+// rendering it once with sample values.
+func parseOutputTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output-template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := templateFields{Host: "example.com", Path: "docs", Title: "Title", Date: "2006-01-02", Slug: "example_com_docs", Ext: ".md"}
+	if _, err := executeTemplate(tmpl, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// templateFilename renders tmpl for a page fetched from u at fetchedAt.
+func templateFilename(tmpl *template.Template, u *url.URL, title string, fetchedAt time.Time, ext string) (string, error) {
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+	fields := templateFields{Title: "untitled", Date: fetchedAt.Format("2006-01-02"), Slug: "output", Ext: sanitizeSegment(ext)}
+	if u != nil {
+		fields.Host = sanitizeSegment(u.Host)
+		fields.Path = sanitizeSegment(strings.Trim(u.Path, "/"))
+		fields.Slug = strings.TrimSuffix(outputFilename(u, ext), ext)
+	}
+	if title = sanitizeSegment(title); title != "" {
+		fields.Title = title
+	}
+	return executeTemplate(tmpl, fields)
+}
+
+// executeTemplate renders tmpl with fields, failing on an empty result.
+func executeTemplate(tmpl *template.Template, fields templateFields) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, fields); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(b.String())
+	if name == "" {
+		return "", errors.New("-output-template renders an empty file name")
+	}
+	return name, nil
+}
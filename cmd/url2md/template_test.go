@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTemplateFilename(t *testing.T) {
+	u, _ := url.Parse("https://blog.example.com:8443/2024/my-post/?page=2")
+	fetched := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		template string
+		title    string
+		want     string
+	}{
+		{"{{.Date}}-{{.Title}}.md", "Hello, World: A/B test", "2024-06-03-Hello_World_A_B_test.md"},
+		{"{{.Host}}/{{.Path}}{{.Ext}}", "", "blog.example.com_8443/2024_my-post.md"},
+		{"{{.Slug}}-{{.Title}}{{.Ext}}", "", "blog_example_com_8443_2024_my_post-untitled.md"},
+		{"{{.Title}}.md", "../../etc/passwd", ".._.._etc_passwd.md"},
+		{"notes/{{.Date}}/{{.Title | printf \"%.5s\"}}.md", "Release notes", "notes/2024-06-03/Relea.md"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseOutputTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("parseOutputTemplate(%q) returned error: %v", tt.template, err)
+		}
+		got, err := templateFilename(tmpl, u, tt.title, fetched, ".md")
+		if err != nil {
+			t.Fatalf("templateFilename(%q) returned error: %v", tt.template, err)
+		}
+		if got != tt.want {
+			t.Fatalf("templateFilename(%q) = %q, expected %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestParseOutputTemplateErrors(t *testing.T) {
+	for _, text := range []string{"{{.Date", "{{.Author}}.md", "{{if .Title}}{{end}}"} {
+		if _, err := parseOutputTemplate(text); err == nil {
+			t.Fatalf("parseOutputTemplate(%q) succeeded, expected an error", text)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// loadCACert returns the system root certificates extended with the PEM
+// certificates in filename, for sites signed by a private CA.
+func loadCACert(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("-cacert: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("-cacert: no PEM certificates found in %s", filename)
+	}
+	return pool, nil
+}
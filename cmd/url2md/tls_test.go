@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"url-to-markdown/pkg/url2md"
+)
+
+func TestLoadCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<h1>Internal</h1>"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := url2md.Options{NoProxy: true}
+	if _, err := url2md.Convert(context.Background(), srv.URL, opts); err == nil {
+		t.Fatalf("Convert without the CA succeeded, expected a certificate error")
+	}
+
+	pool, err := loadCACert(caFile)
+	if err != nil {
+		t.Fatalf("loadCACert returned error: %v", err)
+	}
+	opts.RootCAs = pool
+	res, err := url2md.Convert(context.Background(), srv.URL, opts)
+	if err != nil {
+		t.Fatalf("Convert with the CA returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "# Internal") {
+		t.Fatalf("markdown = %q, expected the page heading", res.Markdown)
+	}
+
+	notPEM := filepath.Join(dir, "notpem.txt")
+	os.WriteFile(notPEM, []byte("hello"), 0644)
+	for _, name := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		if _, err := loadCACert(name); err == nil {
+			t.Fatalf("loadCACert(%q) = nil error, expected failure", name)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// loadUserAgents reads the -user-agent-file list: one User-Agent per line,
+// skipping blank lines and # comments like the -i URL lists.
+func loadUserAgents(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("-user-agent-file: %w", err)
+	}
+	defer f.Close()
+	agents, err := readURLs(f)
+	if err != nil {
+		return nil, fmt.Errorf("-user-agent-file: %s: %w", filename, err)
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("-user-agent-file: %s: no User-Agent strings", filename)
+	}
+	return agents, nil
+}
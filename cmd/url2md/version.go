@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// version, commit and date describe the build. Release builds set them with
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/url2md
+//
+// Values left empty are taken from the build info the go command embeds.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// versionString returns the line printed by -version.
+func versionString() string {
+	info, _ := debug.ReadBuildInfo()
+	return formatVersion(version, commit, date, info)
+}
+
+// formatVersion fills v, c and d from info where they are empty and formats
+// them. info may be nil.
+func formatVersion(v, c, d string, info *debug.BuildInfo) string {
+	fromInfo, modified := c == "", false
+	if info != nil {
+		if v == "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = s.Value
+					if len(c) > 12 {
+						c = c[:12]
+					}
+				}
+			case "vcs.time":
+				if d == "" {
+					d = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	} else if fromInfo && modified {
+		c += "-dirty"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return fmt.Sprintf("url2md %s (commit %s, built %s)", v, c, d)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFormatVersion(t *testing.T) {
+	info := &debug.BuildInfo{
+		Main: debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+			{Key: "vcs.time", Value: "2024-06-03T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	tests := []struct {
+		v, c, d string
+		info    *debug.BuildInfo
+		want    string
+	}{
+		{"1.2.0", "abc1234", "2024-07-01", info, "url2md 1.2.0 (commit abc1234, built 2024-07-01)"},
+		{"", "", "", info, "url2md dev (commit 0123456789ab-dirty, built 2024-06-03T10:00:00Z)"},
+		{"", "", "", &debug.BuildInfo{Main: debug.Module{Version: "v1.3.0"}}, "url2md v1.3.0 (commit unknown, built unknown)"},
+		{"", "", "", nil, "url2md dev (commit unknown, built unknown)"},
+	}
+	for _, tt := range tests {
+		if got := formatVersion(tt.v, tt.c, tt.d, tt.info); got != tt.want {
+			t.Fatalf("formatVersion(%q, %q, %q) = %q, expected %q", tt.v, tt.c, tt.d, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,23 @@ module url-to-markdown
 
 go 1.22.5
 
-require github.com/JohannesKaufmann/html-to-markdown v1.6.0
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/JohannesKaufmann/html-to-markdown v1.6.0
+	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/andybalholm/brotli v1.1.1
+	github.com/andybalholm/cascadia v1.3.2
+	github.com/go-shiori/go-readability v0.0.0-20240701094332-1070de7e32ef
+	golang.org/x/net v0.25.0
+	golang.org/x/term v0.20.0
+	golang.org/x/text v0.15.0
+	golang.org/x/time v0.5.0
+)
 
 require (
-	github.com/PuerkitoBio/goquery v1.9.2 // indirect
-	github.com/andybalholm/cascadia v1.3.2 // indirect
-	golang.org/x/net v0.25.0 // indirect
+	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de // indirect
+	github.com/go-shiori/dom v0.0.0-20210627111528-4e4722cd0d65 // indirect
+	github.com/gogs/chardet v0.0.0-20211120154057-b7413eaefb8f // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
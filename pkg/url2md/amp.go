@@ -0,0 +1,35 @@
+package url2md
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ampCanonical reports whether page is an AMP document, marked with
+// <html amp> or <html ⚡>, and returns the canonical URL it declares,
+// resolved against base. The URL is nil when the page has no canonical
+// http(s) link or the link points back at the page itself.
+func ampCanonical(page []byte, base *url.URL) (*url.URL, bool) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, false
+	}
+	root := doc.Find("html").First()
+	_, amp := root.Attr("amp")
+	if _, bolt := root.Attr("⚡"); !amp && !bolt {
+		return nil, false
+	}
+
+	raw := strings.TrimSpace(doc.Find(`link[rel~="canonical"]`).First().AttrOr("href", ""))
+	if raw == "" {
+		return nil, true
+	}
+	u, err := base.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.String() == base.String() {
+		return nil, true
+	}
+	return u, true
+}
@@ -0,0 +1,72 @@
+package url2md
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAMPCanonical(t *testing.T) {
+	base, _ := url.Parse("https://example.com/amp/post")
+	tests := []struct {
+		page      string
+		canonical string
+		amp       bool
+	}{
+		{`<html amp><head><link rel="canonical" href="/post"></head></html>`, "https://example.com/post", true},
+		{`<html ⚡ lang="en"><head><link rel="canonical" href="https://www.example.com/post"></head></html>`, "https://www.example.com/post", true},
+		{`<html amp><head></head></html>`, "", true},
+		{`<html amp><head><link rel="canonical" href="/amp/post"></head></html>`, "", true},
+		{`<html><head><link rel="canonical" href="/post"></head></html>`, "", false},
+	}
+	for _, tt := range tests {
+		u, amp := ampCanonical([]byte(tt.page), base)
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tt.canonical || amp != tt.amp {
+			t.Fatalf("ampCanonical(%q) = %q, %v; expected %q, %v", tt.page, got, amp, tt.canonical, tt.amp)
+		}
+	}
+}
+
+func TestConvertPreferCanonicalFromAMP(t *testing.T) {
+	canonicalStatus := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/amp/post":
+			io.WriteString(w, `<html amp><head><link rel="canonical" href="/post"></head><body><p>Short AMP teaser.</p></body></html>`)
+		case "/post":
+			w.WriteHeader(canonicalStatus)
+			io.WriteString(w, `<html><body><p>The full article.</p></body></html>`)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		prefer    bool
+		status    int
+		wantFinal string
+		wantText  string
+	}{
+		{true, http.StatusOK, "/post", "The full article."},
+		{true, http.StatusNotFound, "/amp/post", "Short AMP teaser."},
+		{false, http.StatusOK, "/amp/post", "Short AMP teaser."},
+	}
+	for _, tt := range tests {
+		canonicalStatus = tt.status
+		res, err := Convert(context.Background(), srv.URL+"/amp/post", Options{PreferCanonicalFromAMP: tt.prefer, NoProxy: true, Logf: t.Logf})
+		if err != nil {
+			t.Fatalf("prefer %v, status %d: Convert returned error: %v", tt.prefer, tt.status, err)
+		}
+		if res.FinalURL.Path != tt.wantFinal || !strings.Contains(res.Markdown, tt.wantText) {
+			t.Fatalf("prefer %v, status %d: FinalURL %s, markdown %q; expected %s with %q", tt.prefer, tt.status, res.FinalURL, res.Markdown, tt.wantFinal, tt.wantText)
+		}
+	}
+}
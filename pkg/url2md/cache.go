@@ -0,0 +1,135 @@
+package url2md
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheIndexName is the file, inside the cache directory, holding the
+// validators of every cached URL.
+const cacheIndexName = "index.json"
+
+// ErrNotModified is returned when Options.Cache is set and the server
+// answers 304 Not Modified, meaning the previous conversion is still current.
+// It is also returned when the page has not changed after
+// Options.ModifiedSince.
+var ErrNotModified = errors.New("not modified")
+
+// cacheEntry holds the validators a server sent for a URL.
+type cacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Cache remembers the ETag and Last-Modified headers of fetched pages so that
+// later runs can make conditional requests. It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// OpenCache loads the cache index stored in dir, creating the directory if
+// needed. A missing index yields an empty cache.
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("opening cache: %w", err)
+	}
+	c := &Cache{dir: dir, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(filepath.Join(dir, cacheIndexName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("opening cache: %s: %w", cacheIndexName, err)
+	}
+	return c, nil
+}
+
+// Save writes the index back to the cache directory, replacing the previous
+// one atomically.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, cacheIndexName+".*")
+	if err != nil {
+		return fmt.Errorf("saving cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, cacheIndexName)); err != nil {
+		return fmt.Errorf("saving cache: %w", err)
+	}
+	return nil
+}
+
+// applyConditional adds If-None-Match and If-Modified-Since to req when the
+// cache holds validators for key.
+func (c *Cache) applyConditional(req *http.Request, key string) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// Validators are the ETag and Last-Modified headers a server sent for a
+// page, as returned in Result.Validators.
+type Validators struct {
+	// URL is the requested URL the validators belong to. It is empty when
+	// the page was not fetched from its origin.
+	URL          string
+	ETag         string
+	LastModified string
+}
+
+// responseValidators returns the validators of resp, requested as key.
+func responseValidators(key string, resp *http.Response) Validators {
+	return Validators{URL: key, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+}
+
+// Store records v so that the next request for v.URL is conditional, or
+// forgets v.URL when the server sent no validators. Call it once the page
+// of a Result has been saved: a page stored but never written would be
+// skipped as not modified the next time. Store does nothing on a nil Cache
+// and for validators without a URL.
+func (c *Cache) Store(v Validators) {
+	if c == nil || v.URL == "" {
+		return
+	}
+	entry := cacheEntry{ETag: v.ETag, LastModified: v.LastModified}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry == (cacheEntry{}) {
+		delete(c.entries, v.URL)
+		return
+	}
+	c.entries[v.URL] = entry
+}
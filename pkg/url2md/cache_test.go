@@ -0,0 +1,81 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertWithCache(t *testing.T) {
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Sec-Fetch-Mode") != "navigate" {
+			return
+		}
+		conditional = append(conditional, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		io.WriteString(w, "<h1>Page</h1>")
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := OpenCache(dir)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	res, err := Convert(context.Background(), srv.URL+"/page", Options{Cache: cache, NoProxy: true})
+	if err != nil {
+		t.Fatalf("first Convert returned error: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	// Convert leaves the cache alone until the result is stored.
+	cache, err = OpenCache(dir)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	if _, err := Convert(context.Background(), srv.URL+"/page", Options{Cache: cache, NoProxy: true}); err != nil {
+		t.Fatalf("Convert without stored validators returned error: %v", err)
+	}
+	cache.Store(res.Validators)
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	// A fresh run reads the validators back from the index.
+	cache, err = OpenCache(dir)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	_, err = Convert(context.Background(), srv.URL+"/page", Options{Cache: cache, NoProxy: true})
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("second Convert error = %v, expected ErrNotModified", err)
+	}
+
+	want := []string{"|", "|", `"v1"|Mon, 02 Jan 2006 15:04:05 GMT`}
+	if strings.Join(conditional, ",") != strings.Join(want, ",") {
+		t.Fatalf("conditional headers = %q, expected %q", conditional, want)
+	}
+}
+
+func TestOpenCacheCorruptIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, cacheIndexName), []byte("{not json"), 0644)
+	if _, err := OpenCache(dir); err == nil {
+		t.Fatalf("OpenCache with a corrupt index returned nil error")
+	}
+}
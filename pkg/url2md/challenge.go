@@ -0,0 +1,115 @@
+package url2md
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// challengeSniffSize is how much of a response body is searched for
+// challenge signatures. Larger 2xx pages are assumed to be real content, so
+// that an article quoting a signature is not mistaken for a challenge.
+const challengeSniffSize = 64 << 10
+
+// challengeSignature recognizes the block or challenge page of a web
+// application firewall. Every non-empty field must match.
+type challengeSignature struct {
+	// name is the firewall reported in log messages.
+	name string
+	// header is a response header that must be present; when headerValue is
+	// set, one of its values must contain it, ignoring case.
+	header      string
+	headerValue string
+	// title is the <title> of the page, ignoring case and surrounding
+	// whitespace.
+	title string
+	// body is a string the page contains, ignoring case.
+	body string
+	// anyStatus makes the signature match 2xx responses too. Signatures
+	// also seen on regular pages, such as a Server header, only explain why
+	// a 401, 403, 429 or 503 was returned.
+	anyStatus bool
+}
+
+// challengeSignatures are the firewall signatures detectChallenge looks for,
+// most specific first.
+var challengeSignatures = []challengeSignature{
+	{name: "Cloudflare", header: "Cf-Mitigated", headerValue: "challenge", anyStatus: true},
+	{name: "Cloudflare", title: "Just a moment...", anyStatus: true},
+	{name: "Cloudflare", title: "Attention Required! | Cloudflare", anyStatus: true},
+	{name: "Cloudflare", body: "__cf_chl_", anyStatus: true},
+	{name: "Cloudflare", body: "/cdn-cgi/challenge-platform/", anyStatus: true},
+	{name: "Cloudflare", header: "Server", headerValue: "cloudflare"},
+	{name: "Sucuri", header: "X-Sucuri-Block", anyStatus: true},
+	{name: "Sucuri", title: "Sucuri WebSite Firewall - Access Denied", anyStatus: true},
+	{name: "Sucuri", body: "sucuri_cloudproxy_js", anyStatus: true},
+	{name: "Sucuri", header: "Server", headerValue: "sucuri"},
+	{name: "Akamai", body: "/_sec/verify?provider=interstitial", anyStatus: true},
+	{name: "Akamai", header: "Set-Cookie", headerValue: "_abck="},
+	{name: "Akamai", header: "Server", headerValue: "akamaighost"},
+}
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// isBlockStatus reports whether code is one of the statuses firewalls and
+// rate limiters answer with, which trigger the proxy fallback.
+func isBlockStatus(code int) bool {
+	switch code {
+	case http.StatusForbidden, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// detectChallenge returns the name of the firewall whose challenge or block
+// page resp is, given the beginning of its body, or "" when none matches.
+func detectChallenge(resp *http.Response, body []byte) string {
+	blocked := isBlockStatus(resp.StatusCode)
+	if !blocked && len(body) > challengeSniffSize {
+		body = nil
+	}
+	if len(body) > challengeSniffSize {
+		body = body[:challengeSniffSize]
+	}
+	lower := bytes.ToLower(body)
+	title := ""
+	if m := titleRe.FindSubmatch(body); m != nil {
+		title = strings.TrimSpace(string(m[1]))
+	}
+
+	for _, sig := range challengeSignatures {
+		if !sig.anyStatus && !blocked {
+			continue
+		}
+		if sig.header != "" && !headerContains(resp.Header, sig.header, sig.headerValue) {
+			continue
+		}
+		if sig.title != "" && !strings.EqualFold(title, sig.title) {
+			continue
+		}
+		if sig.body != "" && !bytes.Contains(lower, []byte(strings.ToLower(sig.body))) {
+			continue
+		}
+		return sig.name
+	}
+	return ""
+}
+
+// headerContains reports whether header has name and, when value is not
+// empty, a value of it containing value, ignoring case.
+func headerContains(header http.Header, name, value string) bool {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return false
+	}
+	if value == "" {
+		return true
+	}
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), strings.ToLower(value)) {
+			return true
+		}
+	}
+	return false
+}
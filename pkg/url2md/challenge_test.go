@@ -0,0 +1,97 @@
+package url2md
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readChallenge(t *testing.T, name string) []byte {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", "challenges", name))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return page
+}
+
+func TestDetectChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		fixture string
+		want    string
+	}{
+		{"cloudflare page", http.StatusOK, nil, "cloudflare.html", "Cloudflare"},
+		{"cloudflare 403", http.StatusForbidden, http.Header{"Server": {"cloudflare"}}, "", "Cloudflare"},
+		{"cf-mitigated", http.StatusOK, http.Header{"Cf-Mitigated": {"challenge"}}, "article.html", "Cloudflare"},
+		{"cloudflare server on a regular page", http.StatusOK, http.Header{"Server": {"cloudflare"}}, "article.html", ""},
+		{"sucuri page", http.StatusOK, nil, "sucuri.html", "Sucuri"},
+		{"sucuri block header", http.StatusOK, http.Header{"X-Sucuri-Block": {"BLK001"}}, "", "Sucuri"},
+		{"akamai interstitial", http.StatusOK, nil, "akamai.html", "Akamai"},
+		{"akamai 403", http.StatusForbidden, http.Header{"Set-Cookie": {"bm_sz=1; Path=/", "_abck=ABC~-1~; Path=/"}}, "", "Akamai"},
+		{"akamai cookie on a regular page", http.StatusOK, http.Header{"Set-Cookie": {"_abck=ABC~-1~; Path=/"}}, "article.html", ""},
+		{"article quoting a title", http.StatusOK, nil, "article.html", ""},
+		{"plain 403", http.StatusForbidden, http.Header{"Server": {"nginx"}}, "article.html", ""},
+	}
+	for _, tt := range tests {
+		var body []byte
+		if tt.fixture != "" {
+			body = readChallenge(t, tt.fixture)
+		}
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		resp := &http.Response{StatusCode: tt.status, Header: header}
+		if got := detectChallenge(resp, body); got != tt.want {
+			t.Fatalf("%s: detectChallenge = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectChallengeIgnoresLargePages(t *testing.T) {
+	body := append(readChallenge(t, "cloudflare.html"), strings.Repeat("<p>content</p>\n", challengeSniffSize/10)...)
+	if got := detectChallenge(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, body); got != "" {
+		t.Fatalf("detectChallenge = %q for a large page, expected none", got)
+	}
+}
+
+func TestFetchHTMLChallengeOn200UsesProxy(t *testing.T) {
+	challenge := readChallenge(t, "cloudflare.html")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/proxy/") {
+			io.WriteString(w, "# Real page")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(challenge)
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL + "/page")
+
+	opts := &Options{UserAgent: DefaultUserAgent, MaxRedirects: 10, NoWarmup: true, ProxyURL: srv.URL + "/proxy/", Logf: t.Logf}
+	page, err := fetchHTML(context.Background(), newClient(opts), target, opts)
+	if err != nil {
+		t.Fatalf("fetchHTML returned error: %v", err)
+	}
+	if !page.viaProxy || string(page.body) != "# Real page" {
+		t.Fatalf("fetchHTML = %q via proxy %v, expected the proxy content", page.body, page.viaProxy)
+	}
+
+	opts.NoProxy = true
+	_, err = fetchHTML(context.Background(), newClient(opts), target, opts)
+	if want := "HTTP status 200 OK with a Cloudflare challenge page"; err == nil || err.Error() != want {
+		t.Fatalf("fetchHTML error = %v, expected %q", err, want)
+	}
+	if Kind(err) != KindHTTPStatus {
+		t.Fatalf("Kind(%v) = %d, expected KindHTTPStatus", err, Kind(err))
+	}
+}
@@ -0,0 +1,101 @@
+package url2md
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// metaCharsetRe matches both <meta charset="..."> and the older
+// <meta http-equiv="Content-Type" content="text/html; charset=..."> form.
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+
+// metaSniffLimit is how far into the document a <meta> charset declaration is
+// looked for, matching the prescan window browsers use.
+const metaSniffLimit = 1024
+
+// decodeCharset transcodes an HTML body to UTF-8. The charset is taken from the
+// Content-Type header and, when absent there, from a <meta> declaration near
+// the top of the document. Bodies without a declared charset, or declared as
+// UTF-8/ASCII, are returned unchanged.
+func decodeCharset(body []byte, contentType string) ([]byte, error) {
+	label := charsetFromContentType(contentType)
+	if label == "" {
+		label = sniffMetaCharset(body)
+	}
+	if label == "" || isUTF8Compatible(label) {
+		return body, nil
+	}
+
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return body, fmt.Errorf("unsupported charset %q", label)
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, fmt.Errorf("decoding %s body: %w", label, err)
+	}
+	return decoded, nil
+}
+
+func charsetFromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+func sniffMetaCharset(body []byte) string {
+	head := body
+	if len(head) > metaSniffLimit {
+		head = head[:metaSniffLimit]
+	}
+	if m := metaCharsetRe.FindSubmatch(head); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+func isUTF8Compatible(label string) bool {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return true
+	}
+	return false
+}
+
+var (
+	headTagRe = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	doctypeRe = regexp.MustCompile(`(?i)^\s*<!doctype[^>]*>`)
+)
+
+// declareUTF8 returns a copy of page, which decodeCharset has transcoded to
+// UTF-8, whose charset declaration says so: a <meta> naming the original
+// charset would make browsers decode the saved file wrongly. A page without
+// any declaration, whose charset came from the Content-Type header, gets a
+// <meta charset="utf-8"> at the start of its <head>.
+func declareUTF8(page []byte) []byte {
+	head := page[:min(len(page), metaSniffLimit)]
+	if loc := metaCharsetRe.FindSubmatchIndex(head); loc != nil {
+		if isUTF8Compatible(string(page[loc[2]:loc[3]])) {
+			return bytes.Clone(page)
+		}
+		return slices.Concat(page[:loc[2]], []byte("utf-8"), page[loc[3]:])
+	}
+	at := 0
+	if loc := headTagRe.FindIndex(head); loc != nil {
+		at = loc[1]
+	} else if loc := doctypeRe.FindIndex(head); loc != nil {
+		at = loc[1]
+	}
+	return slices.Concat(page[:at], []byte(`<meta charset="utf-8">`), page[at:])
+}
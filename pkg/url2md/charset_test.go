@@ -0,0 +1,88 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// latin1Page is "<p>Café à la crème</p>" encoded as ISO-8859-1.
+var latin1Page = []byte("<html><head><meta charset=\"iso-8859-1\"></head><body><p>Caf\xe9 \xe0 la cr\xe8me</p></body></html>")
+
+func TestDecodeCharsetFromContentType(t *testing.T) {
+	got, err := decodeCharset([]byte("<p>Caf\xe9</p>"), "text/html; charset=ISO-8859-1")
+	if err != nil {
+		t.Fatalf("decodeCharset returned error: %v", err)
+	}
+	if string(got) != "<p>Café</p>" {
+		t.Fatalf("decodeCharset = %q, expected %q", got, "<p>Café</p>")
+	}
+}
+
+func TestDecodeCharsetFromMeta(t *testing.T) {
+	got, err := decodeCharset(latin1Page, "text/html")
+	if err != nil {
+		t.Fatalf("decodeCharset returned error: %v", err)
+	}
+
+	expected := "<html><head><meta charset=\"iso-8859-1\"></head><body><p>Café à la crème</p></body></html>"
+	if string(got) != expected {
+		t.Fatalf("decodeCharset = %q, expected %q", got, expected)
+	}
+}
+
+func TestDecodeCharsetHTTPEquiv(t *testing.T) {
+	body := []byte(`<meta http-equiv="Content-Type" content="text/html; charset=windows-1252"><p>` + "\x93quoted\x94" + `</p>`)
+	got, err := decodeCharset(body, "")
+	if err != nil {
+		t.Fatalf("decodeCharset returned error: %v", err)
+	}
+	if want := `<meta http-equiv="Content-Type" content="text/html; charset=windows-1252"><p>“quoted”</p>`; string(got) != want {
+		t.Fatalf("decodeCharset = %q, expected %q", got, want)
+	}
+}
+
+func TestDecodeCharsetUTF8IsNoop(t *testing.T) {
+	body := []byte("<p>Café</p>")
+	got, err := decodeCharset(body, "text/html; charset=utf-8")
+	if err != nil {
+		t.Fatalf("decodeCharset returned error: %v", err)
+	}
+	if &got[0] != &body[0] {
+		t.Fatalf("decodeCharset copied a UTF-8 body, expected it to be returned as-is")
+	}
+}
+
+func TestDeclareUTF8(t *testing.T) {
+	cases := []struct{ page, want string }{
+		{`<html><head><meta charset="iso-8859-1"></head><p>Café</p>`, `<html><head><meta charset="utf-8"></head><p>Café</p>`},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=windows-1252"><p>“q”</p>`, `<meta http-equiv="Content-Type" content="text/html; charset=utf-8"><p>“q”</p>`},
+		{`<head><meta charset=UTF-8></head>`, `<head><meta charset=UTF-8></head>`},
+		{`<!DOCTYPE html><html><head lang="it"><title>T</title></head>`, `<!DOCTYPE html><html><head lang="it"><meta charset="utf-8"><title>T</title></head>`},
+		{`<!DOCTYPE html><p>Café</p>`, `<!DOCTYPE html><meta charset="utf-8"><p>Café</p>`},
+		{`<p>Café</p>`, `<meta charset="utf-8"><p>Café</p>`},
+	}
+	for _, c := range cases {
+		if got := string(declareUTF8([]byte(c.page))); got != c.want {
+			t.Fatalf("declareUTF8(%q) = %q, expected %q", c.page, got, c.want)
+		}
+	}
+}
+
+func TestConvertHTMLDeclaredAsUTF8(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(latin1Page)
+	}))
+	defer srv.Close()
+
+	res, err := Convert(context.Background(), srv.URL, Options{NoProxy: true, NoWarmup: true})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	want := "<html><head><meta charset=\"utf-8\"></head><body><p>Café à la crème</p></body></html>"
+	if string(res.HTML) != want {
+		t.Fatalf("HTML = %q, expected %q", res.HTML, want)
+	}
+}
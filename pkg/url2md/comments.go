@@ -0,0 +1,36 @@
+package url2md
+
+import (
+	"bytes"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// stripComments removes every comment from page, including IE conditional
+// comments. The markers of downlevel-revealed conditionals such as
+// <![if !IE]> are comments too, so they go while the content between them
+// stays.
+func stripComments(page []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.CommentNode {
+				n.RemoveChild(c)
+			} else {
+				walk(c)
+			}
+			c = next
+		}
+	}
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+	out, err := doc.Html()
+	return []byte(out), err
+}
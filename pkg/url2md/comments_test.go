@@ -0,0 +1,64 @@
+package url2md
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+const commentsPage = `<html><head><!-- build: 2024-06-03 commit abc123 --></head><body>
+<h1>Title</h1>
+<!--
+  Generated by SiteBuilder 9.1
+  template: article.tmpl
+-->
+<p>First <!-- inline note -->paragraph.</p>
+<!--[if lt IE 9]><p>Please upgrade your browser.</p><![endif]-->
+<![if !IE]><p>Modern browsers see this.</p><![endif]>
+<pre>x := 1 // <!-- not code --></pre>
+<details><summary>More</summary><!-- raw --><p>Hidden text.</p></details>
+</body></html>`
+
+func TestStripComments(t *testing.T) {
+	got, err := stripComments([]byte(commentsPage))
+	if err != nil {
+		t.Fatalf("stripComments returned error: %v", err)
+	}
+	for _, gone := range []string{"<!--", "SiteBuilder", "build:", "upgrade your browser", "[if", "endif"} {
+		if strings.Contains(string(got), gone) {
+			t.Fatalf("stripComments left %q in %s", gone, got)
+		}
+	}
+	for _, kept := range []string{"<h1>Title</h1>", "First paragraph.", "Modern browsers see this.", "x := 1 // ", "Hidden text."} {
+		if !strings.Contains(string(got), kept) {
+			t.Fatalf("stripComments dropped %q from %s", kept, got)
+		}
+	}
+}
+
+func TestConvertHTMLKeepComments(t *testing.T) {
+	// A rule that keeps <details> as raw HTML, as custom rules often do,
+	// would carry comments into the Markdown.
+	details := md.Rule{
+		Filter: []string{"details"},
+		Replacement: func(_ string, sel *goquery.Selection, _ *md.Options) *string {
+			raw, _ := goquery.OuterHtml(sel)
+			return md.String(raw)
+		},
+	}
+	for _, keep := range []bool{false, true} {
+		res, err := ConvertHTML(context.Background(), []byte(commentsPage), nil, Options{KeepComments: keep, Rules: []md.Rule{details}})
+		if err != nil {
+			t.Fatalf("keep %v: ConvertHTML returned error: %v", keep, err)
+		}
+		if got := strings.Contains(res.Markdown, "<!-- raw -->"); got != keep {
+			t.Fatalf("keep %v: markdown %q, comment present %v", keep, res.Markdown, got)
+		}
+		if !strings.Contains(res.Markdown, "First paragraph.") || !strings.Contains(res.Markdown, "Modern browsers see this.") {
+			t.Fatalf("keep %v: markdown %q lost page content", keep, res.Markdown)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package url2md
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// convertToMarkdown converts page, resolving relative URLs against base
+// unless base is nil.
+func convertToMarkdown(base *url.URL, page []byte, opts *Options) (string, error) {
+	domain := ""
+	if base != nil {
+		domain = base.Host
+	}
+	linkStyle := "inlined"
+	if opts.LinkStyle == LinkReferenced {
+		linkStyle = "referenced"
+	}
+	codeBlockStyle := ""
+	if opts.CodeFence {
+		codeBlockStyle = "fenced"
+	}
+	converter := md.NewConverter(domain, true, &md.Options{
+		CodeBlockStyle:   codeBlockStyle,
+		LinkStyle:        linkStyle,
+		HeadingStyle:     string(opts.HeadingStyle),
+		BulletListMarker: opts.BulletChar,
+		GetAbsoluteURL: func(selec *goquery.Selection, rawURL string, _ string) string {
+			if _, local := selec.Attr(localAssetAttr); local {
+				return rawURL
+			}
+			return linkTarget(base, rawURL, opts)
+		},
+	})
+	converter.AddRules(builtinRules...)
+	converter.AddRules(definitionListRules(opts.DefinitionListStyle)...)
+	if !opts.KeepScripts {
+		converter.Before(removeScripts)
+	}
+	if !opts.KeepHidden {
+		converter.Before(removeHidden)
+	}
+	converter.Before(unlinkCode)
+	if opts.DetailsHeadings {
+		converter.Before(headDetails)
+	}
+	if opts.Figures {
+		converter.AddRules(figcaptionRule)
+	}
+	if opts.LinkStyle == LinkReferenced {
+		converter.Before(func(selec *goquery.Selection) {
+			numberLinkTargets(selec, base, opts)
+		})
+		converter.After(dedupeLinkReferences)
+	}
+	if opts.CodeFence {
+		converter.Before(func(selec *goquery.Selection) {
+			selec.Find("pre").Each(func(_ int, pre *goquery.Selection) {
+				labelCodeBlock(pre, opts.CodeLanguage)
+			})
+		})
+	}
+	if opts.Tables {
+		converter.Before(func(selec *goquery.Selection) {
+			selec.Find("table").Each(func(_ int, table *goquery.Selection) {
+				expandTableSpans(table)
+			})
+		})
+		converter.Use(plugin.Table())
+	}
+	converter.AddRules(opts.Rules...)
+	return converter.ConvertString(string(page))
+}
+
+// resolveURL resolves a link or image reference found in a page served from
+// base. Unparsable references, data URIs and references in a page without a
+// base are returned unchanged.
+func resolveURL(base *url.URL, rawURL string) string {
+	ref, err := url.Parse(rawURL)
+	if err != nil || ref.Scheme == "data" || base == nil {
+		return rawURL
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// removeScripts removes the <script>, <style>, <noscript> and <template>
+// elements of selec, in the <head> as well as the <body>. They are never
+// shown as text, but their content can otherwise leak into the Markdown or
+// reach Options.Rules.
+func removeScripts(selec *goquery.Selection) {
+	selec.Find("script, style, noscript, template").Remove()
+}
+
+// removeHidden removes the elements hidden from readers: those marked
+// aria-hidden="true", those with the hidden attribute and those with an
+// inline display:none. Collapsed hidden="until-found" sections can still be
+// revealed by the browser and are kept, and so are <body> and anything
+// containing the main content, which some sites mark aria-hidden while a
+// dialog is open.
+func removeHidden(selec *goquery.Selection) {
+	selec.Find("[aria-hidden], [hidden], [style]").Not("html, body").Each(func(_ int, s *goquery.Selection) {
+		if !isHidden(s) || s.Find(mainContentSelector).Length() > 0 {
+			return
+		}
+		s.Remove()
+	})
+}
+
+func isHidden(s *goquery.Selection) bool {
+	if strings.EqualFold(strings.TrimSpace(s.AttrOr("aria-hidden", "")), "true") {
+		return true
+	}
+	if hidden, ok := s.Attr("hidden"); ok && !strings.EqualFold(strings.TrimSpace(hidden), "until-found") {
+		return true
+	}
+	return displayNone(s.AttrOr("style", ""))
+}
+
+// displayNone reports whether the inline style declares display:none. Only
+// a plain "display: none" declaration counts, optionally !important; any
+// value it cannot read, like a var(), is taken as visible.
+func displayNone(style string) bool {
+	hidden := false
+	for _, decl := range strings.Split(style, ";") {
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(prop), "display") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if before, ok := strings.CutSuffix(value, "!important"); ok {
+			value = strings.TrimSpace(before)
+		}
+		// A later display declaration overrides an earlier one.
+		hidden = strings.EqualFold(value, "none")
+	}
+	return hidden
+}
+
+// unlinkCode replaces the links inside <pre>, <code>, <kbd> and <samp> with
+// their text. Code keeps only its text anyway, but the links would still
+// add reference definitions with LinkReferenced and be numbered as if they
+// were in the prose.
+func unlinkCode(selec *goquery.Selection) {
+	selec.Find("pre a, code a, kbd a, samp a").Each(func(_ int, link *goquery.Selection) {
+		link.ReplaceWithSelection(link.Contents())
+	})
+}
+
+// numberLinkTargets overrides the reference numbers the converter gives to
+// links, one per link, so that links to the same target share a number.
+func numberLinkTargets(selec *goquery.Selection, base *url.URL, opts *Options) {
+	ids := map[string]int{}
+	selec.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+		key := linkTarget(base, strings.TrimSpace(link.AttrOr("href", "")), opts) + "\x00" + link.AttrOr("title", "")
+		id, ok := ids[key]
+		if !ok {
+			id = len(ids) + 1
+			ids[key] = id
+		}
+		link.SetAttr("data-index", strconv.Itoa(id))
+	})
+}
+
+// dedupeLinkReferences drops the repeated definitions that links sharing a
+// number leave in the reference section at the end of markdown.
+func dedupeLinkReferences(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	start := len(lines)
+	for start > 0 && linkRefRe.MatchString(lines[start-1]) {
+		start--
+	}
+	seen := map[string]bool{}
+	out := lines[:start]
+	for _, line := range lines[start:] {
+		if !seen[line] {
+			seen[line] = true
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// labelCodeBlock rewrites the class of the <code> inside pre to a single
+// language-X naming the block's language, so that the converter uses it as
+// the fence info string. The language comes from a language-X or lang-X
+// class on the <code> or the <pre>, falling back to defaultLang. A <pre>
+// without a <code> gets one.
+func labelCodeBlock(pre *goquery.Selection, defaultLang string) {
+	lang := codeLanguage(pre.Find("code").First())
+	if lang == "" {
+		lang = codeLanguage(pre)
+	}
+	if lang == "" {
+		lang = defaultLang
+	}
+
+	if pre.Find("code").Length() == 0 {
+		pre.WrapInnerHtml("<code></code>")
+	}
+	code := pre.Find("code").First()
+	if lang == "" {
+		code.RemoveAttr("class")
+		return
+	}
+	code.SetAttr("class", "language-"+lang)
+}
+
+func codeLanguage(selec *goquery.Selection) string {
+	for _, class := range strings.Fields(selec.AttrOr("class", "")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang := strings.TrimPrefix(class, prefix); lang != class && lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// linkTarget returns the URL written for a link or image reference: resolved
+// with resolveURL and, with opts.CleanLinks, stripped of tracking parameters.
+func linkTarget(base *url.URL, rawURL string, opts *Options) string {
+	target := resolveURL(base, rawURL)
+	if !opts.CleanLinks || strings.HasPrefix(target, "data:") {
+		return target
+	}
+	patterns := opts.TrackingParams
+	if len(patterns) == 0 {
+		patterns = DefaultTrackingParams
+	}
+	return cleanLink(target, patterns)
+}
+
+// expandTableSpans pads the rows of table with empty cells where colspan and
+// rowspan would otherwise leave them short, because pipe tables need the
+// same number of cells in every row. The spanned content stays in the first
+// cell it covers.
+func expandTableSpans(table *goquery.Selection) {
+	pending := map[int]int{} // column -> rows still covered by a rowspan above
+	table.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		if !row.Closest("table").IsSelection(table) {
+			return // row of a nested table
+		}
+		col := 0
+		fill := func(before *html.Node) {
+			for pending[col] > 0 {
+				pending[col]--
+				row.Get(0).InsertBefore(emptyCell(atom.Td), before)
+				col++
+			}
+		}
+		row.Children().Filter("td, th").Each(func(_ int, cell *goquery.Selection) {
+			node := cell.Get(0)
+			fill(node)
+			colspan := spanAttr(cell, "colspan")
+			if rowspan := spanAttr(cell, "rowspan"); rowspan > 1 {
+				for i := 0; i < colspan; i++ {
+					pending[col+i] = rowspan - 1
+				}
+			}
+			for i := 1; i < colspan; i++ {
+				row.Get(0).InsertBefore(emptyCell(node.DataAtom), node.NextSibling)
+			}
+			cell.RemoveAttr("colspan").RemoveAttr("rowspan")
+			col += colspan
+		})
+		last := -1
+		for c, rows := range pending {
+			if rows > 0 && c > last {
+				last = c
+			}
+		}
+		for ; col <= last; col++ {
+			if pending[col] > 0 {
+				pending[col]--
+			}
+			row.Get(0).AppendChild(emptyCell(atom.Td))
+		}
+	})
+}
+
+// maxColspan and maxRowspan are the largest spans browsers honor; larger
+// values are clamped to them, so that a hostile colspan cannot make
+// expandTableSpans insert millions of cells.
+const (
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// spanAttr returns the colspan or rowspan of cell, between 1 and the
+// browser limit for name.
+func spanAttr(cell *goquery.Selection, name string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(name, "1")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	if name == "colspan" {
+		return min(n, maxColspan)
+	}
+	return min(n, maxRowspan)
+}
+
+func emptyCell(tag atom.Atom) *html.Node {
+	return &html.Node{Type: html.ElementNode, DataAtom: tag, Data: tag.String()}
+}
@@ -0,0 +1,233 @@
+package url2md
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestConvertToMarkdownResolvesRelativeLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/intro")
+	html := []byte(`<p><a href="/about">About</a> <a href="setup">Setup</a></p><p><img src="//cdn.example.com/logo.png" alt="logo"></p>`)
+
+	got, err := convertToMarkdown(base, html, &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+
+	expected := "[About](https://example.com/about) [Setup](https://example.com/docs/setup)\n\n![logo](https://cdn.example.com/logo.png)"
+	if got != expected {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, expected)
+	}
+}
+
+func TestConvertToMarkdownTablesWithSpans(t *testing.T) {
+	page := `<table>
+<thead><tr><th>Name</th><th colspan="2">Contact</th></tr></thead>
+<tbody>
+<tr><td rowspan="2">Ada</td><td>ada@example.com</td><td>+1</td></tr>
+<tr><td><p>second</p><p>line</p></td><td>+2</td></tr>
+<tr><td>Bob</td><td colspan="2">none</td></tr>
+</tbody>
+</table>`
+
+	base, _ := url.Parse("https://example.com/")
+	got, err := convertToMarkdown(base, []byte(page), &Options{Tables: true})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	want := strings.Join([]string{
+		"| Name | Contact |  |",
+		"| --- | --- | --- |",
+		"| Ada | ada@example.com | +1 |",
+		"|  | second<br>line | +2 |",
+		"| Bob | none |  |",
+	}, "\n")
+	if got != want {
+		t.Fatalf("convertToMarkdown =\n%s\nexpected\n%s", got, want)
+	}
+
+	plain, err := convertToMarkdown(base, []byte(page), &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	if strings.Contains(plain, "| --- |") {
+		t.Fatalf("convertToMarkdown without Tables produced a pipe table:\n%s", plain)
+	}
+}
+
+func TestExpandTableSpansClampsSpans(t *testing.T) {
+	page := `<table><tr><td colspan="100000000" rowspan="100000000">wide</td></tr><tr><td>next</td></tr></table>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expandTableSpans(doc.Find("table"))
+	rows := doc.Find("tr")
+	if got := rows.Eq(0).Children().Length(); got != maxColspan {
+		t.Fatalf("first row has %d cells, expected colspan clamped to %d", got, maxColspan)
+	}
+	if got := rows.Eq(1).Children().Length(); got != maxColspan+1 {
+		t.Fatalf("second row has %d cells, expected %d", got, maxColspan+1)
+	}
+}
+
+func TestConvertToMarkdownStyles(t *testing.T) {
+	page := []byte(`<h1>Title</h1><h2>Section</h2><h3>Detail</h3><ul><li>one</li><li>two</li></ul>`)
+
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "# Title\n\n## Section\n\n### Detail\n\n- one\n- two"},
+		{Options{HeadingStyle: HeadingSetext, BulletChar: "*"}, "Title\n=====\n\nSection\n-------\n\n### Detail\n\n* one\n* two"},
+		{Options{HeadingStyle: HeadingATX, BulletChar: "+"}, "# Title\n\n## Section\n\n### Detail\n\n+ one\n+ two"},
+	}
+	for _, tt := range tests {
+		got, err := convertToMarkdown(nil, page, &tt.opts)
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Fatalf("%q/%q: convertToMarkdown = %q, expected %q", tt.opts.HeadingStyle, tt.opts.BulletChar, got, tt.want)
+		}
+	}
+}
+
+func TestConvertToMarkdownCodeFence(t *testing.T) {
+	page := []byte(`<pre class="chroma"><code class="hljs language-go"><span class="kd">func</span> <span class="nf">main</span>() {}</code></pre>
+<pre class="lang-python"><code>print("hi")</code></pre>
+<pre>plain<br>text</pre>`)
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"", "```go\nfunc main() {}\n```\n\n```python\nprint(\"hi\")\n```\n\n```\nplain\ntext\n```"},
+		{"text", "```go\nfunc main() {}\n```\n\n```python\nprint(\"hi\")\n```\n\n```text\nplain\ntext\n```"},
+	}
+	for _, tt := range tests {
+		got, err := convertToMarkdown(nil, page, &Options{CodeFence: true, CodeLanguage: tt.lang})
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Fatalf("CodeLanguage %q: convertToMarkdown = %q, expected %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestConvertToMarkdownReferencedLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/")
+	page := []byte(`<p>Read the <a href="guide">guide</a>, the <a href="/api">API</a> and the <a href="https://example.com/docs/guide">guide again</a>.</p>`)
+
+	got, err := convertToMarkdown(base, page, &Options{LinkStyle: LinkReferenced})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	want := "Read the [guide][1], the [API][2] and the [guide again][1].\n\n" +
+		"[1]: https://example.com/docs/guide\n" +
+		"[2]: https://example.com/api"
+	if got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+}
+
+func TestConvertToMarkdownCleanLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	page := []byte(`<a href="/post?id=1&utm_source=feed">Post</a> <a href="/x?ref=home">X</a>`)
+
+	got, err := convertToMarkdown(base, page, &Options{CleanLinks: true})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	if want := "[Post](https://example.com/post?id=1) [X](https://example.com/x?ref=home)"; got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+
+	got, _ = convertToMarkdown(base, page, &Options{CleanLinks: true, TrackingParams: []string{"ref"}})
+	if want := "[Post](https://example.com/post?id=1&utm_source=feed) [X](https://example.com/x)"; got != want {
+		t.Fatalf("convertToMarkdown with custom params = %q, expected %q", got, want)
+	}
+}
+
+func TestConvertToMarkdownCodeIsLiteral(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "code-links.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/blog/post")
+	code := "```sh\ncurl -fsSL https://example.com/install.sh?v=1&os=*linux* | sh\n# not a heading\nwget https://example.com/dl/url2md_1.0.tgz\n```"
+	tail := "```\n[link](https://example.com/not-a-link) and <https://example.com/raw>\n```"
+
+	tests := []struct {
+		style LinkStyle
+		want  string
+	}{
+		{LinkInline, "Install it with `go install example.com/url2md@latest` or read the [docs](https://example.com/docs).\n\n" +
+			code + "\n\nThe API answers at `https://api.example.com/v1/`; see [the docs](https://example.com/docs) again.\n\n" + tail},
+		{LinkReferenced, "Install it with `go install example.com/url2md@latest` or read the [docs][1].\n\n" +
+			code + "\n\nThe API answers at `https://api.example.com/v1/`; see [the docs][1] again.\n\n" + tail +
+			"\n\n[1]: https://example.com/docs"},
+	}
+	for _, tt := range tests {
+		got, err := convertToMarkdown(base, page, &Options{CodeFence: true, LinkStyle: tt.style})
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Fatalf("LinkStyle %q: convertToMarkdown = %q, expected %q", tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestConvertToMarkdownRemovesScripts(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "scripts.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := convertToMarkdown(nil, page, &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	if want := "Scripts\n\nBefore the scripts.\n\nAfter the scripts."; got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+
+	got, err = convertToMarkdown(nil, page, &Options{KeepScripts: true})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	if !strings.Contains(got, "Template row") {
+		t.Fatalf("KeepScripts: convertToMarkdown = %q, expected the template content", got)
+	}
+}
+
+func TestConvertToMarkdownRemovesHidden(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "hidden.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := convertToMarkdown(nil, page, &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	want := "Hidden\n\n# Release notes\n\nVisible text.\n\nShown after all.\n\nShown with a variable.\n\nNot hidden.\n\nFound by search."
+	if got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+
+	got, err = convertToMarkdown(nil, page, &Options{KeepHidden: true})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	for _, text := range []string{"Hidden text.", "Collapsed menu.", "Cookie settings."} {
+		if !strings.Contains(got, text) {
+			t.Fatalf("KeepHidden: convertToMarkdown = %q, expected %q", got, text)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package url2md
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is a cookie jar shared by the Convert calls of a run and saved
+// between runs, so that cookies such as Cloudflare clearance survive. It is
+// safe for concurrent use.
+type CookieJar struct {
+	path string
+	jar  *cookiejar.Jar
+	mu   sync.Mutex
+	// entries holds the persistent cookies of jar by domain, path and name,
+	// with Domain in the cookies.txt form ParseCookieFile returns.
+	entries map[string]*http.Cookie
+}
+
+// OpenCookieJar loads the cookies.txt file at path into a new jar. A missing
+// file yields an empty jar; Save creates it.
+func OpenCookieJar(path string) (*CookieJar, error) {
+	inner, _ := cookiejar.New(nil)
+	j := &CookieJar{path: path, jar: inner, entries: map[string]*http.Cookie{}}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening cookie jar: %w", err)
+	}
+	defer f.Close()
+	cookies, err := ParseCookieFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("opening cookie jar: %s: %w", path, err)
+	}
+	j.Add(cookies)
+	return j, nil
+}
+
+// Add stores cookies as returned by ParseCookieFile, each scoped to the host
+// or domain it was exported for. Expired cookies are left out.
+func (j *CookieJar) Add(cookies []*http.Cookie) {
+	addCookies(j, cookies)
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	host := u.Hostname()
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		entry := *c
+		entry.Domain = host
+		if d := strings.TrimPrefix(strings.ToLower(c.Domain), "."); d != "" {
+			if net.ParseIP(host) != nil || (host != d && !strings.HasSuffix(host, "."+d)) {
+				// The jar rejects cookies for other domains.
+				continue
+			}
+			entry.Domain = "." + d
+		}
+		if entry.Path == "" || entry.Path[0] != '/' {
+			entry.Path = defaultCookiePath(u.Path)
+		}
+		if c.MaxAge > 0 {
+			entry.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		entry.MaxAge = 0
+		key := entry.Domain + ";" + entry.Path + ";" + entry.Name
+		if c.MaxAge < 0 || (!entry.Expires.IsZero() && !entry.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = &entry
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Save writes the cookies that have not expired to the file the jar was
+// opened from, in the cookies.txt format, replacing it atomically. Session
+// cookies, which have no expiry, are not saved, as a browser would drop them
+// too.
+func (j *CookieJar) Save() error {
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n# Written by url2md; cookies are secrets, keep this file private.\n\n")
+	if err := j.write(&b, time.Now()); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(j.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("saving cookie jar: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, b.String()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving cookie jar: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving cookie jar: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("saving cookie jar: %w", err)
+	}
+	return nil
+}
+
+// write prints the cookies that are persistent and still valid at now, one
+// cookies.txt line each, sorted by domain, path and name.
+func (j *CookieJar) write(w io.Writer, now time.Time) error {
+	j.mu.Lock()
+	keys := make([]string, 0, len(j.entries))
+	for key, c := range j.entries {
+		if !c.Expires.IsZero() && c.Expires.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		c := j.entries[key]
+		prefix := ""
+		if c.HttpOnly {
+			prefix = httpOnlyPrefix
+		}
+		lines = append(lines, prefix+strings.Join([]string{
+			c.Domain,
+			strings.ToUpper(strconv.FormatBool(strings.HasPrefix(c.Domain, "."))),
+			c.Path,
+			strings.ToUpper(strconv.FormatBool(c.Secure)),
+			strconv.FormatInt(c.Expires.Unix(), 10),
+			c.Name,
+			c.Value,
+		}, "\t"))
+	}
+	j.mu.Unlock()
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultCookiePath returns the path a cookie without a Path attribute set
+// for a URL with path applies to, as defined by RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if path == "" || path[0] != '/' || i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,101 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieJarPersistsBetweenRuns(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Path+" "+r.Header.Get("Cookie"))
+		if r.URL.Path == "/" {
+			http.SetCookie(w, &http.Cookie{Name: "cf_clearance", Value: "ok", Path: "/", MaxAge: 3600, HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "session", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "foreign", Value: "x", Domain: "example.org", MaxAge: 3600})
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>page</p>"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "jar.txt")
+	jar, err := OpenCookieJar(path)
+	if err != nil {
+		t.Fatalf("OpenCookieJar returned error: %v", err)
+	}
+	for _, page := range []string{"/a", "/b"} {
+		if _, err := Convert(context.Background(), srv.URL+page, Options{CookieJar: jar, NoProxy: true}); err != nil {
+			t.Fatalf("Convert returned error: %v", err)
+		}
+	}
+	want := []string{"/ ", "/a cf_clearance=ok; sid=session", "/b cf_clearance=ok; sid=session"}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests = %q, expected %q: one warm-up, then the cookies it set", sent, want)
+	}
+	if err := jar.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t") || strings.Contains(string(data), "sid") || strings.Contains(string(data), "foreign") {
+		t.Fatalf("saved jar:\n%s\nexpected only cf_clearance", data)
+	}
+
+	sent = nil
+	jar, err = OpenCookieJar(path)
+	if err != nil {
+		t.Fatalf("OpenCookieJar returned error: %v", err)
+	}
+	if _, err := Convert(context.Background(), srv.URL+"/c", Options{CookieJar: jar, NoProxy: true}); err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if got := sent[0]; got != "/c cf_clearance=ok" {
+		t.Fatalf("next run sent %q, expected the saved cf_clearance", got)
+	}
+}
+
+func TestCookieJarExpiry(t *testing.T) {
+	jar, _ := OpenCookieJar(filepath.Join(t.TempDir(), "jar.txt"))
+	u, _ := url.Parse("https://www.example.com/docs/page")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "short", Value: "1", MaxAge: 60},
+		{Name: "long", Value: "2", Domain: "example.com", Expires: time.Now().Add(48 * time.Hour), Secure: true},
+		{Name: "gone", Value: "3", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	var b strings.Builder
+	jar.write(&b, time.Now())
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], ".example.com\tTRUE\t/docs\tTRUE\t") || !strings.HasPrefix(lines[1], "www.example.com\tFALSE\t/docs\tFALSE\t") {
+		t.Fatalf("jar written as:\n%s", b.String())
+	}
+
+	b.Reset()
+	jar.write(&b, time.Now().Add(time.Hour))
+	if got := b.String(); strings.Contains(got, "short") || !strings.Contains(got, "long") {
+		t.Fatalf("jar written an hour later as:\n%s\nexpected only the long cookie", got)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "long", Domain: "example.com", Path: "/docs", MaxAge: -1}})
+	b.Reset()
+	jar.write(&b, time.Now())
+	if strings.Contains(b.String(), "long") {
+		t.Fatalf("deleted cookie still written:\n%s", b.String())
+	}
+}
+
+func TestDefaultCookiePath(t *testing.T) {
+	for in, want := range map[string]string{"": "/", "/": "/", "/page": "/", "/docs/page": "/docs", "/a/b/": "/a/b", "x": "/"} {
+		if got := defaultCookiePath(in); got != want {
+			t.Fatalf("defaultCookiePath(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package url2md
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in the files written by curl and
+// browser extensions.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ParseCookieFile reads cookies in the Netscape cookies.txt format exported
+// by browsers and curl: one cookie per line with the domain, the
+// include-subdomains flag, the path, the secure flag, the expiry as a Unix
+// time (0 for session cookies), the name and the value, separated by tabs.
+// Blank lines and comments are skipped. As in the file, the Domain of cookies
+// shared with subdomains starts with a dot, and that of host-only cookies is
+// the bare host.
+func ParseCookieFile(r io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, classify(KindInvalidInput, fmt.Errorf("cookie file line %d: expected 7 tab-separated fields, got %d", n, len(fields)))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, classify(KindInvalidInput, fmt.Errorf("cookie file line %d: invalid expiry %q", n, fields[4]))
+		}
+		domain := strings.TrimPrefix(fields[0], ".")
+		if domain == "" {
+			return nil, classify(KindInvalidInput, fmt.Errorf("cookie file line %d: missing domain", n))
+		}
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		c.Domain = domain
+		if strings.EqualFold(fields[1], "TRUE") {
+			c.Domain = "." + domain
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// addCookies stores cookies in jar, each scoped to the host or domain it was
+// exported for, so that the jar only sends it to matching hosts. Expired
+// cookies are left out.
+func addCookies(jar http.CookieJar, cookies []*http.Cookie) {
+	now := time.Now()
+	for _, c := range cookies {
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+		host, shared := strings.CutPrefix(c.Domain, ".")
+		scoped := *c
+		if !shared {
+			// Without a Domain the jar keeps it as a host-only cookie.
+			scoped.Domain = ""
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{&scoped})
+	}
+}
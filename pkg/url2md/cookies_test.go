@@ -0,0 +1,108 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCookieFile(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "cookies.txt"))
+	if err != nil {
+		t.Fatalf("opening fixture: %v", err)
+	}
+	defer f.Close()
+	cookies, err := ParseCookieFile(f)
+	if err != nil {
+		t.Fatalf("ParseCookieFile returned error: %v", err)
+	}
+	if len(cookies) != 5 {
+		t.Fatalf("got %d cookies, expected 5", len(cookies))
+	}
+
+	session := cookies[0]
+	if session.Name != "session" || session.Value != "abc123" || session.Domain != ".example.com" || !session.Secure || session.HttpOnly {
+		t.Fatalf("session cookie = %+v", session)
+	}
+	if !session.Expires.Equal(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("session expires %v, expected 2100-01-01", session.Expires)
+	}
+	csrf := cookies[1]
+	if csrf.Name != "csrf" || csrf.Domain != "www.example.com" || csrf.Path != "/account" || !csrf.HttpOnly || !csrf.Expires.IsZero() {
+		t.Fatalf("csrf cookie = %+v", csrf)
+	}
+}
+
+func TestParseCookieFileErrors(t *testing.T) {
+	for _, file := range []string{
+		"example.com\tFALSE\t/\tFALSE\t0\tname",
+		"example.com\tFALSE\t/\tFALSE\tsoon\tname\tvalue",
+		"\tFALSE\t/\tFALSE\t0\tname\tvalue",
+	} {
+		if _, err := ParseCookieFile(strings.NewReader(file)); err == nil || Kind(err) != KindInvalidInput {
+			t.Fatalf("ParseCookieFile(%q) error = %v, expected KindInvalidInput", file, err)
+		}
+	}
+}
+
+func TestConvertSendsCookiesToMatchingHosts(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "cookies.txt"))
+	if err != nil {
+		t.Fatalf("opening fixture: %v", err)
+	}
+	defer f.Close()
+	cookies, err := ParseCookieFile(f)
+	if err != nil {
+		t.Fatalf("ParseCookieFile returned error: %v", err)
+	}
+
+	var warmup, page string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			warmup = r.Header.Get("Cookie")
+			return
+		}
+		page = r.Header.Get("Cookie")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>members only</p>"))
+	}))
+	defer srv.Close()
+
+	if _, err := Convert(context.Background(), srv.URL+"/page", Options{Cookies: cookies, NoProxy: true, Logf: t.Logf}); err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	for name, got := range map[string]string{"warm-up": warmup, "page": page} {
+		if got != "local=yes" {
+			t.Fatalf("%s request sent Cookie %q, expected only local=yes", name, got)
+		}
+	}
+}
+
+func TestAddCookiesScopesByDomain(t *testing.T) {
+	client := newClient(&Options{Cookies: []*http.Cookie{
+		{Name: "shared", Value: "1", Domain: ".example.com", Path: "/"},
+		{Name: "host", Value: "2", Domain: "www.example.com", Path: "/"},
+	}})
+	tests := map[string]string{
+		"https://example.com/":      "shared",
+		"https://www.example.com/":  "shared host",
+		"https://docs.example.com/": "shared",
+		"https://example.org/":      "",
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		var names []string
+		for _, c := range client.Jar.Cookies(u) {
+			names = append(names, c.Name)
+		}
+		if got := strings.Join(names, " "); got != want {
+			t.Fatalf("cookies for %s = %q, expected %q", raw, got, want)
+		}
+	}
+}
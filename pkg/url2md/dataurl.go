@@ -0,0 +1,60 @@
+package url2md
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// defaultDataMediaType is the media type of data: URLs that do not name one,
+// as RFC 2397 specifies.
+const defaultDataMediaType = "text/plain;charset=US-ASCII"
+
+// fetchData returns the payload of a data: URL as fetchHTML would return a
+// downloaded page, without any network access. Base64 and percent-encoded
+// payloads are both supported.
+func fetchData(target *url.URL, opts *Options) (fetchResult, error) {
+	mediaType, payload, ok := strings.Cut(target.Opaque, ",")
+	if !ok {
+		return fetchResult{}, classify(KindInvalidInput, fmt.Errorf("invalid data URL: missing comma"))
+	}
+	mediaType, isBase64 := strings.CutSuffix(mediaType, ";base64")
+	if mediaType == "" || strings.HasPrefix(mediaType, ";") {
+		mediaType = defaultDataMediaType
+	}
+
+	unescaped, err := url.PathUnescape(payload)
+	if err != nil {
+		return fetchResult{}, classify(KindInvalidInput, fmt.Errorf("invalid data URL: %w", err))
+	}
+	data := []byte(unescaped)
+	if isBase64 {
+		encoded := strings.Join(strings.Fields(unescaped), "")
+		if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "=")); err != nil {
+				return fetchResult{}, classify(KindInvalidInput, fmt.Errorf("invalid data URL: %w", err))
+			}
+		}
+	}
+	if data, err = readLimited(bytes.NewReader(data), opts.MaxSize); err != nil {
+		return fetchResult{}, classifyRead(err)
+	}
+
+	isHTML, err := classifyContentType(mediaType, target, opts)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	undecoded := false
+	if isHTML {
+		if decoded, err := decodeCharset(data, mediaType); err != nil {
+			opts.warn("Keeping original bytes", "error", err)
+			undecoded = true
+		} else {
+			data = decoded
+		}
+	}
+	opts.info("Decoded data URL", "type", mediaType, "bytes", len(data))
+	return fetchResult{body: data, url: target, contentType: mediaType, isHTML: isHTML, undecoded: undecoded}, nil
+}
@@ -0,0 +1,68 @@
+package url2md
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func TestConvertDataURL(t *testing.T) {
+	page := `<html><head><title>Data</title></head><body><h1>Hello</h1><p>See <a href="/docs">the docs</a>.</p></body></html>`
+	dataURL := "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(page))
+
+	res, err := Convert(context.Background(), dataURL, Options{Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if want := "Data\n\n# Hello\n\nSee [the docs](/docs)."; res.Markdown != want {
+		t.Fatalf("Markdown = %q, expected %q", res.Markdown, want)
+	}
+	if res.Title != "Data" {
+		t.Fatalf("Title = %q, expected Data", res.Title)
+	}
+
+	base, _ := url.Parse("https://example.com/guide/")
+	res, err = Convert(context.Background(), dataURL, Options{BaseURL: base, Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if want := "Data\n\n# Hello\n\nSee [the docs](https://example.com/docs)."; res.Markdown != want {
+		t.Fatalf("Markdown with BaseURL = %q, expected %q", res.Markdown, want)
+	}
+}
+
+func TestFetchDataURL(t *testing.T) {
+	tests := []struct {
+		raw    string
+		body   string
+		isHTML bool
+	}{
+		{"data:text/html,%3Ch1%3EHi%20there%3C%2Fh1%3E", "<h1>Hi there</h1>", true},
+		{"data:text/html;charset=utf-8;base64,PHA+Y2Fmw6k8L3A+", "<p>café</p>", true},
+		{"data:text/html;base64,PHA+YTwvcD4=", "<p>a</p>", true},
+		{"data:text/html;base64,PHA+YTwvcD4", "<p>a</p>", true},
+		{"data:,plain%20text", "plain text", false},
+		{"data:text/markdown,%23%20Title", "# Title", false},
+	}
+	for _, tt := range tests {
+		target, err := ParseURL(tt.raw)
+		if err != nil {
+			t.Fatalf("ParseURL(%q) returned error: %v", tt.raw, err)
+		}
+		page, err := fetchHTML(context.Background(), nil, target, &Options{})
+		if err != nil {
+			t.Fatalf("fetchHTML(%q) returned error: %v", tt.raw, err)
+		}
+		if string(page.body) != tt.body || page.isHTML != tt.isHTML {
+			t.Fatalf("fetchHTML(%q) = %q, isHTML %v, expected %q, %v", tt.raw, page.body, page.isHTML, tt.body, tt.isHTML)
+		}
+	}
+
+	for _, raw := range []string{"data:text/html;base64", "data:text/html;base64,!!!", "data:image/png;base64,iVBORw0KGgo="} {
+		target, _ := ParseURL(raw)
+		if _, err := fetchHTML(context.Background(), nil, target, &Options{}); err == nil {
+			t.Fatalf("fetchHTML(%q) succeeded, expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package url2md
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// emojiNames maps common Unicode emoji to their GitHub shortcode names, as
+// used by Options.EmojiShortcodes.
+var emojiNames = map[rune]string{
+	// Faces.
+	'😀': "grinning", '😃': "smiley", '😄': "smile", '😁': "grin", '😆': "laughing",
+	'😅': "sweat_smile", '🤣': "rofl", '😂': "joy", '🙂': "slightly_smiling_face",
+	'🙃': "upside_down_face", '😉': "wink", '😊': "blush", '😇': "innocent",
+	'😍': "heart_eyes", '🤩': "star_struck", '😘': "kissing_heart", '😋': "yum",
+	'😛': "stuck_out_tongue", '😜': "stuck_out_tongue_winking_eye", '🤔': "thinking",
+	'🤨': "raised_eyebrow", '😐': "neutral_face", '😑': "expressionless",
+	'😶': "no_mouth", '😏': "smirk", '😒': "unamused", '🙄': "roll_eyes",
+	'😬': "grimacing", '😌': "relieved", '😔': "pensive", '😪': "sleepy",
+	'😴': "sleeping", '😷': "mask", '🤯': "exploding_head", '😎': "sunglasses",
+	'🤓': "nerd_face", '😕': "confused", '😟': "worried", '😮': "open_mouth",
+	'😲': "astonished", '😳': "flushed", '😢': "cry", '😭': "sob", '😱': "scream",
+	'😞': "disappointed", '😓': "sweat", '😩': "weary", '😫': "tired_face",
+	'😤': "triumph", '😡': "rage", '😠': "angry", '💀': "skull", '💩': "hankey",
+	'🤡': "clown_face", '👻': "ghost", '👽': "alien", '🤖': "robot",
+
+	// Hands and people.
+	'👋': "wave", '👌': "ok_hand", '✌': "v", '🤞': "crossed_fingers",
+	'👈': "point_left", '👉': "point_right", '👆': "point_up_2", '👇': "point_down",
+	'☝': "point_up", '👍': "+1", '👎': "-1", '✊': "fist", '👊': "punch",
+	'👏': "clap", '🙌': "raised_hands", '🙏': "pray", '💪': "muscle", '👀': "eyes",
+	'🧠': "brain",
+
+	// Hearts and symbols.
+	'❤': "heart", '🧡': "orange_heart", '💛': "yellow_heart", '💚': "green_heart",
+	'💙': "blue_heart", '💜': "purple_heart", '🖤': "black_heart",
+	'💔': "broken_heart", '💯': "100", '💥': "boom", '💫': "dizzy",
+	'💬': "speech_balloon", '💤': "zzz", '✅': "white_check_mark",
+	'✔': "heavy_check_mark", '❌': "x", '❎': "negative_squared_cross_mark",
+	'❗': "exclamation", '❓': "question", '⚠': "warning", '🚫': "no_entry_sign",
+	'⛔': "no_entry", '🔴': "red_circle", '🟢': "green_circle",
+	'🔵': "large_blue_circle", '➕': "heavy_plus_sign", '➖': "heavy_minus_sign",
+	'♻': "recycle", '🆕': "new", '🆗': "ok",
+
+	// Nature and weather.
+	'🔥': "fire", '✨': "sparkles", '⭐': "star", '🌟': "star2", '⚡': "zap",
+	'☀': "sunny", '🌈': "rainbow", '❄': "snowflake", '☔': "umbrella",
+	'🐶': "dog", '🐱': "cat", '🐍': "snake", '🦄': "unicorn", '🐳': "whale",
+	'🐧': "penguin", '🐛': "bug", '🌍': "earth_africa", '🌎': "earth_americas",
+	'🌐': "globe_with_meridians",
+
+	// Objects and activities.
+	'🎉': "tada", '🎊': "confetti_ball", '🎁': "gift", '🏆': "trophy",
+	'🥇': "1st_place_medal", '🚀': "rocket", '✈': "airplane", '🚗': "car",
+	'🏠': "house", '💡': "bulb", '🔧': "wrench", '🔨': "hammer",
+	'🛠': "hammer_and_wrench", '⚙': "gear", '🔒': "lock", '🔓': "unlock",
+	'🔑': "key", '📝': "memo", '📌': "pushpin", '📎': "paperclip",
+	'📦': "package", '📚': "books", '📖': "book", '📄': "page_facing_up",
+	'📅': "date", '📈': "chart_with_upwards_trend",
+	'📉': "chart_with_downwards_trend", '📊': "bar_chart", '🔍': "mag",
+	'🔗': "link", '🚧': "construction", '🏷': "label", '💻': "computer",
+	'📱': "iphone", '⌛': "hourglass", '⏰': "alarm_clock", '🔔': "bell",
+	'📣': "mega", '🎯': "dart", '🧪': "test_tube", '🗑': "wastebasket",
+	'🚨': "rotating_light", '☕': "coffee", '🍕': "pizza", '🍺': "beer",
+}
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	emojiPresentation = "\ufe0f"
+	firstSkinTone     = '\U0001F3FB'
+	lastSkinTone      = '\U0001F3FF'
+)
+
+// shortcodeEmojiHTML replaces the emoji in the text of page with GitHub
+// shortcodes. Code, preformatted text, scripts and styles are left alone.
+func shortcodeEmojiHTML(page []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Pre, atom.Code, atom.Kbd, atom.Samp, atom.Script, atom.Style, atom.Textarea:
+			return
+		}
+		if n.Type == html.TextNode {
+			n.Data = shortcodeEmoji(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+	out, err := doc.Html()
+	return []byte(out), err
+}
+
+// emojiImageClasses are the classes that mark an <img> as an emoji: GitHub
+// and WordPress use "emoji", older WordPress themes "wp-smiley" and
+// Twemoji "twemoji".
+var emojiImageClasses = []string{"emoji", "wp-smiley", "twemoji"}
+
+// textEmojiImages replaces the emoji images of page with their alt text, so
+// that <img class="emoji" alt="😄" src="…"> becomes 😄 instead of an image.
+// An image is an emoji when it has one of emojiImageClasses and an alt
+// text, which may also be a shortcode like :shipit:, or when its alt text
+// is a single emoji.
+func textEmojiImages(page []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	doc.Find("img[alt]").Each(func(_ int, img *goquery.Selection) {
+		alt := strings.TrimSpace(img.AttrOr("alt", ""))
+		if alt == "" || !(isEmoji(alt) || hasEmojiClass(img)) {
+			return
+		}
+		img.ReplaceWithNodes(&html.Node{Type: html.TextNode, Data: alt})
+	})
+	out, err := doc.Html()
+	return []byte(out), err
+}
+
+func hasEmojiClass(img *goquery.Selection) bool {
+	for _, class := range emojiImageClasses {
+		if img.HasClass(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmoji reports whether s is a single emoji, possibly with variation
+// selectors, skin tones or joined into a sequence like 👩‍💻. Flags and
+// keycaps such as 1️⃣ count too.
+func isEmoji(s string) bool {
+	pictographs := 0
+	for i, r := range s {
+		switch {
+		case r == zeroWidthJoiner, r == '\ufe0f', r == '\u20e3', r >= '\U000E0020' && r <= '\U000E007F':
+		case i == 0 && (r == '#' || r == '*' || r >= '0' && r <= '9') && strings.Contains(s, "\u20e3"):
+		case r >= '\U0001F000' && r <= '\U0001FAFF', r >= '\u2300' && r <= '\u23FF',
+			r >= '\u2600' && r <= '\u27BF', r >= '\u2B00' && r <= '\u2BFF', emojiNames[r] != "":
+			pictographs++
+		default:
+			return false
+		}
+	}
+	return pictographs > 0 || strings.Contains(s, "\u20e3")
+}
+
+// shortcodeEmoji replaces the emoji of s found in emojiNames with their
+// shortcodes, such as ":tada:". Emoji carrying a skin tone or joined into a
+// sequence like 👩‍💻 have no shortcode of their own and are kept as they are.
+func shortcodeEmoji(s string) string {
+	var b strings.Builder
+	var prev rune
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if name, ok := emojiNames[r]; ok && prev != zeroWidthJoiner {
+			end := i + size
+			if strings.HasPrefix(s[end:], emojiPresentation) {
+				end += len(emojiPresentation)
+			}
+			next, _ := utf8.DecodeRuneInString(s[end:])
+			if next != zeroWidthJoiner && (next < firstSkinTone || next > lastSkinTone) {
+				b.WriteString(":" + name + ":")
+				i, prev = end, r
+				continue
+			}
+		}
+		b.WriteString(s[i : i+size])
+		i, prev = i+size, r
+	}
+	return b.String()
+}
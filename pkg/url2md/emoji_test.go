@@ -0,0 +1,104 @@
+package url2md
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertHTMLEmoji(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "emoji.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		shortcodes bool
+		want       string
+	}{
+		{false, "Release notes 🎉\n\n# Release 2.0 🚀\n\nThanks to everyone who helped ❤️ and 👍 to the reviewers.\n\n" +
+			"Works on 👩‍💻 laptops, says 👍🏽, and fixes the ❤️‍🔥 bug 🐛.\n\n" +
+			"Type `:tada: 🎉` to celebrate, or just write :smile: yourself.\n\n```\necho \"✅ done\"\n```"},
+		{true, "Release notes :tada:\n\n# Release 2.0 :rocket:\n\nThanks to everyone who helped :heart: and :+1: to the reviewers.\n\n" +
+			"Works on 👩‍💻 laptops, says 👍🏽, and fixes the ❤️‍🔥 bug :bug:.\n\n" +
+			"Type `:tada: 🎉` to celebrate, or just write :smile: yourself.\n\n```\necho \"✅ done\"\n```"},
+	}
+	for _, tt := range tests {
+		res, err := ConvertHTML(context.Background(), page, nil, Options{EmojiShortcodes: tt.shortcodes})
+		if err != nil {
+			t.Fatalf("shortcodes %v: ConvertHTML returned error: %v", tt.shortcodes, err)
+		}
+		if res.Markdown != tt.want {
+			t.Fatalf("shortcodes %v: markdown = %q, expected %q", tt.shortcodes, res.Markdown, tt.want)
+		}
+		if res.Title != "Release notes 🎉" {
+			t.Fatalf("shortcodes %v: title = %q, expected the emoji kept", tt.shortcodes, res.Title)
+		}
+	}
+}
+
+func TestShortcodeEmoji(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"no emoji":   "no emoji",
+		"🎉🎉":         ":tada::tada:",
+		"ok ✔ done":  "ok :heavy_check_mark: done",
+		"a❤️b":       "a:heart:b",
+		"👩‍💻 and 🧑🏻": "👩‍💻 and 🧑🏻",
+	}
+	for in, want := range cases {
+		if got := shortcodeEmoji(in); got != want {
+			t.Fatalf("shortcodeEmoji(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestConvertHTMLEmojiImages(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "emoji-images.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://github.com/example/repo/issues/42")
+
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{EmojiImages: true}, "Issue #42\n\nFixed in the last release 🎉 thanks!\n\nShip it :shipit:\n\nWordPress 😄 and a flag 🇮🇹\n\n" +
+			"![Screenshot of the settings page](https://github.com/img/screenshot.png)"},
+		{Options{EmojiImages: true, EmojiShortcodes: true}, "Issue #42\n\nFixed in the last release :tada: thanks!\n\nShip it :shipit:\n\nWordPress :smile: and a flag 🇮🇹\n\n" +
+			"![Screenshot of the settings page](https://github.com/img/screenshot.png)"},
+	}
+	for _, tt := range tests {
+		res, err := ConvertHTML(context.Background(), page, base, tt.opts)
+		if err != nil {
+			t.Fatalf("ConvertHTML returned error: %v", err)
+		}
+		if res.Markdown != tt.want {
+			t.Fatalf("shortcodes %v: markdown = %q, expected %q", tt.opts.EmojiShortcodes, res.Markdown, tt.want)
+		}
+	}
+
+	res, err := ConvertHTML(context.Background(), page, base, Options{})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "![🎉](https://github.githubassets.com/") {
+		t.Fatalf("markdown = %q, expected emoji images kept by default", res.Markdown)
+	}
+}
+
+func TestIsEmoji(t *testing.T) {
+	cases := map[string]bool{
+		"😄": true, "❤️": true, "👍🏽": true, "👩‍💻": true, "🇮🇹": true, "1️⃣": true, "#️⃣": true,
+		"": false, "smile": false, ":shipit:": false, "1": false, "2024": false, "😄 face": false, "©": false,
+	}
+	for in, want := range cases {
+		if got := isEmoji(in); got != want {
+			t.Fatalf("isEmoji(%q) = %v, expected %v", in, got, want)
+		}
+	}
+}
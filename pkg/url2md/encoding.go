@@ -0,0 +1,52 @@
+package url2md
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is advertised on the page request. Setting it ourselves
+// disables the transport's transparent gzip handling, so responses are
+// decoded by decodeContentEncoding.
+const acceptEncoding = "gzip, br"
+
+// decodeContentEncoding undoes the codings listed in a Content-Encoding header,
+// last applied first. Unknown codings leave the bytes as they are. Decoded
+// bodies larger than limit fail with ErrTooLarge, see readLimited.
+func decodeContentEncoding(body []byte, contentEncoding string, limit int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		case "deflate":
+			// Servers disagree on whether deflate means zlib-wrapped or raw.
+			if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(body))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			continue
+		}
+		decoded, err := readLimited(r, limit)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	return body, nil
+}
@@ -0,0 +1,82 @@
+package url2md
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const encodingPage = "<html><body><h1>Compressed</h1></body></html>"
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compressing with %s: %v", encoding, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing with %s: %v", encoding, err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchHTMLDecompressesBody(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br", "identity", "zstd-unknown"} {
+		t.Run(encoding, func(t *testing.T) {
+			var gotAccept string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/page" {
+					return
+				}
+				gotAccept = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(compress(t, encoding, []byte(encodingPage)))
+			}))
+			defer srv.Close()
+
+			opts := &Options{UserAgent: DefaultUserAgent, MaxRedirects: 10, Logf: t.Logf}
+			target, _ := url.Parse(srv.URL + "/page")
+			page, err := fetchHTML(context.Background(), newClient(opts), target, opts)
+			if err != nil {
+				t.Fatalf("fetchHTML returned error: %v", err)
+			}
+			if string(page.body) != encodingPage {
+				t.Fatalf("body = %q, expected %q", page.body, encodingPage)
+			}
+			if gotAccept != acceptEncoding {
+				t.Fatalf("Accept-Encoding = %q, expected %q", gotAccept, acceptEncoding)
+			}
+		})
+	}
+}
+
+func TestDecodeContentEncodingStacked(t *testing.T) {
+	data := compress(t, "br", compress(t, "gzip", []byte(encodingPage)))
+	got, err := decodeContentEncoding(data, "gzip, br", 0)
+	if err != nil {
+		t.Fatalf("decodeContentEncoding returned error: %v", err)
+	}
+	if string(got) != encodingPage {
+		t.Fatalf("decodeContentEncoding = %q, expected %q", got, encodingPage)
+	}
+}
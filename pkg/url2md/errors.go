@@ -0,0 +1,47 @@
+package url2md
+
+import "errors"
+
+// ErrorKind classifies why a conversion failed.
+type ErrorKind int
+
+const (
+	// KindUnknown is reported for errors that carry no classification.
+	KindUnknown ErrorKind = iota
+	// KindInvalidInput covers malformed URLs, selectors and options.
+	KindInvalidInput
+	// KindNetwork covers DNS, connection and timeout failures.
+	KindNetwork
+	// KindHTTPStatus is an error status returned by the origin.
+	KindHTTPStatus
+	// KindProxy means the origin blocked the request and the proxy fallback
+	// failed as well.
+	KindProxy
+	// KindConversion covers failures while turning the page into Markdown.
+	KindConversion
+	// KindTooLarge means a response body exceeded Options.MaxSize.
+	KindTooLarge
+)
+
+// Error is a classified error returned by Convert and SitemapURLs.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Kind returns the classification of err, or KindUnknown if it has none.
+func Kind(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+func classify(kind ErrorKind, err error) error {
+	return &Error{Kind: kind, Err: err}
+}
@@ -0,0 +1,57 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvertClassifiesErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/blocked", strings.HasPrefix(r.URL.Path, "/proxy/"):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<p>page</p>")
+		}
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		opts Options
+		want ErrorKind
+	}{
+		{"status", srv.URL + "/missing", Options{NoProxy: true}, KindHTTPStatus},
+		{"blocked without proxy", srv.URL + "/blocked", Options{NoProxy: true}, KindHTTPStatus},
+		{"proxy", srv.URL + "/blocked", Options{ProxyURL: srv.URL + "/proxy/"}, KindProxy},
+		{"network", closed.URL + "/page", Options{NoProxy: true}, KindNetwork},
+		{"selector", srv.URL + "/page", Options{Select: "a[", NoProxy: true}, KindInvalidInput},
+		{"connect timeout", srv.URL + "/page", Options{ConnectTimeout: -time.Second, NoProxy: true}, KindInvalidInput},
+		{"no match", srv.URL + "/page", Options{Select: "article", NoProxy: true}, KindConversion},
+	}
+	for _, tt := range tests {
+		_, err := Convert(context.Background(), tt.url, tt.opts)
+		if err == nil {
+			t.Fatalf("%s: Convert returned no error", tt.name)
+		}
+		if got := Kind(err); got != tt.want {
+			t.Fatalf("%s: Kind(%v) = %d, expected %d", tt.name, err, got, tt.want)
+		}
+	}
+
+	if Kind(errors.New("plain")) != KindUnknown {
+		t.Fatalf("Kind of an unclassified error is not KindUnknown")
+	}
+}
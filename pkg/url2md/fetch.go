@@ -0,0 +1,692 @@
+package url2md
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// maxIdleConnsPerHost is how many idle connections to one host a transport
+// keeps for reuse. http.DefaultTransport keeps two, fewer than a batch run
+// usually has in flight against the same site.
+const maxIdleConnsPerHost = 16
+
+// newClient returns the HTTP client used for every request made for one URL:
+// the warm-up, the page itself and any images it references. It goes through
+// opts.Transport when set, or else a transport of its own from NewTransport.
+// Unless opts.CookieJar is set the cookie jar is never shared, so cookies do
+// not leak between URLs; it starts with opts.Cookies.
+func newClient(opts *Options) *http.Client {
+	var jar http.CookieJar = opts.CookieJar
+	if opts.CookieJar == nil {
+		own, _ := cookiejar.New(nil)
+		addCookies(own, opts.Cookies)
+		jar = own
+	}
+	var transport http.RoundTripper = opts.Transport
+	if transport == nil {
+		transport = NewTransport(*opts)
+	}
+	return &http.Client{
+		Jar:       jar,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", len(via)-1)
+			}
+			opts.debug("Redirected", "url", req.URL.String())
+			return nil
+		},
+	}
+}
+
+// NewTransport returns the transport Convert uses to fetch pages and images,
+// set up from opts.HTTPProxy, opts.ConnectTimeout, opts.RootCAs and
+// opts.InsecureSkipVerify. It speaks HTTP/2 where the server supports it.
+// Use it as Options.Transport to share one connection pool between the
+// conversions of a batch.
+func NewTransport(opts Options) *http.Transport {
+	transport := newTransport(&opts)
+	if opts.RootCAs != nil || opts.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:            opts.RootCAs,
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+		}
+	}
+	return transport
+}
+
+// NewProxyTransport returns the transport Convert uses for the requests to
+// opts.ProxyURL, set up from opts.HTTPProxy and opts.ConnectTimeout. Use it
+// as Options.ProxyTransport to share one connection pool to the proxy
+// between the conversions of a batch.
+func NewProxyTransport(opts Options) *http.Transport {
+	return newTransport(&opts)
+}
+
+// newTransport returns a transport that sends requests through opts.HTTPProxy
+// or, when it is empty, the proxy named by HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY. Unlike http.ProxyFromEnvironment, the environment is read each
+// time a transport is created rather than once per process. Unlike
+// NewTransport, it leaves TLS verification alone, as the proxy fallback needs.
+func newTransport(opts *Options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A custom TLSClientConfig would otherwise turn HTTP/2 off.
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	if opts.ConnectTimeout > 0 {
+		// Same keep-alive as http.DefaultTransport; only the dial timeout,
+		// which covers the DNS lookup and the TCP handshake, changes.
+		transport.DialContext = (&net.Dialer{
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	fromEnv := httpproxy.FromEnvironment().ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return fromEnv(req.URL)
+	}
+	if opts.HTTPProxy != "" {
+		if u, err := url.Parse(opts.HTTPProxy); err == nil {
+			transport.Proxy = http.ProxyURL(u)
+		}
+	}
+	return transport
+}
+
+// fetchResult is a page downloaded by fetchHTML.
+type fetchResult struct {
+	// body is the decoded response body.
+	body []byte
+	// url is the URL the page was finally served from after redirects.
+	url *url.URL
+	// statusCode and contentType come from the response. They are zero for
+	// the proxy fallback.
+	statusCode  int
+	contentType string
+	// header holds the response headers, nil for the proxy fallback.
+	header http.Header
+	// validators are those of the response, empty for the proxy fallback.
+	validators Validators
+	// redirects is the number of HTTP redirects followed to reach url.
+	redirects int
+	// isHTML reports whether body is HTML that still needs to be converted.
+	isHTML bool
+	// undecoded is set when body is HTML left in a charset decodeCharset
+	// could not transcode.
+	undecoded bool
+	// viaProxy reports whether body was obtained through the proxy fallback.
+	viaProxy bool
+}
+
+// fetchHTML downloads target and returns the page along with the response
+// metadata later steps need. data: URLs are decoded instead.
+func fetchHTML(ctx context.Context, client *http.Client, target *url.URL, opts *Options) (fetchResult, error) {
+	switch target.Scheme {
+	case "data":
+		return fetchData(target, opts)
+	case "http", "https":
+	default:
+		return fetchResult{}, classify(KindInvalidInput, unsupportedScheme(target.Scheme))
+	}
+	hostBase := target.Scheme + "://" + target.Host
+
+	if opts.HeadCheck {
+		if err := headCheck(ctx, client, target, opts); err != nil {
+			return fetchResult{}, err
+		}
+	}
+
+	// Warm-up request to capture any cookies/challenges that are required for the main document.
+	if opts.NoWarmup {
+		opts.debug("Skipping warm-up request")
+	} else if opts.CookieJar != nil && len(opts.CookieJar.Cookies(target)) > 0 {
+		opts.debug("Skipping warm-up request, the cookie jar already has cookies for the page")
+	} else if warmupReq, err := http.NewRequestWithContext(ctx, http.MethodGet, hostBase+"/", nil); err == nil {
+		applyBrowserHeaders(warmupReq, target, opts, false)
+		applyBasicAuth(warmupReq, opts)
+		if err := opts.RateLimiter.wait(ctx, target.Host, opts); err != nil {
+			return fetchResult{}, classify(KindNetwork, err)
+		}
+		start := time.Now()
+		if resp, err := client.Do(warmupReq); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		opts.debug("Warm-up request done", "duration", since(start))
+	}
+
+	start := time.Now()
+	resp, err := doWithRetry(ctx, client, func() (*http.Request, error) {
+		if err := opts.RateLimiter.wait(ctx, target.Host, opts); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		applyBrowserHeaders(req, target, opts, true)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		applyBasicAuth(req, opts)
+		for name, values := range opts.Header {
+			req.Header[name] = values
+		}
+		if opts.Cache != nil {
+			opts.Cache.applyConditional(req, target.String())
+		}
+		if !opts.ModifiedSince.IsZero() {
+			applyModifiedSince(req, opts.ModifiedSince)
+		}
+		return req, nil
+	}, opts)
+	if err != nil {
+		return fetchResult{}, classify(KindNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if isBlockStatus(resp.StatusCode) {
+		// The body only helps to name the firewall, so errors reading it
+		// are ignored.
+		body, err := readLimited(resp.Body, opts.MaxSize)
+		if encoding := resp.Header.Get("Content-Encoding"); err == nil && encoding != "" {
+			body, _ = decodeContentEncoding(body, encoding, opts.MaxSize)
+		}
+		logErrorBody(resp, body, opts)
+		reason := fmt.Sprintf("Received %d from origin", resp.StatusCode)
+		if name := detectChallenge(resp, body); name != "" {
+			reason = "Hit " + name + " challenge"
+		}
+		return proxyFallback(ctx, target, reason, resp.Status, opts)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && (opts.Cache != nil || !opts.ModifiedSince.IsZero()) {
+		return fetchResult{}, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, challengeSniffSize))
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+			body, _ = decodeContentEncoding(body, encoding, opts.MaxSize)
+		}
+		logErrorBody(resp, body, opts)
+		return fetchResult{}, classify(KindHTTPStatus, fmt.Errorf("HTTP status %s", resp.Status))
+	}
+	if !opts.ModifiedSince.IsZero() {
+		// Servers may ignore If-Modified-Since and still send Last-Modified.
+		if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && !modified.After(opts.ModifiedSince) {
+			opts.info("Page not modified since the given time", "url", target.String(), "last_modified", modified.UTC().Format(time.RFC3339))
+			return fetchResult{}, ErrNotModified
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	isHTML, err := classifyContentType(contentType, resp.Request.URL, opts)
+	if err != nil {
+		return fetchResult{}, err
+	}
+
+	data, err := readLimited(resp.Body, opts.MaxSize)
+	if err != nil {
+		return fetchResult{}, classifyRead(err)
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		if data, err = decodeContentEncoding(data, encoding, opts.MaxSize); err != nil {
+			return fetchResult{}, classifyRead(fmt.Errorf("failed to decode %s response: %w", encoding, err))
+		}
+	}
+
+	undecoded := false
+	if isHTML {
+		if name := detectChallenge(resp, data); name != "" {
+			return proxyFallback(ctx, target, "Hit "+name+" challenge", resp.Status+" with a "+name+" challenge page", opts)
+		}
+		if decoded, err := decodeCharset(data, contentType); err != nil {
+			opts.warn("Keeping original bytes", "error", err)
+			undecoded = true
+		} else {
+			data = decoded
+		}
+	}
+
+	opts.info("Fetched page", "url", target.String(), "bytes", len(data), "duration", since(start))
+	return fetchResult{
+		body:        data,
+		url:         resp.Request.URL,
+		statusCode:  resp.StatusCode,
+		contentType: contentType,
+		header:      resp.Header,
+		validators:  responseValidators(target.String(), resp),
+		redirects:   countRedirects(resp),
+		isHTML:      isHTML,
+		undecoded:   undecoded,
+	}, nil
+}
+
+// countRedirects returns the number of HTTP redirects the client followed
+// before receiving resp.
+func countRedirects(resp *http.Response) int {
+	n := 0
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		n++
+	}
+	return n
+}
+
+// headCheck probes target with a HEAD request for Options.HeadCheck. It
+// fails when the page is missing or has a content type classifyContentType
+// rejects, and returns nil when the page request should follow: for HTML,
+// for statuses the page request handles itself, such as a block that calls
+// for the proxy, and when the server does not support HEAD.
+func headCheck(ctx context.Context, client *http.Client, target *url.URL, opts *Options) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return classify(KindInvalidInput, err)
+	}
+	applyBrowserHeaders(req, target, opts, true)
+	applyBasicAuth(req, opts)
+	for name, values := range opts.Header {
+		req.Header[name] = values
+	}
+	if err := opts.RateLimiter.wait(ctx, target.Host, opts); err != nil {
+		return classify(KindNetwork, err)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return classify(KindNetwork, err)
+	}
+	resp.Body.Close()
+	contentType := resp.Header.Get("Content-Type")
+	opts.info("HEAD check", "url", target.String(), "status", resp.StatusCode, "type", contentType, "duration", since(start))
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		opts.debug("Server rejects HEAD, sending the page request")
+		return nil
+	case isBlockStatus(resp.StatusCode):
+		return nil
+	case resp.StatusCode >= 400:
+		return classify(KindHTTPStatus, fmt.Errorf("HTTP status %s", resp.Status))
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil
+	}
+	_, err = classifyContentType(contentType, resp.Request.URL, opts)
+	return err
+}
+
+// errorPreviewSize is how much of an error response body is logged.
+const errorPreviewSize = 500
+
+// logErrorBody logs the start of the body of a non-2xx response at debug
+// level, since it often explains the error or names a captcha.
+func logErrorBody(resp *http.Response, body []byte, opts *Options) {
+	if decoded, err := decodeCharset(body, resp.Header.Get("Content-Type")); err == nil {
+		body = decoded
+	}
+	if preview := bodyPreview(body); preview != "" {
+		opts.debug("Error response body", "status", resp.StatusCode, "body", preview)
+	}
+}
+
+// bodyPreview returns up to errorPreviewSize bytes of body on a single line,
+// or "" when body is empty or is not text, so that binary data never ends
+// up in the log.
+func bodyPreview(body []byte) string {
+	truncated := len(body) > errorPreviewSize
+	if truncated {
+		body = body[:errorPreviewSize]
+		// Drop a rune cut in half by the truncation.
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		return ""
+	}
+	for _, r := range string(body) {
+		if (r < ' ' && r != '\t' && r != '\n' && r != '\r') || r == 0x7f {
+			return ""
+		}
+	}
+	preview := strings.Join(strings.Fields(string(body)), " ")
+	if truncated && preview != "" {
+		preview += "…"
+	}
+	return preview
+}
+
+// proxyFallback fetches target through the reader proxy after the origin
+// refused it for reason, answering with status. With opts.NoProxy it fails
+// with a KindHTTPStatus error instead.
+func proxyFallback(ctx context.Context, target *url.URL, reason, status string, opts *Options) (fetchResult, error) {
+	if opts.NoProxy {
+		opts.warn("Proxy fallback skipped by configuration (-no-proxy)", "reason", reason)
+		return fetchResult{}, classify(KindHTTPStatus, fmt.Errorf("HTTP status %s", status))
+	}
+	proxyStart := time.Now()
+	fallback, err := fetchViaProxy(ctx, target, opts)
+	opts.debug("Proxy fallback done", "duration", since(proxyStart))
+	if err != nil {
+		opts.warn("Proxy fallback failed", "reason", reason, "error", err)
+		return fetchResult{}, classify(KindProxy, fmt.Errorf("%s and proxy fallback failed: %w", status, err))
+	}
+	opts.info("Fetched content via proxy", "reason", reason)
+	return fetchResult{body: fallback, url: target, viaProxy: true}, nil
+}
+
+// since returns the time elapsed since start, rounded for log messages.
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
+
+// classifyContentType reports whether a response needs HTML conversion
+// (false means it is Markdown or plain text to pass through as-is). Other
+// content types fail with an "unsupported content type" error unless
+// opts.Force is set, in which case they are converted as HTML. A missing
+// Content-Type is treated as HTML.
+func classifyContentType(contentType string, source *url.URL, opts *Options) (bool, error) {
+	if strings.HasSuffix(strings.ToLower(source.Path), ".md") {
+		return false, nil
+	}
+	if contentType == "" {
+		return true, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return true, nil
+	case "text/markdown", "text/x-markdown", "text/plain":
+		return false, nil
+	}
+	if opts.Force {
+		opts.info("Converting content as HTML (-force)", "type", mediaType)
+		return true, nil
+	}
+	return false, classify(KindConversion, fmt.Errorf("unsupported content type %q", mediaType))
+}
+
+// applyModifiedSince makes req conditional on the page having changed after
+// since. A later If-Modified-Since already set from the cache is kept.
+func applyModifiedSince(req *http.Request, since time.Time) {
+	if cached, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && cached.After(since) {
+		return
+	}
+	req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+}
+
+func applyBasicAuth(req *http.Request, opts *Options) {
+	if opts.BasicAuth != nil {
+		pass, _ := opts.BasicAuth.Password()
+		req.SetBasicAuth(opts.BasicAuth.Username(), pass)
+	}
+}
+
+func applyBrowserHeaders(req *http.Request, target *url.URL, opts *Options, includeNavigation bool) {
+	lang := opts.AcceptLanguage
+	if lang == "" {
+		lang = DefaultAcceptLanguage
+	}
+	accept := opts.Accept
+	if accept == "" {
+		accept = DefaultAccept
+	}
+	userAgent := opts.UserAgents.userAgent(opts.UserAgent)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Accept-Language", lang)
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Pragma", "no-cache")
+	if brands, mobile, platform, ok := clientHints(userAgent); ok {
+		req.Header.Set("Sec-CH-UA", brands)
+		req.Header.Set("Sec-CH-UA-Mobile", mobile)
+		req.Header.Set("Sec-CH-UA-Platform", platform)
+	}
+	if includeNavigation {
+		req.Header.Set("Sec-Fetch-Dest", "document")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.Header.Set("Sec-Fetch-Site", "none")
+		req.Header.Set("Sec-Fetch-User", "?1")
+		req.Header.Set("Upgrade-Insecure-Requests", "1")
+	}
+}
+
+var languageRangeRe = regexp.MustCompile(`^(\*|[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)(\s*;\s*q=(0(\.[0-9]{0,3})?|1(\.0{0,3})?))?$`)
+
+// ValidateAcceptLanguage reports whether raw is usable as
+// Options.AcceptLanguage: a comma-separated list of language ranges such as
+// "it-IT,it;q=0.9,en;q=0.5".
+func ValidateAcceptLanguage(raw string) error {
+	for _, part := range strings.Split(raw, ",") {
+		if !languageRangeRe.MatchString(strings.TrimSpace(part)) {
+			return classify(KindInvalidInput, fmt.Errorf("invalid Accept-Language %q: expected language ranges like it-IT,it;q=0.9", raw))
+		}
+	}
+	return nil
+}
+
+var mediaRangeRe = regexp.MustCompile(`^(\*/\*|[A-Za-z0-9!#$&^_.+-]+/(\*|[A-Za-z0-9!#$&^_.+-]+))(\s*;\s*[A-Za-z0-9!#$&^_.+-]+=("[^"\r\n]*"|[A-Za-z0-9!#$&^_.+-]+))*$`)
+
+// ValidateAccept reports whether raw is usable as Options.Accept: a
+// comma-separated list of media ranges such as
+// "text/markdown,text/html;q=0.9".
+func ValidateAccept(raw string) error {
+	for _, part := range strings.Split(raw, ",") {
+		if !mediaRangeRe.MatchString(strings.TrimSpace(part)) {
+			return classify(KindInvalidInput, fmt.Errorf("invalid Accept %q: expected media ranges like text/markdown,text/html;q=0.9", raw))
+		}
+	}
+	return nil
+}
+
+// ValidateProxyURL reports whether raw is usable as Options.ProxyURL: an
+// absolute http or https URL with a host.
+func ValidateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return classify(KindInvalidInput, fmt.Errorf("invalid proxy url: %w", err))
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return classify(KindInvalidInput, fmt.Errorf("invalid proxy url %q: must be an absolute http or https URL", raw))
+	}
+	return nil
+}
+
+// ValidateHTTPProxy reports whether raw is usable as Options.HTTPProxy: an
+// absolute http, https or socks5 URL with a host.
+func ValidateHTTPProxy(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return classify(KindInvalidInput, fmt.Errorf("invalid http proxy: %w", err))
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return classify(KindInvalidInput, fmt.Errorf("invalid http proxy %q: must be an http, https or socks5 URL", raw))
+	}
+	if u.Host == "" {
+		return classify(KindInvalidInput, fmt.Errorf("invalid http proxy %q: missing host", raw))
+	}
+	return nil
+}
+
+// fetchViaProxy asks a reader-compatible proxy for target by appending it to
+// the proxy URL. JINA_API_KEY is only sent to the default endpoint unless
+// opts.ProxyAuth is set.
+func fetchViaProxy(ctx context.Context, target *url.URL, opts *Options) ([]byte, error) {
+	endpoint := opts.ProxyURL
+	if endpoint == "" {
+		endpoint = DefaultProxyURL
+	}
+	req, err := newProxyRequest(ctx, endpoint, target, opts)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "url2md-proxy/1.0 (+https://github.com)")
+	if key := strings.TrimSpace(os.Getenv("JINA_API_KEY")); key != "" && (endpoint == DefaultProxyURL || opts.ProxyAuth) {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	transport := opts.ProxyTransport
+	if transport == nil {
+		own := newTransport(opts)
+		defer own.CloseIdleConnections()
+		transport = own
+	}
+	client := &http.Client{Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Only the start of the body is reported, whatever its size.
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if len(data) > 0 {
+			detail := truncateUTF8(strings.TrimSpace(string(data)), 256)
+			return nil, fmt.Errorf("proxy request status %s: %s", resp.Status, detail)
+		}
+		return nil, fmt.Errorf("proxy request status %s", resp.Status)
+	}
+
+	return readLimited(resp.Body, opts.MaxSize)
+}
+
+// truncateUTF8 shortens s to at most n bytes, cutting on a rune boundary,
+// and marks the cut with an ellipsis.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// proxyBodyFuncs are the functions available to Options.ProxyBodyTemplate.
+var proxyBodyFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(s)
+		return strings.TrimSuffix(b.String(), "\n"), err
+	},
+	"query": url.QueryEscape,
+}
+
+// proxyBody holds the fields of Options.ProxyBodyTemplate.
+type proxyBody struct {
+	URL string
+}
+
+// newProxyRequest returns the request asking the reader proxy at endpoint
+// for target: a GET of endpoint followed by the page URL, or with
+// Options.ProxyMethod a request to endpoint whose body carries the URL.
+func newProxyRequest(ctx context.Context, endpoint string, target *url.URL, opts *Options) (*http.Request, error) {
+	method := opts.ProxyMethod
+	if method == "" || method == http.MethodGet {
+		if !strings.HasSuffix(endpoint, "/") {
+			endpoint += "/"
+		}
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpoint+target.String(), nil)
+	}
+	text := opts.ProxyBodyTemplate
+	if text == "" {
+		text = DefaultProxyBody
+	}
+	tmpl, err := parseProxyBody(text)
+	if err != nil {
+		return nil, err
+	}
+	var body strings.Builder
+	if err := tmpl.Execute(&body, proxyBody{URL: target.String()}); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	contentType := "application/x-www-form-urlencoded"
+	if strings.HasPrefix(strings.TrimSpace(body.String()), "{") {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+func parseProxyBody(text string) (*template.Template, error) {
+	return template.New("proxy-body").Funcs(proxyBodyFuncs).Option("missingkey=error").Parse(text)
+}
+
+var methodRe = regexp.MustCompile(`^[A-Z]+$`)
+
+// ValidateProxyRequest reports whether method and bodyTemplate are usable as
+// Options.ProxyMethod and Options.ProxyBodyTemplate: an upper-case method
+// name, and a template that renders without errors and is only given with
+// a method other than GET.
+func ValidateProxyRequest(method, bodyTemplate string) error {
+	if method != "" && !methodRe.MatchString(method) {
+		return classify(KindInvalidInput, fmt.Errorf("invalid proxy method %q: must be an upper-case method like POST", method))
+	}
+	if bodyTemplate == "" {
+		return nil
+	}
+	if method == "" || method == http.MethodGet {
+		return classify(KindInvalidInput, errors.New("a proxy body template needs a proxy method other than GET"))
+	}
+	tmpl, err := parseProxyBody(bodyTemplate)
+	if err == nil {
+		err = tmpl.Execute(io.Discard, proxyBody{URL: "https://example.com/"})
+	}
+	if err != nil {
+		return classify(KindInvalidInput, fmt.Errorf("invalid proxy body template: %w", err))
+	}
+	return nil
+}
+
+// ErrTooLarge is returned when a response body exceeds Options.MaxSize.
+var ErrTooLarge = errors.New("response exceeded max size")
+
+// readLimited reads r to the end, failing with ErrTooLarge once more than
+// limit bytes have been read. A limit of zero or less reads without a cap.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w of %d bytes", ErrTooLarge, limit)
+	}
+	return data, nil
+}
+
+// classifyRead classifies an error met while reading a response body.
+func classifyRead(err error) error {
+	if errors.Is(err, ErrTooLarge) {
+		return classify(KindTooLarge, err)
+	}
+	return classify(KindNetwork, err)
+}
@@ -0,0 +1,185 @@
+package url2md
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// assetsDirName is the folder, next to the markdown file, that downloaded
+// images are saved into.
+const assetsDirName = "assets"
+
+// localAssetAttr marks <img> elements whose src was rewritten to a downloaded
+// file, so the converter leaves the relative path alone.
+const localAssetAttr = "data-url2md-local"
+
+var unsafeAssetChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// stripImages removes every image from page.
+func stripImages(page []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	doc.Find("img, picture").Remove()
+	html, err := doc.Html()
+	return []byte(html), err
+}
+
+// downloadImages saves the images referenced by page into assetsDir and points
+// their src at the saved copies, relative to the markdown file. SVG images
+// and data URIs are left untouched unless opts.ImagesAll is set. Images that fail
+// to download keep their original src.
+func downloadImages(ctx context.Context, client *http.Client, page []byte, base *url.URL, assetsDir string, opts *Options) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find("img[src]").Each(func(_ int, img *goquery.Selection) {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		if src == "" {
+			return
+		}
+
+		var name string
+		var data []byte
+		if strings.HasPrefix(src, "data:") {
+			if !opts.ImagesAll {
+				return
+			}
+			mediaType, decoded, err := decodeDataURI(src)
+			if err != nil {
+				opts.warn("Skipping inline image", "error", err)
+				return
+			}
+			name, data = assetName(src, "image", mediaType), decoded
+		} else {
+			target, err := url.Parse(src)
+			if err == nil && base != nil {
+				target = base.ResolveReference(target)
+			}
+			if err != nil {
+				opts.warn("Skipping image", "src", src, "error", err)
+				return
+			}
+			if !opts.ImagesAll && strings.EqualFold(path.Ext(target.Path), ".svg") {
+				return
+			}
+			body, contentType, err := fetchAsset(ctx, client, target, opts)
+			if err != nil {
+				opts.warn("Failed to download image", "url", target.String(), "error", err)
+				return
+			}
+			if !opts.ImagesAll && strings.HasPrefix(contentType, "image/svg") {
+				return
+			}
+			name, data = assetName(target.String(), path.Base(target.Path), contentType), body
+		}
+
+		if err := writeAsset(filepath.Join(assetsDir, name), data); err != nil {
+			opts.warn("Failed to save image", "file", name, "error", err)
+			return
+		}
+		img.SetAttr("src", assetsDirName+"/"+name)
+		img.SetAttr(localAssetAttr, "")
+		img.RemoveAttr("srcset")
+	})
+
+	html, err := doc.Html()
+	return []byte(html), err
+}
+
+func fetchAsset(ctx context.Context, client *http.Client, target *url.URL, opts *Options) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	applyBrowserHeaders(req, target, opts, false)
+	req.Header.Set("Accept", "image/avif,image/webp,image/*,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	data, err := readLimited(resp.Body, opts.MaxSize)
+	return data, resp.Header.Get("Content-Type"), err
+}
+
+// assetName builds a file name for an image. The hash of source keeps names
+// unique across pages while letting repeated images share one file.
+func assetName(source, base, contentType string) string {
+	sum := sha256.Sum256([]byte(source))
+	prefix := hex.EncodeToString(sum[:4])
+
+	ext := path.Ext(base)
+	base = strings.TrimSuffix(base, ext)
+	if ext == "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+	}
+
+	base = strings.Trim(unsafeAssetChars.ReplaceAllString(base, "_"), "_.")
+	if base == "" {
+		base = "image"
+	}
+	return prefix + "-" + base + strings.ToLower(unsafeAssetChars.ReplaceAllString(ext, ""))
+}
+
+func writeAsset(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// decodeDataURI returns the media type and payload of a data: URI, handling
+// both base64 and percent-encoded payloads.
+func decodeDataURI(raw string) (string, []byte, error) {
+	spec, payload, ok := strings.Cut(strings.TrimPrefix(raw, "data:"), ",")
+	if !ok {
+		return "", nil, errors.New("malformed data URI")
+	}
+
+	isBase64 := strings.HasSuffix(spec, ";base64")
+	mediaType := strings.TrimSuffix(spec, ";base64")
+	if mediaType == "" {
+		mediaType = "text/plain;charset=US-ASCII"
+	}
+
+	if isBase64 {
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("decoding data URI: %w", err)
+		}
+		return mediaType, data, nil
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("decoding data URI: %w", err)
+	}
+	return mediaType, []byte(data), nil
+}
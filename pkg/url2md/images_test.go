@@ -0,0 +1,92 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripImages(t *testing.T) {
+	got, err := stripImages([]byte(`<p>Text <img src="/a.png" alt="a"></p><picture><source srcset="/b.webp"><img src="/b.png"></picture>`))
+	if err != nil {
+		t.Fatalf("stripImages returned error: %v", err)
+	}
+	if strings.Contains(string(got), "<img") || strings.Contains(string(got), "<picture") {
+		t.Fatalf("stripImages left images behind: %s", got)
+	}
+	if !strings.Contains(string(got), "Text") {
+		t.Fatalf("stripImages removed surrounding text: %s", got)
+	}
+}
+
+func TestDownloadImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/img/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png-bytes"))
+		case "/icon.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg/>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	base, _ := url.Parse(srv.URL + "/docs/page")
+	page := []byte(`<p><img src="../img/logo.png" alt="Logo"><img src="/icon.svg" alt="Icon"><img src="data:image/gif;base64,R0lGODlh" alt="Pixel"></p>`)
+	assetsDir := filepath.Join(t.TempDir(), assetsDirName)
+	opts := &Options{UserAgent: DefaultUserAgent, Logf: t.Logf}
+
+	got, err := downloadImages(context.Background(), srv.Client(), page, base, assetsDir, opts)
+	if err != nil {
+		t.Fatalf("downloadImages returned error: %v", err)
+	}
+
+	name := assetName(srv.URL+"/img/logo.png", "logo.png", "image/png")
+	data, err := os.ReadFile(filepath.Join(assetsDir, name))
+	if err != nil {
+		t.Fatalf("downloaded image not found: %v", err)
+	}
+	if string(data) != "png-bytes" {
+		t.Fatalf("downloaded image = %q, expected %q", data, "png-bytes")
+	}
+
+	markdown, err := convertToMarkdown(base, got, &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	for _, want := range []string{
+		"![Logo](assets/" + name + ")",
+		"![Icon](" + srv.URL + "/icon.svg)",
+		"![Pixel](data:image/gif;base64,R0lGODlh)",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Fatalf("markdown %q does not contain %q", markdown, want)
+		}
+	}
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	mediaType, data, err := decodeDataURI("data:text/html;base64,PGgxPkhpPC9oMT4=")
+	if err != nil {
+		t.Fatalf("decodeDataURI returned error: %v", err)
+	}
+	if mediaType != "text/html" || string(data) != "<h1>Hi</h1>" {
+		t.Fatalf("decodeDataURI = %q, %q", mediaType, data)
+	}
+
+	mediaType, data, err = decodeDataURI("data:,Hello%2C%20World")
+	if err != nil {
+		t.Fatalf("decodeDataURI returned error: %v", err)
+	}
+	if mediaType != "text/plain;charset=US-ASCII" || string(data) != "Hello, World" {
+		t.Fatalf("decodeDataURI = %q, %q", mediaType, data)
+	}
+}
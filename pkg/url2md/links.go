@@ -0,0 +1,216 @@
+package url2md
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractLinks returns the http and https targets of the <a href> links in
+// page, resolved against base unless it is nil, without fragments and in
+// document order. Each URL is listed once.
+func extractLinks(page []byte, base *url.URL) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := map[string]bool{}
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		ref, err := url.Parse(strings.TrimSpace(a.AttrOr("href", "")))
+		if err != nil {
+			return
+		}
+		link := ref
+		if base != nil {
+			link = base.ResolveReference(ref)
+		}
+		if link.Scheme != "http" && link.Scheme != "https" {
+			return
+		}
+		link.Fragment, link.RawFragment = "", ""
+		if s := link.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, s)
+		}
+	})
+	return links
+}
+
+// linkAttrs lists, per element, the attributes absolutizeLinks rewrites.
+var linkAttrs = []struct{ selector, attr string }{
+	{"a[href], area[href], link[href]", "href"},
+	{"img[src], source[src], video[src], audio[src], track[src], iframe[src], embed[src]", "src"},
+	{"img[srcset], source[srcset]", "srcset"},
+	{"video[poster]", "poster"},
+	{"object[data]", "data"},
+}
+
+// absolutizeLinks rewrites the link and media attributes of page to absolute
+// URLs resolved against base, so they are absolute in the HTML itself and
+// not only where the converter resolves them. Protocol-relative references
+// take the scheme of base and fragment-only ones point into base. Images
+// already saved by downloadImages keep their relative path.
+func absolutizeLinks(page []byte, base *url.URL) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(raw string) string {
+		ref, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || ref.Scheme != "" {
+			return raw
+		}
+		return base.ResolveReference(ref).String()
+	}
+
+	for _, la := range linkAttrs {
+		doc.Find(la.selector).Each(func(_ int, el *goquery.Selection) {
+			if _, local := el.Attr(localAssetAttr); local {
+				return
+			}
+			value := el.AttrOr(la.attr, "")
+			if la.attr != "srcset" {
+				el.SetAttr(la.attr, resolve(value))
+				return
+			}
+			candidates := strings.Split(value, ",")
+			for i, candidate := range candidates {
+				fields := strings.Fields(candidate)
+				if len(fields) > 0 {
+					fields[0] = resolve(fields[0])
+				}
+				candidates[i] = strings.Join(fields, " ")
+			}
+			el.SetAttr(la.attr, strings.Join(candidates, ", "))
+		})
+	}
+
+	html, err := doc.Html()
+	return []byte(html), err
+}
+
+// DefaultTrackingParams are the query parameters removed by
+// Options.CleanLinks when Options.TrackingParams is empty. A trailing "*"
+// matches any suffix.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "gclsrc", "dclid", "msclkid", "yclid", "mc_*", "_hsenc", "_hsmi", "igshid"}
+
+// cleanLink removes the query parameters matching patterns from rawURL,
+// keeping the other parameters in their original order and encoding. URLs
+// without a query are returned unchanged.
+func cleanLink(rawURL string, patterns []string) string {
+	q := strings.IndexByte(rawURL, '?')
+	if q < 0 {
+		return rawURL
+	}
+	query, fragment := rawURL[q+1:], ""
+	if f := strings.IndexByte(query, '#'); f >= 0 {
+		query, fragment = query[:f], query[f:]
+	}
+
+	var kept []string
+	for _, param := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param != "" && !matchesParam(name, patterns) {
+			kept = append(kept, param)
+		}
+	}
+	if len(kept) == 0 {
+		return rawURL[:q] + fragment
+	}
+	return rawURL[:q] + "?" + strings.Join(kept, "&") + fragment
+}
+
+func matchesParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// emptyLinkRe matches a link without visible text, inline or referenced,
+// with the character before it and a space after it. The text may contain
+// spaces, no-break spaces and zero-width characters, and the destination
+// may be wrapped in <…> or contain balanced parentheses, as in Wikipedia
+// URLs. The character before must not be the "!" of an image, an escape or
+// the "]" of a [text][] reference.
+var emptyLinkRe = regexp.MustCompile(`(^|[^!\\\]])\[[ \t\x{00A0}\x{200B}-\x{200D}\x{2060}\x{FEFF}]*\](?:\((?:<[^>]*>|[^()]|\([^()]*\))*\)|\[([^\]]*)\])( ?)`)
+
+// removeEmptyLinks removes the links of markdown whose text is empty or only
+// whitespace, such as the [](url) of icon-only navigation links, along
+// with the definitions only they used. Images, including empty ![](url)
+// ones, code spans and fenced code blocks are left alone, and lines left
+// without text are dropped.
+func removeEmptyLinks(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	var labels []string
+	fence := ""
+	for _, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimLeft(line, " "), fence) {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			out = append(out, line)
+			continue
+		}
+		// The odd parts are inside code spans.
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			// Adjacent links share the character matched before them, so
+			// repeat until none is left.
+			for prev := ""; prev != parts[i]; {
+				prev = parts[i]
+				parts[i] = emptyLinkRe.ReplaceAllStringFunc(prev, func(link string) string {
+					m := emptyLinkRe.FindStringSubmatch(link)
+					if m[2] != "" {
+						labels = append(labels, m[2])
+					}
+					if m[1] == "" || strings.HasSuffix(m[1], " ") {
+						return m[1]
+					}
+					return m[1] + m[3]
+				})
+			}
+		}
+		cleaned := strings.Join(parts, "`")
+		if cleaned != line && strings.TrimSpace(cleaned[len(blockMarkerRe.FindString(cleaned)):]) == "" {
+			continue
+		}
+		out = append(out, cleaned)
+	}
+	markdown = strings.Join(out, "\n")
+	for _, label := range labels {
+		if strings.Contains(markdown, "]["+label+"]") {
+			continue
+		}
+		out = out[:0]
+		for _, line := range strings.Split(markdown, "\n") {
+			if !strings.HasPrefix(strings.TrimLeft(line, " "), "["+label+"]:") {
+				out = append(out, line)
+			}
+		}
+		markdown = strings.Join(out, "\n")
+	}
+	return markdown
+}
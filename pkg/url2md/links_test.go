@@ -0,0 +1,113 @@
+package url2md
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	page := []byte(`<nav><a href="/docs/">Docs</a><a href="intro#setup">Intro</a></nav>
+<p><a href="intro">Intro again</a> <a href="#top">Top</a> <a href="mailto:a@example.com">Mail</a>
+<a href="https://other.example.org/x">Other</a> <a>No href</a></p>`)
+	base, _ := url.Parse("https://example.com/docs/start")
+
+	got := extractLinks(page, base)
+	want := []string{
+		"https://example.com/docs/",
+		"https://example.com/docs/intro",
+		"https://example.com/docs/start",
+		"https://other.example.org/x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("extractLinks = %q, expected %q", got, want)
+	}
+}
+
+func TestCleanLink(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/a?utm_source=news&id=7&utm_medium=mail":   "https://example.com/a?id=7",
+		"https://example.com/a?fbclid=abc":                             "https://example.com/a",
+		"https://example.com/a?q=go%20lang&GCLID=x#install":            "https://example.com/a?q=go%20lang#install",
+		"https://example.com/a?mc_cid=1&mc_eid=2&page=2&b=1":           "https://example.com/a?page=2&b=1",
+		"https://example.com/a?utm%5Fcampaign=spring&x=":               "https://example.com/a?x=",
+		"https://example.com/a#utm_source=here":                        "https://example.com/a#utm_source=here",
+		"/relative/path":                                               "/relative/path",
+		"#section":                                                     "#section",
+		"https://example.com/search?utm=keep&gclidx=keep&fbclid_=keep": "https://example.com/search?utm=keep&gclidx=keep&fbclid_=keep",
+	}
+	for in, want := range tests {
+		if got := cleanLink(in, DefaultTrackingParams); got != want {
+			t.Fatalf("cleanLink(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestAbsolutizeLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/page?lang=en")
+	page := []byte(`<a href="#intro">Intro</a><a href="//cdn.example.org/a">CDN</a><a href="../up">Up</a>` +
+		`<a href="mailto:me@example.com">Mail</a><img src="i.png" srcset="i-2x.png 2x, /i-3x.png 3x">` +
+		`<img src="assets/logo.png" data-url2md-local=""><video src="v.mp4" poster="p.jpg"></video>`)
+
+	out, err := absolutizeLinks(page, base)
+	if err != nil {
+		t.Fatalf("absolutizeLinks returned error: %v", err)
+	}
+	for _, want := range []string{
+		`href="https://example.com/docs/page?lang=en#intro"`,
+		`href="https://cdn.example.org/a"`,
+		`href="https://example.com/up"`,
+		`href="mailto:me@example.com"`,
+		`src="https://example.com/docs/i.png"`,
+		`srcset="https://example.com/docs/i-2x.png 2x, https://example.com/i-3x.png 3x"`,
+		`src="assets/logo.png"`,
+		`src="https://example.com/docs/v.mp4"`,
+		`poster="https://example.com/docs/p.jpg"`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("absolutizeLinks output %s\nexpected it to contain %s", out, want)
+		}
+	}
+}
+
+func TestRemoveEmptyLinks(t *testing.T) {
+	tests := map[string]string{
+		"[](https://example.com/) Home [ ](/search) [Docs](/docs)":              "Home [Docs](/docs)",
+		"Follow us[](https://x.com/example) today.":                             "Follow us today.",
+		"[](/a)[](/b)[Blog](/blog)":                                             "[Blog](/blog)",
+		"- [](/)\n- [About](/about)":                                            "- [About](/about)",
+		"![](https://example.com/logo.png) and ![Chart](/chart.png)":            "![](https://example.com/logo.png) and ![Chart](/chart.png)",
+		"[![](/logo.png)](/) and [![Logo](/logo.png)](/)":                       "[![](/logo.png)](/) and [![Logo](/logo.png)](/)",
+		"Write `[](url)` for an empty link.":                                    "Write `[](url)` for an empty link.",
+		"```md\n[](url)\n```":                                                   "```md\n[](url)\n```",
+		"Menu [][1] and [Docs][2]\n\n[1]: https://example.com/\n[2]: /docs":     "Menu and [Docs][2]\n\n[2]: /docs",
+		"An escaped \\[](not-a-link) and [Term][] reference":                    "An escaped \\[](not-a-link) and [Term][] reference",
+		"Read [](https://en.wikipedia.org/wiki/Go_(programming_language)) more": "Read more",
+		"See [](<https://example.com/a b>) and [](/x \"Title\") too":            "See and too",
+	}
+	for in, want := range tests {
+		if got := removeEmptyLinks(in); got != want {
+			t.Fatalf("removeEmptyLinks(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestConvertHTMLKeepEmptyLinks(t *testing.T) {
+	page := []byte(`<nav><a href="/">&#8203;</a> <a href="/docs">Docs</a></nav><p><a href="/"><img src="/logo.png" alt=""></a></p>`)
+	tests := map[bool]string{
+		false: "[Docs](https://example.com/docs)\n\n[![](https://example.com/logo.png)](https://example.com/)",
+		true:  "[\u200b](https://example.com/) [Docs](https://example.com/docs)\n\n[![](https://example.com/logo.png)](https://example.com/)",
+	}
+	base, _ := url.Parse("https://example.com/")
+	for keep, want := range tests {
+		res, err := ConvertHTML(context.Background(), page, base, Options{KeepEmptyLinks: keep})
+		if err != nil {
+			t.Fatalf("ConvertHTML returned error: %v", err)
+		}
+		if res.Markdown != want {
+			t.Fatalf("ConvertHTML with KeepEmptyLinks %v = %q, expected %q", keep, res.Markdown, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package url2md
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata is the Open Graph and article information a page declares in its
+// <meta> tags. Fields the page does not declare are empty.
+type Metadata struct {
+	// Title and Description are og:title and og:description.
+	Title       string
+	Description string
+	// Image is og:image, resolved to an absolute URL.
+	Image string
+	// PublishedTime is article:published_time as the page writes it,
+	// usually an ISO 8601 timestamp.
+	PublishedTime string
+	// Author is <meta name="author">, or else article:author.
+	Author string
+}
+
+// pageMetadata is the document information reported in a Result.
+type pageMetadata struct {
+	title       string
+	description string
+	canonical   string
+	openGraph   Metadata
+}
+
+// extractMetadata reads the <title>, <meta name="description">,
+// <link rel="canonical"> and Open Graph tags of an HTML document. Missing or
+// unparsable values are returned empty.
+func extractMetadata(html []byte) pageMetadata {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return pageMetadata{}
+	}
+
+	return pageMetadata{
+		title:       strings.TrimSpace(doc.Find("title").First().Text()),
+		description: strings.TrimSpace(doc.Find(`meta[name="description"]`).First().AttrOr("content", "")),
+		canonical:   strings.TrimSpace(doc.Find(`link[rel~="canonical"]`).First().AttrOr("href", "")),
+		openGraph: Metadata{
+			Title:         metaContent(doc, "og:title"),
+			Description:   metaContent(doc, "og:description"),
+			Image:         metaContent(doc, "og:image"),
+			PublishedTime: metaContent(doc, "article:published_time"),
+			Author:        metaContent(doc, "author", "article:author"),
+		},
+	}
+}
+
+// metaContent returns the content of the first <meta> tag named after one of
+// names, in order. Open Graph tags use the property attribute, but pages
+// often give them a name instead, so both are accepted.
+func metaContent(doc *goquery.Document, names ...string) string {
+	for _, name := range names {
+		var content string
+		doc.Find("meta[property], meta[name]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+			if !strings.EqualFold(meta.AttrOr("property", meta.AttrOr("name", "")), name) {
+				return true
+			}
+			content = strings.TrimSpace(meta.AttrOr("content", ""))
+			return content == ""
+		})
+		if content != "" {
+			return content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package url2md
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	html := []byte(`<html><head>
+<title> Getting started </title>
+<meta name="description" content="How to install the tool">
+<link rel="canonical" href=" /docs/getting-started ">
+</head><body><h1>Hi</h1></body></html>`)
+
+	meta := extractMetadata(html)
+	if meta.title != "Getting started" {
+		t.Fatalf("title = %q, expected %q", meta.title, "Getting started")
+	}
+	if meta.description != "How to install the tool" {
+		t.Fatalf("description = %q, expected %q", meta.description, "How to install the tool")
+	}
+	if meta.canonical != "/docs/getting-started" {
+		t.Fatalf("canonical = %q, expected %q", meta.canonical, "/docs/getting-started")
+	}
+}
+
+func TestConvertHTMLOpenGraph(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "opengraph.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/blog/release-2")
+	res, err := ConvertHTML(context.Background(), page, base, Options{})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	want := Metadata{
+		Title:         "Release 2.0",
+		Description:   "Faster conversions and a new CLI.",
+		Image:         "https://example.com/img/release-2.png",
+		PublishedTime: "2024-06-03T10:00:00+02:00",
+		Author:        "Ada Lovelace",
+	}
+	if res.Metadata != want {
+		t.Fatalf("Metadata = %+v, expected %+v", res.Metadata, want)
+	}
+
+	if meta := extractMetadata([]byte(`<html><head><title>Plain</title></head></html>`)); meta.openGraph != (Metadata{}) {
+		t.Fatalf("openGraph = %+v, expected no values for a page without tags", meta.openGraph)
+	}
+	if meta := extractMetadata([]byte(`<meta name="og:title" content="Named"><meta property="article:author" content="Grace">`)); meta.openGraph.Title != "Named" || meta.openGraph.Author != "Grace" {
+		t.Fatalf("openGraph = %+v, expected og:title from name and the article:author fallback", meta.openGraph)
+	}
+}
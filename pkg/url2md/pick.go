@@ -0,0 +1,183 @@
+package url2md
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Candidate is an element of a page that may hold its main content, as
+// offered to Options.PickContent.
+type Candidate struct {
+	// Selector is a CSS selector matching the element, usable as
+	// Options.Select.
+	Selector string
+	// TextLength is the number of characters of text in the element, with
+	// runs of whitespace counted once.
+	TextLength int
+	// Preview is the beginning of the text of the element.
+	Preview string
+}
+
+const (
+	// candidateCount is how many elements are offered to
+	// Options.PickContent.
+	candidateCount = 10
+	// previewLength is the number of characters of Candidate.Preview.
+	previewLength = 60
+)
+
+// containerAtoms are the elements considered as content containers.
+var containerAtoms = map[atom.Atom]bool{
+	atom.Article: true, atom.Aside: true, atom.Div: true, atom.Footer: true,
+	atom.Header: true, atom.Main: true, atom.Nav: true, atom.Section: true,
+	atom.Table: true, atom.Td: true, atom.Ul: true, atom.Ol: true,
+}
+
+// cssIdentRe matches the id attributes that can be written as #id without
+// escaping.
+var cssIdentRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// contentCandidates returns up to limit container elements of page with the most
+// text, biggest first. An element whose text is all in one inner candidate
+// is left out in favor of that candidate.
+func contentCandidates(page []byte, limit int) ([]Candidate, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*html.Node
+	texts := map[*html.Node]string{}
+	doc.Find("body *").Each(func(_ int, s *goquery.Selection) {
+		if !containerAtoms[s.Nodes[0].DataAtom] {
+			return
+		}
+		if text := nodeText(s.Nodes[0]); text != "" {
+			nodes = append(nodes, s.Nodes[0])
+			texts[s.Nodes[0]] = text
+		}
+	})
+	wrappers := map[*html.Node]bool{}
+	for _, n := range nodes {
+		for p := n.Parent; p != nil && texts[p] == texts[n]; p = p.Parent {
+			wrappers[p] = true
+		}
+	}
+
+	var candidates []Candidate
+	for _, n := range nodes {
+		if wrappers[n] {
+			continue
+		}
+		text := texts[n]
+		preview := text
+		if utf8.RuneCountInString(text) > previewLength {
+			preview = strings.TrimSpace(string([]rune(text)[:previewLength])) + "…"
+		}
+		candidates = append(candidates, Candidate{
+			Selector:   cssPath(n),
+			TextLength: utf8.RuneCountInString(text),
+			Preview:    preview,
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].TextLength > candidates[j].TextLength })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// nodeText returns the text of n, leaving out scripts and styles, with runs
+// of whitespace and the boundaries between elements turned into one space.
+func nodeText(n *html.Node) string {
+	var words []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			words = append(words, strings.Fields(n.Data)...)
+		case n.DataAtom == atom.Script || n.DataAtom == atom.Style:
+		default:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return strings.Join(words, " ")
+}
+
+// nodeID returns the id attribute of n, or "" when it has none.
+func nodeID(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == "id" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// cssPath returns a selector for n made of the tag names of its ancestors,
+// with :nth-of-type where siblings share a tag, starting from the nearest
+// ancestor with a usable id or else from <body>.
+func cssPath(n *html.Node) string {
+	var parts []string
+	for cur := n; cur != nil && cur.Type == html.ElementNode; cur = cur.Parent {
+		if id := nodeID(cur); cssIdentRe.MatchString(id) {
+			parts = append(parts, cur.Data+"#"+id)
+			break
+		}
+		if cur.DataAtom == atom.Body {
+			parts = append(parts, "body")
+			break
+		}
+		part := cur.Data
+		index, same := 0, 0
+		for s := cur.Parent.FirstChild; s != nil; s = s.NextSibling {
+			if s.Type == html.ElementNode && s.Data == cur.Data {
+				same++
+				if s == cur {
+					index = same
+				}
+			}
+		}
+		if same > 1 {
+			part += fmt.Sprintf(":nth-of-type(%d)", index)
+		}
+		parts = append(parts, part)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, " > ")
+}
+
+// pickContent asks opts.PickContent which element of page to convert and
+// returns its HTML, or page itself when the whole page was chosen.
+func pickContent(page []byte, opts *Options) ([]byte, error) {
+	candidates, err := contentCandidates(page, candidateCount)
+	if err != nil {
+		return nil, classify(KindConversion, fmt.Errorf("failed to list content candidates: %w", err))
+	}
+	if len(candidates) == 0 {
+		opts.debug("No content candidates, converting the whole page")
+		return page, nil
+	}
+	i, err := opts.PickContent(candidates)
+	if err != nil {
+		return nil, classify(KindInvalidInput, fmt.Errorf("failed to pick content: %w", err))
+	}
+	if i < 0 || i >= len(candidates) {
+		opts.debug("Converting the whole page")
+		return page, nil
+	}
+	opts.info("Converting picked element", "selector", candidates[i].Selector)
+	return selectHTML(page, candidates[i].Selector)
+}
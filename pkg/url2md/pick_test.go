@@ -0,0 +1,83 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const pickPage = `<html><body>
+<nav><ul><li>Home</li><li>About</li></ul></nav>
+<div class="wrapper"><main id="content">
+  <article><h1>Story</h1><p>` + "A long paragraph of story text that goes on and on for a while." + `</p></article>
+</main></div>
+<div><p>Sidebar one</p></div>
+<div><p>Sidebar two, a bit longer</p></div>
+</body></html>`
+
+func TestContentCandidates(t *testing.T) {
+	got, err := contentCandidates([]byte(pickPage), 3)
+	if err != nil {
+		t.Fatalf("contentCandidates returned error: %v", err)
+	}
+	want := []string{"main#content > article", "body > div:nth-of-type(3)", "body > div:nth-of-type(2)"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d candidates, expected %d: %+v", len(got), len(want), got)
+	}
+	for i, c := range got {
+		if c.Selector != want[i] {
+			t.Fatalf("candidate %d selector = %q, expected %q", i, c.Selector, want[i])
+		}
+	}
+	if got[0].TextLength != 69 {
+		t.Fatalf("TextLength = %d, expected 69", got[0].TextLength)
+	}
+	if want := "Story A long paragraph of story text that goes on and on for…"; got[0].Preview != want {
+		t.Fatalf("Preview = %q, expected %q", got[0].Preview, want)
+	}
+}
+
+func TestConvertHTMLPickContent(t *testing.T) {
+	var offered []Candidate
+	pick := func(i int) func([]Candidate) (int, error) {
+		return func(c []Candidate) (int, error) {
+			offered = c
+			return i, nil
+		}
+	}
+
+	res, err := ConvertHTML(context.Background(), []byte(pickPage), nil, Options{PickContent: pick(0)})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if len(offered) == 0 {
+		t.Fatal("PickContent was not called")
+	}
+	if !strings.HasPrefix(res.Markdown, "# Story") || strings.Contains(res.Markdown, "Sidebar") {
+		t.Fatalf("Markdown = %q, expected only the article", res.Markdown)
+	}
+
+	res, err = ConvertHTML(context.Background(), []byte(pickPage), nil, Options{PickContent: pick(-1)})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "Home") || !strings.Contains(res.Markdown, "Sidebar two") {
+		t.Fatalf("Markdown = %q, expected the whole page", res.Markdown)
+	}
+
+	offered = nil
+	if _, err := ConvertHTML(context.Background(), []byte(pickPage), nil, Options{Select: "nav", PickContent: pick(0)}); err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if offered != nil {
+		t.Fatal("PickContent was called although Select was set")
+	}
+
+	_, err = ConvertHTML(context.Background(), []byte(pickPage), nil, Options{PickContent: func([]Candidate) (int, error) {
+		return 0, errors.New("aborted")
+	}})
+	if Kind(err) != KindInvalidInput {
+		t.Fatalf("ConvertHTML error = %v, expected KindInvalidInput", err)
+	}
+}
@@ -0,0 +1,55 @@
+package url2md
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter spaces out the requests made to each host. Every host gets its
+// own budget, so a slow host does not hold back the others. It is safe for
+// concurrent use and meant to be shared by all the conversions of a batch.
+type RateLimiter struct {
+	every time.Duration
+	mu    sync.Mutex
+	hosts map[string]*rate.Limiter
+}
+
+// NewRateLimiter returns a limiter allowing one request to each host every
+// interval.
+func NewRateLimiter(every time.Duration) *RateLimiter {
+	return &RateLimiter{every: every, hosts: map[string]*rate.Limiter{}}
+}
+
+// wait blocks until a request to host is allowed or ctx is done. A nil
+// limiter never waits.
+func (l *RateLimiter) wait(ctx context.Context, host string, opts *Options) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	limiter, ok := l.hosts[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(l.every), 1)
+		l.hosts[host] = limiter
+	}
+	l.mu.Unlock()
+
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+	opts.debug("Waiting for the rate limit", "host", host, "delay", delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
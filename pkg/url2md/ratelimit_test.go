@@ -0,0 +1,50 @@
+package url2md
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPerHost(t *testing.T) {
+	limiter := NewRateLimiter(100 * time.Millisecond)
+	opts := &Options{Logf: t.Logf}
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, host := range []string{"a.example", "b.example"} {
+		if err := limiter.wait(ctx, host, opts); err != nil {
+			t.Fatalf("wait(%s) returned error: %v", host, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("first requests to two hosts took %s, expected no wait", elapsed)
+	}
+
+	if err := limiter.wait(ctx, "a.example", opts); err != nil {
+		t.Fatalf("wait returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("second request to a.example after %s, expected about 100ms", elapsed)
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	limiter := NewRateLimiter(time.Hour)
+	opts := &Options{Logf: t.Logf}
+	if err := limiter.wait(context.Background(), "a.example", opts); err != nil {
+		t.Fatalf("wait returned error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx, "a.example", opts); err != context.DeadlineExceeded {
+		t.Fatalf("wait error = %v, expected context.DeadlineExceeded", err)
+	}
+}
+
+func TestNilRateLimiter(t *testing.T) {
+	var limiter *RateLimiter
+	if err := limiter.wait(context.Background(), "a.example", &Options{}); err != nil {
+		t.Fatalf("wait returned error: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package url2md
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+// minArticleLength is the amount of text, in characters, an extracted article
+// needs before it is preferred over converting the whole document.
+const minArticleLength = 140
+
+// article is the main content isolated from a page by extractArticle.
+type article struct {
+	title string
+	html  []byte
+}
+
+// extractArticle runs a readability pass over page and returns the HTML of its
+// main content. It returns an error when nothing substantial was found, so the
+// caller can fall back to the full document.
+func extractArticle(page []byte, pageURL *url.URL) (article, error) {
+	parsed, err := readability.FromReader(bytes.NewReader(page), pageURL)
+	if err != nil {
+		return article{}, err
+	}
+	if len(strings.TrimSpace(parsed.TextContent)) < minArticleLength {
+		return article{}, errors.New("no substantial content found")
+	}
+
+	return article{
+		title: strings.TrimSpace(parsed.Title),
+		html:  []byte(parsed.Content),
+	}, nil
+}
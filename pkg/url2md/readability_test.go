@@ -0,0 +1,38 @@
+package url2md
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractArticleDropsBoilerplate(t *testing.T) {
+	paragraph := "<p>Readability keeps the long, comma-rich paragraphs that make up the body of an article, and it drops the short links around it.</p>"
+	page := `<html><head><title>Release notes</title></head><body>
+<nav><a href="/">Home</a> <a href="/blog">Blog</a></nav>
+<article><h1>Release notes</h1>` + strings.Repeat(paragraph, 5) + `</article>
+<footer>Copyright footer text</footer>
+</body></html>`
+	base, _ := url.Parse("https://example.com/release")
+
+	got, err := extractArticle([]byte(page), base)
+	if err != nil {
+		t.Fatalf("extractArticle returned error: %v", err)
+	}
+	if got.title != "Release notes" {
+		t.Fatalf("title = %q, expected %q", got.title, "Release notes")
+	}
+	if !strings.Contains(string(got.html), "comma-rich paragraphs") {
+		t.Fatalf("article content is missing the body text: %s", got.html)
+	}
+	if strings.Contains(string(got.html), "Copyright footer") {
+		t.Fatalf("article content still contains the footer: %s", got.html)
+	}
+}
+
+func TestExtractArticleRejectsThinPages(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	if _, err := extractArticle([]byte(`<html><body><div id="app"></div></body></html>`), base); err == nil {
+		t.Fatalf("extractArticle returned nil error for an empty page")
+	}
+}
@@ -0,0 +1,49 @@
+package url2md
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxMetaRefreshDelay is the longest delay, in seconds, of a meta refresh
+// that is treated as a redirect.
+const maxMetaRefreshDelay = 5
+
+var metaRefreshRe = regexp.MustCompile(`(?i)^\s*(\d+)(?:\.\d*)?\s*(?:[;,]\s*(?:url\s*=\s*)?(.*))?$`)
+
+// metaRefreshTarget returns the URL a page redirects to with
+// <meta http-equiv="refresh">, resolved against base. It returns nil when
+// there is no such tag, the delay is longer than maxMetaRefreshDelay, or the
+// page only refreshes itself.
+func metaRefreshTarget(page []byte, base *url.URL) *url.URL {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil
+	}
+
+	var target *url.URL
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(meta.AttrOr("http-equiv", "")), "refresh") {
+			return true
+		}
+		m := metaRefreshRe.FindStringSubmatch(meta.AttrOr("content", ""))
+		if m == nil {
+			return false
+		}
+		delay, err := strconv.Atoi(m[1])
+		raw := strings.Trim(strings.TrimSpace(m[2]), `'"`)
+		if err != nil || delay > maxMetaRefreshDelay || raw == "" {
+			return false
+		}
+		if u, err := base.Parse(raw); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.String() != base.String() {
+			target = u
+		}
+		return false
+	})
+	return target
+}
@@ -0,0 +1,88 @@
+package url2md
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMetaRefreshTarget(t *testing.T) {
+	base, _ := url.Parse("https://example.com/old/")
+	cases := map[string]string{
+		`<meta http-equiv="refresh" content="0;url=/new">`:                     "https://example.com/new",
+		`<meta http-equiv="Refresh" content="3; URL='next.html'">`:             "https://example.com/old/next.html",
+		`<meta http-equiv="refresh" content="0, https://other.example.org">`:   "https://other.example.org",
+		`<meta http-equiv="refresh" content="30;url=/later">`:                  "",
+		`<meta http-equiv="refresh" content="5">`:                              "",
+		`<meta http-equiv="refresh" content="0;url=https://example.com/old/">`: "",
+		`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`:      "",
+		`<meta name="refresh" content="0;url=/new">`:                           "",
+	}
+	for page, want := range cases {
+		got := ""
+		if u := metaRefreshTarget([]byte("<head>"+page+"</head>"), base); u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Fatalf("metaRefreshTarget(%s) = %q, expected %q", page, got, want)
+		}
+	}
+}
+
+func TestConvertFollowMetaRefresh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/landing":
+			io.WriteString(w, `<head><meta http-equiv="refresh" content="0;url=/docs/"></head><body>Redirecting…</body>`)
+		case "/moved":
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		case "/loop":
+			fmt.Fprintf(w, `<meta http-equiv="refresh" content="0;url=/loop?n=%s1">`, r.URL.Query().Get("n"))
+		default:
+			io.WriteString(w, `<h1>Docs</h1><a href="intro">Intro</a>`)
+		}
+	}))
+	defer srv.Close()
+
+	res, err := Convert(context.Background(), srv.URL+"/landing", Options{FollowMetaRefresh: true, NoProxy: true, Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if res.FinalURL.String() != srv.URL+"/docs/" {
+		t.Fatalf("FinalURL = %s, expected %s/docs/", res.FinalURL, srv.URL)
+	}
+	if !strings.Contains(res.Markdown, "# Docs") || !strings.Contains(res.Markdown, "("+srv.URL+"/docs/intro)") {
+		t.Fatalf("markdown = %q, expected the refreshed page with links resolved against it", res.Markdown)
+	}
+
+	res, err = Convert(context.Background(), srv.URL+"/landing", Options{NoProxy: true})
+	if err != nil || !strings.Contains(res.Markdown, "Redirecting") {
+		t.Fatalf("Convert without FollowMetaRefresh = %q, %v; expected the landing page", res.Markdown, err)
+	}
+
+	_, err = Convert(context.Background(), srv.URL+"/loop", Options{FollowMetaRefresh: true, MaxRedirects: 3, NoProxy: true})
+	if err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Fatalf("Convert error = %v, expected the redirect limit", err)
+	}
+
+	// The HTTP redirect to /landing and its refresh share the budget.
+	if _, err := Convert(context.Background(), srv.URL+"/moved", Options{FollowMetaRefresh: true, MaxRedirects: 2, NoProxy: true}); err != nil {
+		t.Fatalf("Convert within the redirect budget returned error: %v", err)
+	}
+	for _, limit := range []int{1, -1} {
+		_, err = Convert(context.Background(), srv.URL+"/moved", Options{FollowMetaRefresh: true, MaxRedirects: limit, NoProxy: true})
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("stopped after %d redirects", max(limit, 0))) {
+			t.Fatalf("MaxRedirects %d: Convert error = %v, expected the redirect limit", limit, err)
+		}
+	}
+	_, err = Convert(context.Background(), srv.URL+"/landing", Options{FollowMetaRefresh: true, MaxRedirects: -1, NoProxy: true})
+	if err == nil || !strings.Contains(err.Error(), "stopped after 0 redirects") {
+		t.Fatalf("MaxRedirects -1: Convert error = %v, expected no refresh to be followed", err)
+	}
+}
@@ -0,0 +1,119 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// retryBaseDelay is the backoff before the first retry; it doubles with each
+// further attempt.
+var retryBaseDelay = 500 * time.Millisecond
+
+// maxRetryDelay caps the backoff before jitter, so a long -retries run keeps
+// retrying every half minute instead of overflowing the shift.
+const maxRetryDelay = 30 * time.Second
+
+// doWithRetry sends the request built by newReq, retrying up to opts.Retries
+// times on connection errors and 429/503 responses. Retries back off
+// exponentially with jitter, honor Retry-After, and stop early when the next
+// attempt would not fit before the context deadline. The last response or
+// error is returned as-is.
+func doWithRetry(ctx context.Context, client *http.Client, newReq func() (*http.Request, error), opts *Options) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		var reason string
+		switch {
+		case err != nil && ctx.Err() == nil && isTransient(err):
+			reason = err.Error()
+		case err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable):
+			reason = resp.Status
+		default:
+			return resp, err
+		}
+		if attempt >= opts.Retries {
+			return resp, err
+		}
+
+		delay := backoffDelay(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = after
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			opts.warn("Not retrying: next attempt would exceed the timeout", "url", req.URL.String(), "delay", delay.Round(time.Millisecond))
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		opts.info("Retrying", "reason", reason, "url", req.URL.String(), "delay", delay.Round(time.Millisecond), "attempt", attempt+1, "retries", opts.Retries)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay returns the exponential backoff for a retry attempt, capped at
+// maxRetryDelay, with up to 50% random jitter so concurrent workers don't
+// retry in lockstep.
+func backoffDelay(attempt int) time.Duration {
+	delay := min(retryBaseDelay<<min(attempt, 10), maxRetryDelay)
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// retryAfter parses a Retry-After header given either as a number of seconds
+// or as an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// isTransient reports whether err is a connection-level failure that may
+// succeed on a second attempt.
+func isTransient(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,110 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"Sat, 01 Jun 2024 12:00:10 GMT", 10 * time.Second, true},
+		{"Sat, 01 Jun 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tc := range cases {
+		got, ok := retryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("retryAfter(%q) = %s, %v; expected %s, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	cases := []struct {
+		attempt int
+		min     time.Duration
+	}{
+		{0, retryBaseDelay},
+		{3, 8 * retryBaseDelay},
+		{35, maxRetryDelay},
+		{64, maxRetryDelay},
+		{1 << 20, maxRetryDelay},
+	}
+	for _, tc := range cases {
+		got := backoffDelay(tc.attempt)
+		if got < tc.min || got > tc.min+tc.min/2 {
+			t.Fatalf("backoffDelay(%d) = %s, expected between %s and %s", tc.attempt, got, tc.min, tc.min+tc.min/2)
+		}
+	}
+}
+
+func TestFetchHTMLRetriesServiceUnavailable(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			return
+		}
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>ok</p>"))
+	}))
+	defer srv.Close()
+
+	opts := &Options{UserAgent: DefaultUserAgent, MaxRedirects: 10, Retries: 2, NoProxy: true, Logf: t.Logf}
+	target, _ := url.Parse(srv.URL + "/page")
+
+	page, err := fetchHTML(context.Background(), newClient(opts), target, opts)
+	if err != nil {
+		t.Fatalf("fetchHTML returned error: %v", err)
+	}
+	if string(page.body) != "<p>ok</p>" {
+		t.Fatalf("body = %q, expected %q", page.body, "<p>ok</p>")
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("server saw %d attempts, expected 3", n)
+	}
+}
+
+func TestFetchHTMLGivesUpAfterRetries(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	opts := &Options{UserAgent: DefaultUserAgent, MaxRedirects: 10, Retries: 1, NoProxy: true, Logf: t.Logf}
+	target, _ := url.Parse(srv.URL + "/page")
+
+	if _, err := fetchHTML(context.Background(), newClient(opts), target, opts); err == nil {
+		t.Fatalf("fetchHTML returned nil error, expected 429 failure")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("server saw %d attempts, expected 2", n)
+	}
+}
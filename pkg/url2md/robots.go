@@ -0,0 +1,239 @@
+package url2md
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsGroup holds the rules that apply to a set of user agents.
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// robotsRules is a parsed robots.txt file.
+type robotsRules struct {
+	groups []robotsGroup
+	// disallowAll is set when robots.txt could not be retrieved because of a
+	// server error, which RFC 9309 treats as a complete disallow.
+	disallowAll bool
+}
+
+// parseRobots parses a robots.txt body. Unknown fields are ignored.
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if !inAgents {
+				rules.groups = append(rules.groups, robotsGroup{})
+				current = &rules.groups[len(rules.groups)-1]
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (field == "disallow" && value == "") {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: field == "allow", pattern: value, re: robotsPattern(value)})
+		}
+	}
+	return rules
+}
+
+// allowed reports whether userAgent may fetch path (including any query).
+// The most specific group matching userAgent applies, falling back to "*";
+// within it the longest matching rule wins and Allow wins ties.
+func (r *robotsRules) allowed(userAgent, path string) bool {
+	if r.disallowAll {
+		return false
+	}
+
+	group := r.groupFor(strings.ToLower(userAgent))
+	if group == nil {
+		return true
+	}
+
+	allow, matched := true, -1
+	for _, rule := range group.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if n := len(rule.pattern); n > matched || (n == matched && rule.allow) {
+			allow, matched = rule.allow, n
+		}
+	}
+	return allow
+}
+
+func (r *robotsRules) groupFor(userAgent string) *robotsGroup {
+	var best, wildcard *robotsGroup
+	bestLen := 0
+	for i := range r.groups {
+		g := &r.groups[i]
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+			} else if agent != "" && strings.Contains(userAgent, agent) && len(agent) > bestLen {
+				best, bestLen = g, len(agent)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return wildcard
+}
+
+// robotsPattern compiles a robots.txt path pattern, where '*' matches any
+// sequence of characters and a trailing '$' anchors the end of the path.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// RobotsCache keeps the parsed robots.txt of every host seen, so that the
+// Convert calls sharing it through Options.Robots fetch each file only once.
+// Only robots.txt files that were retrieved, or found missing, are kept: a
+// network error, a timeout or a server error disallows the URL being
+// checked, and the next URL on that host asks again. It is safe for
+// concurrent use.
+type RobotsCache struct {
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	mu    sync.Mutex
+	rules *robotsRules // nil until retrieved
+}
+
+// NewRobotsCache returns an empty RobotsCache. Create one per run, so that
+// a robots.txt changed in the meantime is read again by the next one.
+func NewRobotsCache() *RobotsCache {
+	return &RobotsCache{entries: map[string]*robotsEntry{}}
+}
+
+// rules returns the robots.txt rules of origin, fetching them on the first
+// request for origin or after a failed one. A nil cache always fetches.
+func (c *RobotsCache) rules(ctx context.Context, client *http.Client, origin string, opts *Options) *robotsRules {
+	if c == nil {
+		rules, _ := fetchRobots(ctx, client, origin, opts)
+		return rules
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[origin]
+	if !ok {
+		entry = &robotsEntry{}
+		c.entries[origin] = entry
+	}
+	c.mu.Unlock()
+
+	// Holding the entry lock while fetching makes the other workers asking
+	// for the same host wait for the result instead of fetching it too.
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.rules != nil {
+		return entry.rules
+	}
+	rules, keep := fetchRobots(ctx, client, origin, opts)
+	if keep {
+		entry.rules = rules
+	}
+	return rules
+}
+
+// robotsAllowed reports whether robots.txt on the target's host allows
+// fetching it with the configured user agent. data: URLs have no host and are
+// always allowed.
+func robotsAllowed(ctx context.Context, client *http.Client, target *url.URL, opts *Options) bool {
+	if target.Scheme == "data" {
+		return true
+	}
+	origin := target.Scheme + "://" + target.Host
+	rules := opts.Robots.rules(ctx, client, origin, opts)
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return rules.allowed(opts.UserAgent, path)
+}
+
+// fetchRobots downloads and parses origin's robots.txt. A missing file (4xx)
+// allows everything; server errors, unreachable hosts and bodies over
+// Options.MaxSize disallow everything. keep reports whether the result may
+// be cached, which is not the case for failures that may be transient.
+func fetchRobots(ctx context.Context, client *http.Client, origin string, opts *Options) (rules *robotsRules, keep bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return &robotsRules{}, true
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		opts.warn("Could not fetch robots.txt", "origin", origin, "error", err)
+		return &robotsRules{disallowAll: true}, false
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		opts.warn("robots.txt returned an error, treating as disallowed", "origin", origin, "status", resp.Status)
+		return &robotsRules{disallowAll: true}, false
+	case resp.StatusCode >= 400:
+		opts.debug("No robots.txt", "origin", origin, "status", resp.Status)
+		return &robotsRules{}, true
+	}
+	body, err := readLimited(resp.Body, opts.MaxSize)
+	if err != nil {
+		opts.warn("Could not read robots.txt, treating as disallowed", "origin", origin, "error", err)
+		return &robotsRules{disallowAll: true}, errors.Is(err, ErrTooLarge)
+	}
+	return parseRobots(bytes.NewReader(body)), true
+}
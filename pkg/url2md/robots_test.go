@@ -0,0 +1,121 @@
+package url2md
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const sampleRobots = `# sample
+User-agent: *
+Disallow: /private/
+Allow: /private/public-page
+Disallow: /*.pdf$
+
+User-agent: url2md
+User-agent: other-bot
+Disallow: /
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(sampleRobots))
+	cases := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{"Mozilla/5.0 Chrome", "/docs/intro", true},
+		{"Mozilla/5.0 Chrome", "/private/secret", false},
+		{"Mozilla/5.0 Chrome", "/private/public-page", true},
+		{"Mozilla/5.0 Chrome", "/files/report.pdf", false},
+		{"Mozilla/5.0 Chrome", "/files/report.pdf?download=1", true},
+		{"Mozilla/5.0 Chrome", "/files/a.pdf.pdf", false},
+		{"url2md/1.0", "/docs/intro", false},
+	}
+
+	for _, tc := range cases {
+		if got := rules.allowed(tc.agent, tc.path); got != tc.allowed {
+			t.Fatalf("allowed(%q, %q) = %v, expected %v", tc.agent, tc.path, got, tc.allowed)
+		}
+	}
+}
+
+func TestRobotsEmptyDisallowAllowsEverything(t *testing.T) {
+	rules := parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"))
+	if !rules.allowed("any", "/anything") {
+		t.Fatalf("empty Disallow should allow everything")
+	}
+}
+
+func TestRobotsAllowedCachesPerHost(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			hits.Add(1)
+			w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+		}
+	}))
+	defer srv.Close()
+
+	opts := &Options{UserAgent: DefaultUserAgent, Robots: NewRobotsCache(), Logf: t.Logf}
+	for _, path := range []string{"/docs", "/admin/users", "/blog"} {
+		target, _ := url.Parse(srv.URL + path)
+		want := path != "/admin/users"
+		if got := robotsAllowed(context.Background(), srv.Client(), target, opts); got != want {
+			t.Fatalf("robotsAllowed(%s) = %v, expected %v", path, got, want)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("robots.txt fetched %d times, expected once", n)
+	}
+}
+
+func TestRobotsAllowedRetriesFailures(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			return
+		}
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+	}))
+	defer srv.Close()
+
+	opts := &Options{UserAgent: DefaultUserAgent, Robots: NewRobotsCache(), Logf: t.Logf}
+	target, _ := url.Parse(srv.URL + "/docs")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if robotsAllowed(ctx, srv.Client(), target, opts) {
+		t.Fatalf("robotsAllowed with a cancelled context = true, expected false")
+	}
+	if robotsAllowed(context.Background(), srv.Client(), target, opts) {
+		t.Fatalf("robotsAllowed on a 503 = true, expected false")
+	}
+	for i := 0; i < 2; i++ {
+		if !robotsAllowed(context.Background(), srv.Client(), target, opts) {
+			t.Fatalf("robotsAllowed after the server recovered = false, expected true")
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("robots.txt fetched %d times, expected twice", n)
+	}
+}
+
+func TestRobotsAllowedMaxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nAllow: /\n" + strings.Repeat("#", 100)))
+	}))
+	defer srv.Close()
+
+	opts := &Options{UserAgent: DefaultUserAgent, MaxSize: 64, Logf: t.Logf}
+	target, _ := url.Parse(srv.URL + "/docs")
+	if robotsAllowed(context.Background(), srv.Client(), target, opts) {
+		t.Fatalf("robotsAllowed with an oversized robots.txt = true, expected false")
+	}
+}
@@ -0,0 +1,210 @@
+package url2md
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// builtinRules are the conversion rules registered on top of the
+// converter's CommonMark rules, before Options.Rules.
+var builtinRules = []md.Rule{kbdRule, detailsRule, summaryRule}
+
+// kbdRule writes <kbd> as inline code. Key combinations written as nested
+// <kbd> elements, like <kbd><kbd>Ctrl</kbd>+<kbd>C</kbd></kbd>, keep one
+// code span per key instead of merging them.
+var kbdRule = md.Rule{
+	Filter: []string{"kbd"},
+	Replacement: func(content string, selec *goquery.Selection, _ *md.Options) *string {
+		if selec.Find("kbd").Length() > 0 {
+			return &content
+		}
+		text := strings.Join(strings.Fields(selec.Text()), " ")
+		if text == "" {
+			return &text
+		}
+		fence := "`"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+			text = " " + text + " "
+		}
+		text = fence + text + fence
+		if !selec.Parent().Is("kbd") {
+			text = md.AddSpaceIfNessesary(selec, text)
+		}
+		return &text
+	},
+}
+
+// detailsRule keeps <details> as raw HTML, which GitHub-flavored Markdown
+// renders as a collapsible section: the <summary> goes on the line after
+// the opening tag and the content, converted to Markdown, between blank
+// lines so that renderers parse it. Nested details nest the same way.
+var detailsRule = md.Rule{
+	Filter: []string{"details"},
+	Replacement: func(content string, selec *goquery.Selection, _ *md.Options) *string {
+		open := "<details>"
+		if _, ok := selec.Attr("open"); ok {
+			open = "<details open>"
+		}
+		if summary := strings.Join(strings.Fields(selec.ChildrenFiltered("summary").First().Text()), " "); summary != "" {
+			open += "\n<summary>" + html.EscapeString(summary) + "</summary>"
+		}
+		text := "\n\n" + open + "\n\n"
+		if content = strings.TrimSpace(content); content != "" {
+			text += content + "\n\n"
+		}
+		text += "</details>\n\n"
+		return &text
+	},
+}
+
+// summaryRule drops the <summary> of a <details> from its content, since
+// detailsRule writes it with the opening tag.
+var summaryRule = md.Rule{
+	Filter: []string{"summary"},
+	Replacement: func(_ string, selec *goquery.Selection, _ *md.Options) *string {
+		if !selec.Parent().Is("details") {
+			return nil
+		}
+		text := ""
+		return &text
+	},
+}
+
+// headDetails rewrites the <details> of selec for Options.DetailsHeadings:
+// each becomes a plain <div>, and its <summary> a heading one level
+// below the heading before it, or below the heading of the details it is
+// nested in.
+func headDetails(selec *goquery.Selection) {
+	type section struct {
+		details *goquery.Selection
+		level   int
+	}
+	var sections []section
+	base := 1
+	levels := map[*html.Node]int{}
+	selec.Find("h1, h2, h3, h4, h5, h6, details").Each(func(_ int, s *goquery.Selection) {
+		if !s.Is("details") {
+			base = int(goquery.NodeName(s)[1] - '0')
+			return
+		}
+		level := base + 1
+		if outer := s.ParentsFiltered("details").First(); outer.Length() > 0 {
+			level = levels[outer.Get(0)] + 1
+		}
+		level = min(level, 6)
+		levels[s.Get(0)] = level
+		sections = append(sections, section{s, level})
+	})
+	for _, sec := range sections {
+		if summary := sec.details.ChildrenFiltered("summary").First(); summary.Length() > 0 {
+			tag := fmt.Sprintf("h%d", sec.level)
+			heading := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+			for c := summary.Get(0).FirstChild; c != nil; c = summary.Get(0).FirstChild {
+				summary.Get(0).RemoveChild(c)
+				heading.AppendChild(c)
+			}
+			summary.ReplaceWithNodes(heading)
+		}
+		// A <div> keeps the content a block of its own.
+		node := sec.details.Get(0)
+		node.Data, node.DataAtom, node.Attr = "div", atom.Div, nil
+	}
+}
+
+// figcaptionRule keeps the caption of a <figure>: as an italic line after
+// the image of image figures, and as a blockquote for other figures, such
+// as code listings. It is registered with Options.Figures.
+var figcaptionRule = md.Rule{
+	Filter: []string{"figcaption"},
+	Replacement: func(content string, selec *goquery.Selection, _ *md.Options) *string {
+		figure := selec.Closest("figure")
+		caption := strings.Join(strings.Fields(content), " ")
+		if figure.Length() == 0 || caption == "" {
+			return nil
+		}
+		if figure.Find("img").Length() > 0 {
+			// Use the other emphasis delimiter when the caption already
+			// contains emphasis, so the two do not close each other.
+			delim := "_"
+			if strings.Contains(caption, "_") {
+				delim = "*"
+			}
+			caption = "\n\n" + delim + caption + delim + "\n\n"
+		} else {
+			caption = "\n\n> " + caption + "\n\n"
+		}
+		return &caption
+	},
+}
+
+// blankLinesRe matches the runs of blank lines the converter leaves between
+// the blocks of an element's content.
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// definitionListRules returns the rules writing <dt> and <dd> in style.
+// Markdown has no definition lists of its own, so DefinitionListBold writes
+// them with bold and blockquotes, and DefinitionListExtra with the syntax of
+// the extensions that have them.
+func definitionListRules(style DefinitionListStyle) []md.Rule {
+	term := md.Rule{
+		Filter: []string{"dt"},
+		Replacement: func(content string, selec *goquery.Selection, _ *md.Options) *string {
+			text := strings.Join(strings.Fields(content), " ")
+			if text == "" {
+				return &text
+			}
+			if style == DefinitionListExtra {
+				// Terms sharing a definition stay on consecutive lines.
+				if !selec.Prev().Is("dt") {
+					text = "\n\n" + text
+				}
+				text += "\n"
+				return &text
+			}
+			if !strings.HasPrefix(text, "**") || !strings.HasSuffix(text, "**") {
+				text = "**" + text + "**"
+			}
+			text = "\n\n" + text + "\n\n"
+			return &text
+		},
+	}
+	definition := md.Rule{
+		Filter: []string{"dd"},
+		Replacement: func(content string, _ *goquery.Selection, _ *md.Options) *string {
+			content = blankLinesRe.ReplaceAllString(strings.TrimSpace(content), "\n\n")
+			if content == "" {
+				return &content
+			}
+			var text string
+			if style == DefinitionListExtra {
+				text = ": " + indentLines(content, "    ") + "\n"
+			} else {
+				text = "\n\n> " + strings.ReplaceAll(content, "\n", "\n> ") + "\n\n"
+				text = strings.ReplaceAll(text, "> \n", ">\n")
+			}
+			return &text
+		},
+	}
+	return []md.Rule{term, definition}
+}
+
+// indentLines indents every line of s but the first with prefix, leaving
+// blank lines empty.
+func indentLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,110 @@
+package url2md
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestKbdRule(t *testing.T) {
+	tests := map[string]string{
+		`<p>Press <kbd>Enter</kbd> to continue.</p>`:                "Press `Enter` to continue.",
+		`<p>Copy with <kbd><kbd>Ctrl</kbd>+<kbd>C</kbd></kbd>.</p>`: "Copy with `Ctrl`+`C`.",
+		"<p>Type <kbd>\n  git   status\n</kbd></p>":                 "Type `git status`",
+		"<p>The <kbd>`</kbd> key</p>":                               "The `` ` `` key",
+	}
+	for page, want := range tests {
+		got, err := convertToMarkdown(nil, []byte(page), &Options{})
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("convertToMarkdown(%q) = %q, expected %q", page, got, want)
+		}
+	}
+}
+
+func TestOptionsRules(t *testing.T) {
+	mark := md.Rule{
+		Filter: []string{"mark", "kbd"},
+		Replacement: func(content string, _ *goquery.Selection, _ *md.Options) *string {
+			text := "==" + strings.TrimSpace(content) + "=="
+			return &text
+		},
+	}
+	got, err := convertToMarkdown(nil, []byte(`<p><mark>Note</mark> and <kbd>Esc</kbd></p>`), &Options{Rules: []md.Rule{mark}})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	if want := "==Note== and ==Esc=="; got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+}
+
+func TestFigcaptionRule(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "figures.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/post")
+
+	got, err := convertToMarkdown(base, page, &Options{Figures: true})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	want := "Intro.\n\n![Chart](https://example.com/img/chart.png)\n\n*Figure 1: requests per _second_.*\n\n" +
+		"```\ngo test ./...\n```\n\n> Listing 1: running the tests\n\n![Plain](https://example.com/img/no-caption.png)"
+	if got != want {
+		t.Fatalf("convertToMarkdown = %q, expected %q", got, want)
+	}
+}
+
+func TestDefinitionListRules(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "definition-lists.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[DefinitionListStyle]string{
+		DefinitionListBold: "Glossary:\n\n**Markdown**\n\n> A lightweight _markup_ language.\n\n**CLI**\n\n**Command line**\n\n" +
+			"> A text interface.\n>\n> See `url2md -h`.\n\n> A shell.\n\nEnd.",
+		DefinitionListExtra: "Glossary:\n\nMarkdown\n: A lightweight _markup_ language.\n\nCLI\nCommand line\n" +
+			": A text interface.\n\n    See `url2md -h`.\n: A shell.\n\nEnd.",
+	}
+	for style, want := range tests {
+		got, err := convertToMarkdown(nil, page, &Options{DefinitionListStyle: style})
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("convertToMarkdown with %s = %q, expected %q", style, got, want)
+		}
+	}
+}
+
+func TestDetailsRule(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "details.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[bool]string{
+		false: "## FAQ\n\n<details open>\n<summary>How do I install it?</summary>\n\nRun `go install`.\n\n" +
+			"<details>\n<summary>On Windows &amp; macOS</summary>\n\n- Use the release binaries.\n\n</details>\n\n</details>\n\n" +
+			"<details>\n<summary>Is it free?</summary>\n\nYes.\n\n</details>\n\nEnd.",
+		true: "## FAQ\n\n### How do I _install_ it?\n\nRun `go install`.\n\n#### On Windows & macOS\n\n- Use the release binaries.\n\n" +
+			"### Is it free?\n\nYes.\n\nEnd.",
+	}
+	for headings, want := range tests {
+		got, err := convertToMarkdown(nil, page, &Options{DetailsHeadings: headings})
+		if err != nil {
+			t.Fatalf("convertToMarkdown returned error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("convertToMarkdown with DetailsHeadings %v = %q, expected %q", headings, got, want)
+		}
+	}
+}
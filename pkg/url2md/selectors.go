@@ -0,0 +1,112 @@
+package url2md
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// ErrNoMatch is returned when Options.Select or Options.Split matches nothing
+// in the page.
+var ErrNoMatch = errors.New("selector matched no elements")
+
+// validateSelector reports a syntax error in a CSS selector. goquery silently
+// treats invalid selectors as matching nothing, which would hide typos.
+func validateSelector(selector string) error {
+	if _, err := cascadia.ParseGroup(selector); err != nil {
+		return classify(KindInvalidInput, fmt.Errorf("invalid selector %q: %w", selector, err))
+	}
+	return nil
+}
+
+// selectHTML returns the outer HTML of the elements of page matching
+// selector, concatenated in document order. Matches nested inside another
+// match are only included once, as part of their ancestor.
+func selectHTML(page []byte, selector string) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := doc.Find(selector)
+	if matches.Length() == 0 {
+		return nil, classify(KindConversion, fmt.Errorf("%q: %w", selector, ErrNoMatch))
+	}
+
+	var parts []string
+	var renderErr error
+	matches.Each(func(_ int, s *goquery.Selection) {
+		if renderErr != nil || s.ParentsFiltered(selector).Length() > 0 {
+			return
+		}
+		html, err := goquery.OuterHtml(s)
+		if err != nil {
+			renderErr = err
+			return
+		}
+		parts = append(parts, html)
+	})
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	return []byte(strings.Join(parts, "\n")), nil
+}
+
+// excludeHTML removes every element of page matching one of selectors.
+func excludeHTML(page []byte, selectors []string) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	for _, selector := range selectors {
+		doc.Find(selector).Remove()
+	}
+	html, err := doc.Html()
+	return []byte(html), err
+}
+
+// DefaultNavSelectors are the CSS selectors of the boilerplate removed by
+// Options.TrimNav when Options.NavSelectors is empty: navigation, page
+// headers and footers, sidebars, and elements whose class or id has a
+// word starting with nav, menu or sidebar, such as "site-nav" or "navbar".
+// The last two use the regular expression attribute selector "#=" of
+// cascadia, the selector engine of goquery.
+var DefaultNavSelectors = []string{
+	"nav",
+	"header",
+	"footer",
+	"aside",
+	"[role=navigation]",
+	`[class#=(?i)(^|[\s_-])(nav|menu|sidebar)]`,
+	`[id#=(?i)(^|[\s_-])(nav|menu|sidebar)]`,
+}
+
+// mainContentSelector matches the elements holding the main column of a
+// page, which trimNav never removes.
+const mainContentSelector = "main, article, [role=main]"
+
+// trimNav removes the boilerplate elements of page matching one of
+// selectors. Elements containing the main content, like a "sidebar-layout"
+// wrapper around <main>, are kept, and so are the <header> and <footer> of
+// an article, which hold its title and byline.
+func trimNav(page []byte, selectors []string) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	doc.Find(strings.Join(selectors, ", ")).Each(func(_ int, s *goquery.Selection) {
+		if s.Find(mainContentSelector).Length() > 0 {
+			return
+		}
+		if s.Is("header, footer") && s.ParentsFiltered(mainContentSelector).Length() > 0 {
+			return
+		}
+		s.Remove()
+	})
+	html, err := doc.Html()
+	return []byte(html), err
+}
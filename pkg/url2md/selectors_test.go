@@ -0,0 +1,122 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const selectorsPage = `<html><body>
+<nav>Menu</nav>
+<article class="post"><h1>First</h1><div class="post">Nested</div></article>
+<aside>Sidebar</aside>
+<article class="post"><h1>Second</h1></article>
+</body></html>`
+
+func TestSelectHTMLConcatenatesMatchesInOrder(t *testing.T) {
+	got, err := selectHTML([]byte(selectorsPage), ".post")
+	if err != nil {
+		t.Fatalf("selectHTML returned error: %v", err)
+	}
+
+	html := string(got)
+	if strings.Contains(html, "Menu") || strings.Contains(html, "Sidebar") {
+		t.Fatalf("selectHTML kept content outside the selection: %s", html)
+	}
+	first, second := strings.Index(html, "First"), strings.Index(html, "Second")
+	if first < 0 || second < 0 || first > second {
+		t.Fatalf("selectHTML did not keep both matches in document order: %s", html)
+	}
+	if strings.Count(html, "Nested") != 1 {
+		t.Fatalf("nested match was duplicated: %s", html)
+	}
+}
+
+func TestSelectHTMLNoMatch(t *testing.T) {
+	_, err := selectHTML([]byte(selectorsPage), "main")
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("selectHTML error = %v, expected ErrNoMatch", err)
+	}
+}
+
+func TestValidateSelector(t *testing.T) {
+	if err := validateSelector("article.post > h1, main"); err != nil {
+		t.Fatalf("validateSelector returned error for a valid selector: %v", err)
+	}
+	if err := validateSelector("div["); err == nil {
+		t.Fatalf("validateSelector returned nil error for an invalid selector")
+	}
+}
+
+func TestExcludeHTMLDropsMatchesFromMarkdown(t *testing.T) {
+	page := `<body>
+<div id="cookie-banner">We use cookies</div>
+<nav><a href="/">Home</a></nav>
+<main><h1>Title</h1><p>Body text</p><div class="advert">Buy now</div></main>
+</body>`
+
+	got, err := excludeHTML([]byte(page), []string{"#cookie-banner", "nav", ".advert"})
+	if err != nil {
+		t.Fatalf("excludeHTML returned error: %v", err)
+	}
+
+	base, _ := url.Parse("https://example.com/")
+	markdown, err := convertToMarkdown(base, got, &Options{})
+	if err != nil {
+		t.Fatalf("convertToMarkdown returned error: %v", err)
+	}
+	for _, unwanted := range []string{"cookies", "Home", "Buy now"} {
+		if strings.Contains(markdown, unwanted) {
+			t.Fatalf("markdown %q still contains excluded text %q", markdown, unwanted)
+		}
+	}
+	if !strings.Contains(markdown, "# Title") || !strings.Contains(markdown, "Body text") {
+		t.Fatalf("markdown %q lost the main content", markdown)
+	}
+}
+
+func TestExcludeHTMLAfterSelect(t *testing.T) {
+	selected, err := selectHTML([]byte(selectorsPage), "article")
+	if err != nil {
+		t.Fatalf("selectHTML returned error: %v", err)
+	}
+	got, err := excludeHTML(selected, []string{"div.post"})
+	if err != nil {
+		t.Fatalf("excludeHTML returned error: %v", err)
+	}
+	if strings.Contains(string(got), "Nested") || !strings.Contains(string(got), "Second") {
+		t.Fatalf("excludeHTML result = %s", got)
+	}
+}
+
+func TestConvertHTMLTrimNav(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "boilerplate.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		selectors []string
+		want      string
+	}{
+		{nil, "# Getting started\n\nBy Ada\n\nInstall the tool first.\n\nDrawing on a canvas.\n\n- Download\n- Run\n\nLast updated in June."},
+		{[]string{"aside", "footer"}, "[Example](/)\n\n[Docs](/docs) [Blog](/blog)\n\nRelated pages\n\n# Getting started\n\nBy Ada\n\n" +
+			"Install the tool first.\n\nDrawing on a canvas.\n\n- Download\n- Run\n\nLast updated in June.\n\n[Next](/next)\n\n- [Privacy](/privacy)"},
+	}
+	for _, tt := range tests {
+		res, err := ConvertHTML(context.Background(), page, nil, Options{TrimNav: true, NavSelectors: tt.selectors})
+		if err != nil {
+			t.Fatalf("ConvertHTML returned error: %v", err)
+		}
+		if res.Markdown != tt.want {
+			t.Fatalf("NavSelectors %q: Markdown = %q, expected %q", tt.selectors, res.Markdown, tt.want)
+		}
+	}
+
+	if _, err := ConvertHTML(context.Background(), page, nil, Options{TrimNav: true, NavSelectors: []string{"div["}}); Kind(err) != KindInvalidInput {
+		t.Fatalf("ConvertHTML error = %v, expected KindInvalidInput for an invalid selector", err)
+	}
+}
@@ -0,0 +1,141 @@
+package url2md
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxSitemapDepth bounds how deeply sitemap indexes may nest.
+const maxSitemapDepth = 5
+
+// sitemapDoc covers both <urlset> sitemaps and <sitemapindex> files.
+type sitemapDoc struct {
+	URLs     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+// SitemapURLs fetches the sitemap at rawURL and returns the page URLs it
+// lists, following sitemap indexes and decompressing gzipped sitemaps.
+// Duplicates are dropped and at most limit URLs are returned; zero means no
+// limit. Nested sitemaps that fail to load are logged and skipped.
+func SitemapURLs(ctx context.Context, rawURL string, limit int, opts Options) ([]string, error) {
+	target, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, classify(KindInvalidInput, fmt.Errorf("invalid url: %w", err))
+	}
+	if opts.HTTPProxy != "" {
+		if err := ValidateHTTPProxy(opts.HTTPProxy); err != nil {
+			return nil, err
+		}
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.MaxRedirects == 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	client := newClient(&opts)
+
+	var urls []string
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+	full := func() bool { return limit > 0 && len(urls) >= limit }
+
+	var walk func(sitemap *url.URL, depth int) error
+	walk = func(sitemap *url.URL, depth int) error {
+		visited[sitemap.String()] = true
+		opts.info("Reading sitemap", "url", sitemap.String())
+		doc, err := fetchSitemap(ctx, client, sitemap, &opts)
+		if err != nil {
+			return fmt.Errorf("failed to read sitemap %s: %w", sitemap, err)
+		}
+		for _, loc := range doc.URLs {
+			if full() {
+				return nil
+			}
+			page := resolveURL(sitemap, strings.TrimSpace(loc))
+			if page != "" && !seen[page] {
+				seen[page] = true
+				urls = append(urls, page)
+			}
+		}
+		for _, loc := range doc.Sitemaps {
+			if full() {
+				return nil
+			}
+			child, err := sitemap.Parse(strings.TrimSpace(loc))
+			if err != nil || visited[child.String()] {
+				continue
+			}
+			if depth >= maxSitemapDepth {
+				opts.warn("Skipping sitemap: nested too deeply", "url", child.String())
+				continue
+			}
+			if err := walk(child, depth+1); err != nil {
+				opts.warn("Skipping sitemap", "error", err)
+			}
+		}
+		return nil
+	}
+	if err := walk(target, 0); err != nil {
+		return nil, err
+	}
+	if full() {
+		opts.info("Stopped at the URL limit", "limit", limit)
+	}
+	return urls, nil
+}
+
+func fetchSitemap(ctx context.Context, client *http.Client, target *url.URL, opts *Options) (*sitemapDoc, error) {
+	resp, err := doWithRetry(ctx, client, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		applyBrowserHeaders(req, target, opts, false)
+		req.Header.Set("Accept", "application/xml,text/xml;q=0.9,*/*;q=0.8")
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		for name, values := range opts.Header {
+			req.Header[name] = values
+		}
+		return req, nil
+	}, opts)
+	if err != nil {
+		return nil, classify(KindNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, classify(KindHTTPStatus, fmt.Errorf("HTTP status %s", resp.Status))
+	}
+	data, err := readLimited(resp.Body, opts.MaxSize)
+	if err != nil {
+		return nil, classifyRead(err)
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		if data, err = decodeContentEncoding(data, encoding, opts.MaxSize); err != nil {
+			return nil, classifyRead(err)
+		}
+	}
+	// sitemap.xml.gz files are usually served as plain gzip archives rather
+	// than with a Content-Encoding header.
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		if data, err = decodeContentEncoding(data, "gzip", opts.MaxSize); err != nil {
+			return nil, classifyRead(err)
+		}
+	}
+
+	var doc sitemapDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, classify(KindConversion, fmt.Errorf("invalid sitemap: %w", err))
+	}
+	return &doc, nil
+}
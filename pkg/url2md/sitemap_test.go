@@ -0,0 +1,58 @@
+package url2md
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSitemapURLsFollowsIndexes(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>`+srv.URL+`/docs.xml</loc></sitemap>
+  <sitemap><loc>`+srv.URL+`/blog.xml.gz</loc></sitemap>
+  <sitemap><loc>`+srv.URL+`/missing.xml</loc></sitemap>
+  <sitemap><loc>`+srv.URL+`/sitemap.xml</loc></sitemap>
+</sitemapindex>`)
+		case "/docs.xml":
+			io.WriteString(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> `+srv.URL+`/docs/a </loc></url>
+  <url><loc>`+srv.URL+`/docs/b</loc></url>
+</urlset>`)
+		case "/blog.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(compress(t, "gzip", []byte(`<urlset><url><loc>`+srv.URL+`/blog/c</loc></url><url><loc>`+srv.URL+`/docs/a</loc></url></urlset>`)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	got, err := SitemapURLs(context.Background(), srv.URL+"/sitemap.xml", 0, Options{Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("SitemapURLs returned error: %v", err)
+	}
+	want := []string{srv.URL + "/docs/a", srv.URL + "/docs/b", srv.URL + "/blog/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SitemapURLs = %q, expected %q", got, want)
+	}
+
+	got, err = SitemapURLs(context.Background(), srv.URL+"/sitemap.xml", 2, Options{Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("SitemapURLs returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want[:2]) {
+		t.Fatalf("SitemapURLs with limit 2 = %q, expected %q", got, want[:2])
+	}
+
+	if _, err := SitemapURLs(context.Background(), srv.URL+"/missing.xml", 0, Options{}); err == nil {
+		t.Fatalf("SitemapURLs on a missing sitemap returned no error")
+	}
+}
@@ -0,0 +1,90 @@
+package url2md
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Section is a part of a page converted on its own, as selected by
+// Options.Split.
+type Section struct {
+	// ID names the section: its id attribute, or else the anchor of its
+	// first heading, or else its position starting from 1. IDs are unique
+	// within a Result.
+	ID string
+	// Title is the text of the first heading of the section, if any.
+	Title string
+	// Markdown is the converted section.
+	Markdown string
+}
+
+// htmlSection is an element of a page matched by Options.Split.
+type htmlSection struct {
+	id    string
+	title string
+	html  []byte
+}
+
+// splitHTML returns the elements of page matching selector in document order,
+// each with the ID and title of its Section. Matches nested inside another
+// match are only included as part of their ancestor.
+func splitHTML(page []byte, selector string) ([]htmlSection, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []htmlSection
+	var renderErr error
+	seen := map[string]int{}
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+		if renderErr != nil || s.ParentsFiltered(selector).Length() > 0 {
+			return
+		}
+		html, err := goquery.OuterHtml(s)
+		if err != nil {
+			renderErr = err
+			return
+		}
+		title := strings.Join(strings.Fields(s.Find("h1, h2, h3, h4, h5, h6").First().Text()), " ")
+		id := strings.TrimSpace(s.AttrOr("id", ""))
+		if id == "" {
+			id = slugify(title)
+		}
+		if id == "" {
+			id = strconv.Itoa(len(sections) + 1)
+		}
+		sections = append(sections, htmlSection{id: uniqueName(id, seen), title: title, html: []byte(html)})
+	})
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	if len(sections) == 0 {
+		return nil, classify(KindConversion, fmt.Errorf("%q: %w", selector, ErrNoMatch))
+	}
+	return sections, nil
+}
+
+// convertSections converts every element of page matching opts.Split into a
+// Section of its own.
+func convertSections(base *url.URL, page []byte, opts *Options) ([]Section, error) {
+	parts, err := splitHTML(page, opts.Split)
+	if err != nil {
+		return nil, err
+	}
+	opts.debug("Splitting page into sections", "count", len(parts))
+	sections := make([]Section, 0, len(parts))
+	for _, part := range parts {
+		markdown, err := convertToMarkdown(base, part.html, opts)
+		if err != nil {
+			return nil, classify(KindConversion, fmt.Errorf("failed to convert section %q: %w", part.id, err))
+		}
+		sections = append(sections, Section{ID: part.id, Title: part.title, Markdown: finishMarkdown(markdown, opts)})
+	}
+	return sections, nil
+}
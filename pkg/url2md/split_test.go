@@ -0,0 +1,52 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const sectionsPage = `<html><head><title>Reference</title></head><body>
+<nav>Menu</nav>
+<section id="install"><h2>Installation</h2><p>Run <a href="/get">the installer</a>.</p></section>
+<section><h2>Getting Started</h2><p>Open the app.</p>
+  <section id="nested"><h3>Nested</h3><p>Inner part.</p></section>
+</section>
+<section><p>No heading here.</p></section>
+<section id="install"><h2>Installation again</h2><p>Duplicate id.</p></section>
+</body></html>`
+
+func TestConvertHTMLSplit(t *testing.T) {
+	source, _ := url.Parse("https://example.com/docs/")
+	res, err := ConvertHTML(context.Background(), []byte(sectionsPage), source, Options{Split: "section"})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "Menu") || !strings.Contains(res.Markdown, "Duplicate id.") {
+		t.Fatalf("Markdown = %q, expected the whole page", res.Markdown)
+	}
+
+	want := []Section{
+		{ID: "install", Title: "Installation", Markdown: "## Installation\n\nRun [the installer](https://example.com/get)."},
+		{ID: "getting-started", Title: "Getting Started", Markdown: "## Getting Started\n\nOpen the app.\n\n### Nested\n\nInner part."},
+		{ID: "3", Markdown: "No heading here."},
+		{ID: "install-1", Title: "Installation again", Markdown: "## Installation again\n\nDuplicate id."},
+	}
+	if len(res.Sections) != len(want) {
+		t.Fatalf("got %d sections, expected %d: %+v", len(res.Sections), len(want), res.Sections)
+	}
+	for i, s := range res.Sections {
+		if s != want[i] {
+			t.Fatalf("Sections[%d] = %+v, expected %+v", i, s, want[i])
+		}
+	}
+}
+
+func TestConvertHTMLSplitNoMatch(t *testing.T) {
+	_, err := ConvertHTML(context.Background(), []byte(sectionsPage), nil, Options{Split: "article"})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("ConvertHTML error = %v, expected ErrNoMatch", err)
+	}
+}
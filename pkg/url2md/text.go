@@ -0,0 +1,105 @@
+package url2md
+
+import (
+	"regexp"
+	"strings"
+)
+
+// escapeBase is the start of the private-use range escaped ASCII characters
+// are shifted into while stripInline removes markup.
+const escapeBase = 0xE000
+
+var (
+	inlineLinkRe     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	inlineRefLinkRe  = regexp.MustCompile(`!?\[([^\]]*)\]\[[^\]]*\]`)
+	inlineHTMLRe     = regexp.MustCompile(`<[^>]+>`)
+	inlineEscapeRe   = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+	inlineEmphasisRe = regexp.MustCompile(`(^|\W)_+|_+(\W|$)`)
+	textRuleRe       = regexp.MustCompile(`^ {0,3}([-*_])( *[-*_]){2,} *$`)
+	tableDelimRe     = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	blockMarkerRe    = regexp.MustCompile(`^(\s*>)*\s*([-*+]\s+)?`)
+)
+
+// PlainText strips the Markdown syntax from markdown, leaving its text for
+// uses such as search indexing: links become their text, images their alt
+// text, and heading, list, quote, emphasis and code markers are dropped.
+// Paragraph breaks and the content of code blocks are kept.
+func PlainText(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	var out []string
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimLeft(line, " "), fence) {
+				fence = ""
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		switch {
+		case linkRefRe.MatchString(line), textRuleRe.MatchString(line), tableDelimRe.MatchString(line) && strings.Contains(line, "-"):
+			continue
+		case i > 0 && setextRe.MatchString(line) && strings.TrimSpace(lines[i-1]) != "":
+			continue
+		}
+		if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			out = append(out, stripInline(m[2]))
+			continue
+		}
+		line = line[len(blockMarkerRe.FindString(line)):]
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+			for j, cell := range cells {
+				cells[j] = stripInline(cell)
+			}
+			out = append(out, strings.Join(cells, "\t"))
+			continue
+		}
+		out = append(out, stripInline(line))
+	}
+	return collapseBlankLines(strings.Join(out, "\n"))
+}
+
+// stripInline strips inline Markdown from s: links and images keep their
+// text, and code, emphasis, HTML tags and escapes are removed. Escaped
+// characters are set aside first so they survive as literal text.
+func stripInline(s string) string {
+	s = inlineEscapeRe.ReplaceAllStringFunc(s, func(esc string) string {
+		return string(rune(escapeBase + rune(esc[1])))
+	})
+	s = inlineLinkRe.ReplaceAllString(s, "$1")
+	s = inlineRefLinkRe.ReplaceAllString(s, "$1")
+	s = inlineHTMLRe.ReplaceAllString(s, "")
+	s = strings.NewReplacer("`", "", "*", "", "~~", "").Replace(s)
+	s = inlineEmphasisRe.ReplaceAllString(s, "$1$2")
+	s = strings.Map(func(r rune) rune {
+		if r >= escapeBase && r < escapeBase+0x80 {
+			return r - escapeBase
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// collapseBlankLines trims s and reduces runs of blank lines to one.
+func collapseBlankLines(s string) string {
+	var out []string
+	blank := false
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, strings.TrimRight(line, " \t"))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,21 @@
+package url2md
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	markdown := "# The *Title*\n\nSee [the docs](https://example.com/docs) and ![a cat](cat.png).\n\n" +
+		"> quoted **bold** text\n\n- first\n- second [ref][1]\n\n1. one\n\n---\n\n" +
+		"| Name | Value |\n| --- | --- |\n| `a` | b\\_c |\n\n```go\nx := *p\n```\n\n" +
+		"Sub\n---\n\n[1]: https://example.com/ref"
+	expected := "The Title\n\nSee the docs and a cat.\n\nquoted bold text\n\nfirst\nsecond ref\n\n1. one\n\n" +
+		"Name\tValue\na\tb_c\n\nx := *p\n\nSub"
+	if got := PlainText(markdown); got != expected {
+		t.Fatalf("PlainText = %q, expected %q", got, expected)
+	}
+}
+
+func TestPlainTextEmpty(t *testing.T) {
+	if got := PlainText("\n\n"); got != "" {
+		t.Fatalf("PlainText = %q, expected empty", got)
+	}
+}
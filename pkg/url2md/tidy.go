@@ -0,0 +1,46 @@
+package url2md
+
+import "strings"
+
+// Tidy normalizes the whitespace of markdown so that it passes markdownlint:
+// runs of blank lines are collapsed into one, trailing whitespace is trimmed
+// and the document ends with exactly one newline. Two trailing spaces that
+// make a hard line break inside a paragraph are kept. The lines of fenced
+// code blocks are left untouched.
+func Tidy(markdown string) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimLeft(line, " "), fence) {
+				fence = ""
+				line = strings.TrimRight(line, " \t")
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+		}
+
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed == "" {
+			if len(out) > 0 && out[len(out)-1] != "" {
+				out = append(out, "")
+			}
+			continue
+		}
+		if fence == "" && strings.HasSuffix(line, "  ") && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			trimmed += "  "
+		}
+		out = append(out, trimmed)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
@@ -0,0 +1,48 @@
+package url2md
+
+import "testing"
+
+func TestTidyCollapsesBlankLines(t *testing.T) {
+	got := Tidy("\n\n# Title\n\n\n\nFirst.\n \n\t\nSecond.")
+	if want := "# Title\n\nFirst.\n\nSecond.\n"; got != want {
+		t.Fatalf("Tidy = %q, expected %q", got, want)
+	}
+}
+
+func TestTidyTrimsTrailingWhitespace(t *testing.T) {
+	got := Tidy("Title \t\n\n- item \n- two\t\n\nlast line  ")
+	if want := "Title\n\n- item\n- two\n\nlast line\n"; got != want {
+		t.Fatalf("Tidy = %q, expected %q", got, want)
+	}
+}
+
+func TestTidyKeepsHardLineBreaks(t *testing.T) {
+	got := Tidy("Line one  \nline two     \nline three  \n\nNext.")
+	if want := "Line one  \nline two  \nline three\n\nNext.\n"; got != want {
+		t.Fatalf("Tidy = %q, expected %q", got, want)
+	}
+}
+
+func TestTidySingleTrailingNewline(t *testing.T) {
+	cases := map[string]string{
+		"Text":         "Text\n",
+		"Text\n":       "Text\n",
+		"Text\n\n\n\n": "Text\n",
+		"Text\r\n":     "Text\n",
+		"":             "",
+		"\n\n":         "",
+	}
+	for in, want := range cases {
+		if got := Tidy(in); got != want {
+			t.Fatalf("Tidy(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestTidyLeavesCodeFences(t *testing.T) {
+	code := "```go\nfunc main() {  \n\n\n\n\tx := 1\t\n}\n```"
+	got := Tidy("Intro.\n\n\n" + code + "  \n\n\n~~~\n  \n~~~")
+	if want := "Intro.\n\n" + code + "\n\n~~~\n  \n~~~\n"; got != want {
+		t.Fatalf("Tidy = %q, expected %q", got, want)
+	}
+}
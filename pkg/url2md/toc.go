@@ -0,0 +1,130 @@
+package url2md
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var atxHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// tocHeading is a heading found in converted Markdown.
+type tocHeading struct {
+	level int
+	text  string
+}
+
+// tableOfContents returns a nested list linking to the headings of markdown,
+// using the anchors GitHub generates for them, or "" when there are no
+// headings. bullet is the list marker.
+func tableOfContents(markdown, bullet string) string {
+	headings := findHeadings(markdown)
+	if len(headings) == 0 {
+		return ""
+	}
+	if bullet == "" {
+		bullet = "-"
+	}
+	top := headings[0].level
+	for _, h := range headings {
+		top = min(top, h.level)
+	}
+
+	var b strings.Builder
+	slugs := map[string]int{}
+	depth := 0
+	for i, h := range headings {
+		// Never indent more than one level below the previous entry, or the
+		// item would become a code block.
+		level := h.level - top
+		if i == 0 {
+			level = 0
+		}
+		depth = min(level, depth+1)
+		label := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(h.text)
+		b.WriteString(strings.Repeat("  ", depth) + bullet + " [" + label + "](#" + uniqueSlug(h.text, slugs) + ")\n")
+	}
+	return b.String()
+}
+
+// findHeadings returns the ATX and setext headings of markdown, outside
+// fenced code blocks, as plain text.
+func findHeadings(markdown string) []tocHeading {
+	var headings []tocHeading
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimLeft(line, " "), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			if text := stripInline(m[2]); text != "" {
+				headings = append(headings, tocHeading{len(m[1]), text})
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "    ") || i+1 >= len(lines) {
+			continue
+		}
+		if m := setextRe.FindStringSubmatch(lines[i+1]); m != nil {
+			level := 1
+			if m[1][0] == '-' {
+				level = 2
+			}
+			if text := stripInline(line); text != "" {
+				headings = append(headings, tocHeading{level, text})
+			}
+			i++
+		}
+	}
+	return headings
+}
+
+// slugify returns the anchor GitHub generates for a heading: lower case,
+// punctuation removed and spaces replaced by hyphens.
+func slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// uniqueSlug returns the slug of text, suffixed with -1, -2, ... when seen
+// already holds it, the way GitHub disambiguates repeated headings.
+func uniqueSlug(text string, seen map[string]int) string {
+	return uniqueName(slugify(text), seen)
+}
+
+// uniqueName returns slug, suffixed with -1, -2, ... when seen already holds
+// it, and records the result in seen.
+func uniqueName(slug string, seen map[string]int) string {
+	n, dup := seen[slug]
+	seen[slug] = n + 1
+	if !dup {
+		return slug
+	}
+	for {
+		candidate := slug + "-" + strconv.Itoa(n)
+		if _, taken := seen[candidate]; !taken {
+			seen[candidate] = 1
+			return candidate
+		}
+		n++
+		seen[slug] = n + 1
+	}
+}
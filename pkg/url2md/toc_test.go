@@ -0,0 +1,50 @@
+package url2md
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Getting Started":           "getting-started",
+		"What's new in v2.0?":       "whats-new-in-v20",
+		"snake_case and kebab-case": "snake_case-and-kebab-case",
+		"  Déjà vu  ":               "--déjà-vu--",
+		"C++ & Go":                  "c--go",
+		"日本語のページ":                   "日本語のページ",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Fatalf("slugify(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	seen := map[string]int{}
+	var got []string
+	for _, text := range []string{"Usage", "Usage", "Usage 1", "Usage"} {
+		got = append(got, uniqueSlug(text, seen))
+	}
+	want := []string{"usage", "usage-1", "usage-1-1", "usage-2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("uniqueSlug sequence = %q, expected %q", got, want)
+		}
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	markdown := "# Guide\n\nIntro.\n\n## Install `url2md`\n\n```sh\n# not a heading\n```\n\n" +
+		"#### Deep\n\nSetup\n-----\n\n## [Links](https://example.com) and _more_\n\n## Setup\n"
+	want := "* [Guide](#guide)\n" +
+		"  * [Install url2md](#install-url2md)\n" +
+		"    * [Deep](#deep)\n" +
+		"  * [Setup](#setup)\n" +
+		"  * [Links and more](#links-and-more)\n" +
+		"  * [Setup](#setup-1)\n"
+	if got := tableOfContents(markdown, "*"); got != want {
+		t.Fatalf("tableOfContents = %q, expected %q", got, want)
+	}
+	if got := tableOfContents("no headings here", "-"); got != "" {
+		t.Fatalf("tableOfContents without headings = %q, expected empty", got)
+	}
+}
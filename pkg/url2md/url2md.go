@@ -0,0 +1,852 @@
+// Package url2md downloads web pages and converts them to Markdown.
+//
+//	res, err := url2md.Convert(ctx, "https://example.com/docs", url2md.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(res.Markdown)
+package url2md
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+)
+
+// DefaultMaxRedirects is used when Options.MaxRedirects is zero.
+const DefaultMaxRedirects = 10
+
+// DefaultMaxSize is used when Options.MaxSize is zero.
+const DefaultMaxSize = 20 << 20
+
+// DefaultUserAgent is sent when Options.UserAgent is empty.
+const DefaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
+// DefaultAcceptLanguage is the Accept-Language header sent when
+// Options.AcceptLanguage is empty.
+const DefaultAcceptLanguage = "en-US,en;q=0.9"
+
+// DefaultAccept is the Accept header sent when Options.Accept is empty.
+const DefaultAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// DefaultProxyURL is the reader proxy used when Options.ProxyURL is empty.
+const DefaultProxyURL = "https://r.jina.ai/"
+
+// DefaultProxyBody is the Options.ProxyBodyTemplate used when a ProxyMethod
+// other than GET is set without one.
+const DefaultProxyBody = `{"url": {{json .URL}}}`
+
+// ErrDisallowed is returned when Options.RespectRobots is set and robots.txt
+// forbids fetching the URL.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// ErrUnsupportedScheme is returned for URLs whose scheme is not http, https
+// or data.
+var ErrUnsupportedScheme = errors.New("unsupported scheme")
+
+// ErrEmptyContent is returned, together with the Result, when the Markdown
+// converted from a page is shorter than Options.MinLength, as happens with
+// pages rendered by JavaScript.
+var ErrEmptyContent = errors.New("empty conversion result")
+
+// ImageMode selects how images found in a page are handled.
+type ImageMode string
+
+const (
+	// ImagesKeep leaves image links pointing at the original location.
+	ImagesKeep ImageMode = "keep"
+	// ImagesStrip removes images before conversion.
+	ImagesStrip ImageMode = "strip"
+	// ImagesDownload saves images into an assets/ folder inside
+	// Options.OutputDir and links to the local copies.
+	ImagesDownload ImageMode = "download"
+)
+
+// LinkStyle selects how links are written.
+type LinkStyle string
+
+const (
+	// LinkInline writes links as [text](url).
+	LinkInline LinkStyle = "inline"
+	// LinkReferenced writes links as [text][n] with one [n]: url definition
+	// per distinct target at the end of the document.
+	LinkReferenced LinkStyle = "referenced"
+)
+
+// HeadingStyle selects how headings are written.
+type HeadingStyle string
+
+const (
+	// HeadingATX writes headings as "# Title".
+	HeadingATX HeadingStyle = "atx"
+	// HeadingSetext underlines level 1 and 2 headings with "=" and "-".
+	// Deeper levels still use the ATX form.
+	HeadingSetext HeadingStyle = "setext"
+)
+
+// DefinitionListStyle selects how definition lists (<dl>) are written.
+type DefinitionListStyle string
+
+const (
+	// DefinitionListBold writes each term in bold on its own line and its
+	// definitions as blockquotes below it.
+	DefinitionListBold DefinitionListStyle = "bold"
+	// DefinitionListExtra uses the PHP Markdown Extra syntax, also read by
+	// Pandoc and kramdown: the term on its own line, followed by one
+	// ": definition" line per definition.
+	DefinitionListExtra DefinitionListStyle = "extra"
+)
+
+// Options configures a conversion. The zero value fetches the page with the
+// default browser headers and converts the whole document.
+type Options struct {
+	// UserAgent overrides DefaultUserAgent.
+	UserAgent string
+	// UserAgents, when set, gives each conversion the next User-Agent of
+	// the rotation instead of UserAgent, which is still used to match the
+	// robots.txt rules. The Sec-CH-UA headers follow the User-Agent sent.
+	UserAgents *UserAgentRotation
+	// AcceptLanguage overrides DefaultAcceptLanguage on the warm-up, page,
+	// image and sitemap requests, for sites that pick the locale from it.
+	AcceptLanguage string
+	// Accept overrides DefaultAccept on the warm-up and page requests. Set
+	// it to "text/markdown" to ask servers that can render Markdown
+	// themselves for it: a text/markdown response is then used as is,
+	// without conversion.
+	Accept string
+	// Header holds extra headers for the page request. They override the
+	// default browser headers and are never sent to the proxy.
+	Header http.Header
+	// Cookies are sent with the warm-up, page and image requests to the
+	// hosts their Domain matches, as a logged-in browser would. Load them
+	// with ParseCookieFile. They are never sent to the proxy, and they are
+	// ignored when CookieJar is set: add them to it with CookieJar.Add.
+	Cookies []*http.Cookie
+	// CookieJar, when set, replaces the cookie jar each call otherwise
+	// starts empty, so that the cookies a site sets for one URL, such as
+	// after the warm-up request, are sent for the next ones too; the warm-up
+	// is skipped when the jar already has cookies for the page. Share it
+	// between the Convert calls of a batch and save it with CookieJar.Save.
+	CookieJar *CookieJar
+	// BasicAuth, when set, is sent as HTTP Basic credentials on the warm-up
+	// and page requests. It is never sent to the proxy.
+	BasicAuth *url.Userinfo
+	// MaxRedirects caps the number of redirects followed. Zero means
+	// DefaultMaxRedirects; a negative value disables following redirects.
+	MaxRedirects int
+	// MaxSize caps the size in bytes of every response body, after
+	// decompression. Zero means DefaultMaxSize; a negative value disables the
+	// limit.
+	MaxSize int64
+	// FollowMetaRefresh follows <meta http-equiv="refresh"> redirects with a
+	// short delay. They count against MaxRedirects together with the HTTP
+	// redirects met on the way.
+	FollowMetaRefresh bool
+	// PreferCanonicalFromAMP converts the canonical page an AMP document
+	// links to with <link rel="canonical"> instead of the stripped-down AMP
+	// version. The AMP page is converted when the canonical one cannot be
+	// fetched.
+	PreferCanonicalFromAMP bool
+	// ConnectTimeout caps the time spent resolving the host and opening each
+	// connection, independently of the deadline of the context passed to
+	// Convert. Zero keeps the 30 second limit of http.DefaultTransport.
+	ConnectTimeout time.Duration
+	// Retries is how many times the page request is retried on connection
+	// errors and 429/503 responses.
+	Retries int
+	// NoWarmup skips the request to the host root that normally precedes the
+	// page request to collect cookies. Sites behind Cloudflare may then
+	// answer with a challenge.
+	NoWarmup bool
+	// HeadCheck sends a HEAD request before anything else and stops with
+	// the error the page request would give when the URL answers with a
+	// missing page or a content type that is not converted, without
+	// downloading it. Servers that reject HEAD get the page request as
+	// usual.
+	HeadCheck bool
+	// NoProxy disables the proxy fallback for blocked requests.
+	NoProxy bool
+	// ProxyOnEmpty fetches the page again through the reader proxy when its
+	// HTML converts to less than MinLength characters, as the empty shell of
+	// a JavaScript application does, and returns the proxy's Markdown
+	// instead. It has no effect with NoProxy or a zero MinLength.
+	ProxyOnEmpty bool
+	// ProxyURL overrides DefaultProxyURL with another reader-compatible
+	// endpoint; the page URL is appended to it.
+	ProxyURL string
+	// ProxyAuth sends JINA_API_KEY to a custom ProxyURL. The key is always
+	// sent to the default endpoint.
+	ProxyAuth bool
+	// ProxyMethod is the method of the proxy request, GET when empty. With
+	// another method, such as POST, the page URL is sent in the request
+	// body instead of being appended to ProxyURL.
+	ProxyMethod string
+	// ProxyBodyTemplate is the text/template the body of a ProxyMethod
+	// request is rendered from, with the page URL as {{.URL}} and the json
+	// and query functions to escape it, e.g. {"url": {{json .URL}}}. A body
+	// starting with '{' is sent as application/json, others as
+	// application/x-www-form-urlencoded. It defaults to DefaultProxyBody.
+	ProxyBodyTemplate string
+	// HTTPProxy is a forward proxy (http://, https:// or socks5://) every
+	// request is sent through, including those to ProxyURL. When empty,
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment are honored.
+	HTTPProxy string
+	// RootCAs, when set, replaces the system roots used to verify the
+	// certificates of the fetched site. It is not used for ProxyURL.
+	RootCAs *x509.CertPool
+	// InsecureSkipVerify disables certificate verification for the fetched
+	// site, leaving the connection open to interception. It is not used for
+	// ProxyURL.
+	InsecureSkipVerify bool
+	// Transport, when set, carries the requests for the page and its images
+	// in place of a transport built from HTTPProxy, RootCAs and
+	// InsecureSkipVerify. Create it with NewTransport and share it between
+	// the Convert calls of a batch so that connections are reused; every
+	// call still keeps its own cookies and the deadline of its context.
+	Transport http.RoundTripper
+	// ProxyTransport, when set, carries the requests to ProxyURL in place
+	// of a transport built for each of them. Create it with
+	// NewProxyTransport and share it between the Convert calls of a batch.
+	ProxyTransport http.RoundTripper
+	// Cache, when set, makes the page request conditional on the validators
+	// stored by a previous run; Convert fails with ErrNotModified when the
+	// page has not changed. Convert does not update it: the caller records
+	// Result.Validators with Cache.Store once the result is saved, and
+	// writes the cache back with Cache.Save.
+	Cache *Cache
+	// ModifiedSince, when set, makes the page request conditional with
+	// If-Modified-Since; Convert fails with ErrNotModified when the server
+	// answers 304 or reports a Last-Modified time that is not after it.
+	// Pages without Last-Modified are always converted.
+	ModifiedSince time.Time
+	// RateLimiter, when set, throttles the warm-up and page requests per
+	// host. Share one limiter between the Convert calls of a batch.
+	RateLimiter *RateLimiter
+	// RespectRobots makes Convert fail with ErrDisallowed for URLs that the
+	// host's robots.txt forbids.
+	RespectRobots bool
+	// Robots, when set, keeps the robots.txt files fetched for
+	// RespectRobots. Share one between the Convert calls of a run so that
+	// each host's file is fetched only once; when nil, every Convert call
+	// fetches it again.
+	Robots *RobotsCache
+
+	// BaseURL, when set, replaces the page URL as the base for resolving
+	// relative links and images. Result.FinalURL is not affected.
+	BaseURL *url.URL
+	// Force converts responses whose Content-Type is neither HTML nor text
+	// as if they were HTML instead of failing.
+	Force bool
+	// UseCanonical makes a <link rel="canonical"> on the same host replace the
+	// fetched URL as Result.FinalURL and as the base for resolving links.
+	// Canonical URLs on other hosts are ignored.
+	UseCanonical bool
+
+	// Select is a CSS selector; when set, only the matching elements are
+	// converted and Convert fails with ErrNoMatch if there are none.
+	Select string
+	// Exclude lists CSS selectors of elements removed before conversion,
+	// after Select has been applied.
+	Exclude []string
+	// TrimNav removes common boilerplate before conversion, after Select
+	// and Exclude: the elements matching NavSelectors, except those that
+	// hold the main content. It is lighter than Readability and keeps the
+	// structure of the main column.
+	TrimNav bool
+	// NavSelectors lists the CSS selectors TrimNav removes. Empty means
+	// DefaultNavSelectors.
+	NavSelectors []string
+	// PickContent, when set and Select is empty, is called with the
+	// container elements of the page holding the most text, biggest first,
+	// and returns the index of the one to convert, or -1 for the whole page.
+	PickContent func(candidates []Candidate) (int, error)
+	// Split is a CSS selector; when set, every matching element is also
+	// converted on its own into Result.Sections, after Select, Exclude and
+	// Readability have been applied. Convert fails with ErrNoMatch if there
+	// are none.
+	Split string
+	// Readability converts only the main article content when one can be
+	// found, falling back to the whole document otherwise.
+	Readability bool
+	// Images selects how images are handled. The empty value keeps them.
+	Images ImageMode
+	// ImagesAll makes ImagesDownload also save SVG images and data URIs.
+	ImagesAll bool
+	// Tables converts <table> elements to GitHub-flavored pipe tables instead
+	// of plain text rows.
+	Tables bool
+	// HeadingStyle selects the heading syntax. The empty value is HeadingATX.
+	HeadingStyle HeadingStyle
+	// BulletChar is the marker of unordered list items: "-" (the default),
+	// "*" or "+".
+	BulletChar string
+	// LinkStyle selects the link syntax. The empty value is LinkInline.
+	LinkStyle LinkStyle
+	// DefinitionListStyle selects the definition list syntax. The empty
+	// value is DefinitionListBold.
+	DefinitionListStyle DefinitionListStyle
+	// DetailsHeadings writes each collapsible <details> section as a heading,
+	// made from its <summary>, followed by its content, instead of keeping
+	// it as raw HTML for renderers that do not allow HTML.
+	DetailsHeadings bool
+	// AbsoluteLinks rewrites the href, src and srcset attributes of the page
+	// to absolute URLs before conversion, so that they are absolute even in
+	// rules or output that do not resolve them. It needs a base URL.
+	AbsoluteLinks bool
+	// CleanLinks removes tracking query parameters, such as utm_source or
+	// fbclid, from the links and images in the converted Markdown.
+	CleanLinks bool
+	// TrackingParams lists the parameters CleanLinks removes; a trailing "*"
+	// matches any suffix. Empty means DefaultTrackingParams.
+	TrackingParams []string
+	// CodeFence writes every <pre> block as a fenced code block whose info
+	// string is the language named by a language-X or lang-X class, or
+	// CodeLanguage when there is none.
+	CodeFence bool
+	// CodeLanguage is the default info string of fenced code blocks. It is
+	// only used with CodeFence.
+	CodeLanguage string
+	// Wrap hard-wraps paragraph text at this many columns. Zero disables
+	// wrapping.
+	Wrap int
+	// Figures keeps <figcaption> captions: in italics below the image, or
+	// as a blockquote for figures without one.
+	Figures bool
+	// EmojiShortcodes writes common Unicode emoji in the page text as GitHub
+	// shortcodes such as :tada:, for Markdown rendered on GitHub. Emoji in
+	// code are left alone. By default emoji are kept as they are.
+	EmojiShortcodes bool
+	// EmojiImages replaces emoji drawn as images, like GitHub's
+	// <img class="emoji" alt="😄">, with the emoji of their alt text, before
+	// Images and EmojiShortcodes apply. By default they are kept as images.
+	EmojiImages bool
+	// KeepComments leaves HTML comments, including IE conditional comments,
+	// in the document handed to the converter and to Rules. By default they
+	// are removed first.
+	KeepComments bool
+	// KeepScripts leaves <script>, <style>, <noscript> and <template>
+	// elements in the document handed to the converter and to Rules. By
+	// default they are removed first.
+	KeepScripts bool
+	// KeepHidden leaves the elements hidden from readers, marked
+	// aria-hidden="true", with the hidden attribute or with an inline
+	// display:none, in the document handed to the converter and to Rules.
+	// By default they are removed first, as they mostly hold duplicate text
+	// or decoration.
+	KeepHidden bool
+	// KeepEmptyLinks leaves links without text, such as the [](url) of
+	// icon-only navigation links, in the Markdown. By default they are
+	// removed; images, even without alt text, are always kept.
+	KeepEmptyLinks bool
+	// Rules are extra html-to-markdown conversion rules, registered after the
+	// built-in ones so that they take precedence for the same tags.
+	Rules []md.Rule
+	// TOC prepends a nested list linking to every heading of the Markdown,
+	// using GitHub-style anchors.
+	TOC bool
+	// MinLength makes Convert return ErrEmptyContent along with the result
+	// when the converted Markdown has fewer characters than this, ignoring
+	// surrounding whitespace. Zero disables the check.
+	MinLength int
+	// OutputDir is the directory the markdown will be written to. Downloaded
+	// images are saved in its assets/ subfolder.
+	OutputDir string
+
+	// Logger receives leveled progress messages: steps of the conversion at
+	// debug level, fetches at info level, and problems that do not fail the
+	// conversion, such as an image that could not be downloaded, at warn
+	// level. It takes precedence over Logf.
+	Logger *slog.Logger
+	// Logf receives the same messages as Logger, each formatted as one
+	// "message key=value ..." line, when Logger is nil. Nil disables logging.
+	Logf func(format string, args ...interface{})
+}
+
+// Result is a converted page.
+type Result struct {
+	// Markdown is the converted document.
+	Markdown string
+	// FinalURL is the URL the page was served from after redirects, or its
+	// canonical URL when Options.UseCanonical applies. It is nil for
+	// documents passed to ConvertHTML without a source URL.
+	FinalURL *url.URL
+	// CanonicalURL is the canonical URL declared by the page, if any.
+	CanonicalURL *url.URL
+	// Title is the page title, or empty when the page has none.
+	Title string
+	// Description is the page's meta description, if any.
+	Description string
+	// Metadata holds the Open Graph and article tags of the page. It is
+	// empty for Markdown responses.
+	Metadata Metadata
+	// FetchedAt is when the page was downloaded.
+	FetchedAt time.Time
+	// ViaProxy reports whether the content came from the proxy fallback.
+	ViaProxy bool
+	// Header holds the headers of the response the page was read from. It
+	// is nil for the proxy fallback and for documents passed to ConvertHTML.
+	Header http.Header
+	// HTML is the page Convert downloaded, decoded to UTF-8 with its
+	// charset declaration saying so, as it was before the conversion. It
+	// is nil for Markdown and plain-text responses, such as those of the
+	// proxy fallback, and for documents passed to ConvertHTML.
+	HTML []byte
+	// Validators holds the ETag and Last-Modified headers of the response,
+	// to record in Options.Cache with Cache.Store once the result is saved.
+	// They are empty for the proxy fallback and for ConvertHTML.
+	Validators Validators
+	// Links lists the absolute http(s) URLs the page links to, without
+	// fragments. It is empty for Markdown responses.
+	Links []string
+	// Sections holds the parts of the page matched by Options.Split, in
+	// document order. It is empty for Markdown responses.
+	Sections []Section
+}
+
+// log sends msg and its key-value pairs to o.Logger or, when that is nil, to
+// o.Logf as a single "msg key=value ..." line.
+func (o *Options) log(level slog.Level, msg string, args ...interface{}) {
+	if o.Logger != nil {
+		o.Logger.Log(context.Background(), level, msg, args...)
+		return
+	}
+	if o.Logf == nil {
+		return
+	}
+	rec := slog.NewRecord(time.Time{}, level, msg, 0)
+	rec.Add(args...)
+	var line strings.Builder
+	line.WriteString(msg)
+	rec.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&line, " %s=%v", a.Key, a.Value)
+		return true
+	})
+	o.Logf("%s", line.String())
+}
+
+func (o *Options) debug(msg string, args ...interface{}) { o.log(slog.LevelDebug, msg, args...) }
+
+func (o *Options) info(msg string, args ...interface{}) { o.log(slog.LevelInfo, msg, args...) }
+
+func (o *Options) warn(msg string, args ...interface{}) { o.log(slog.LevelWarn, msg, args...) }
+
+// Convert downloads rawURL and converts it to Markdown. A missing scheme
+// defaults to https. The payload of a data: URL is decoded and converted
+// without any request; relative links in it are only resolved against
+// Options.BaseURL.
+func Convert(ctx context.Context, rawURL string, opts Options) (Result, error) {
+	target, err := ParseURL(rawURL)
+	if err != nil {
+		return Result{}, classify(KindInvalidInput, fmt.Errorf("invalid url: %w", err))
+	}
+	if err := prepareOptions(&opts); err != nil {
+		return Result{}, err
+	}
+
+	client := newClient(&opts)
+
+	if opts.RespectRobots && opts.Robots == nil {
+		// Still fetch robots.txt once for the redirects of this call.
+		opts.Robots = NewRobotsCache()
+	}
+	if opts.RespectRobots && !robotsAllowed(ctx, client, target, &opts) {
+		return Result{}, fmt.Errorf("skipping %s: %w", target, ErrDisallowed)
+	}
+
+	opts.info("Fetching", "url", target.String())
+	page, err := fetchHTML(ctx, client, target, &opts)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to download %s: %w", target, err)
+	}
+	// Meta refreshes and the HTTP redirects of every request share the
+	// MaxRedirects budget; a negative MaxRedirects allows none of either.
+	budget, redirects := max(opts.MaxRedirects, 0), page.redirects
+	for opts.FollowMetaRefresh && page.isHTML {
+		next := metaRefreshTarget(page.body, page.url)
+		if next == nil {
+			break
+		}
+		if redirects >= budget {
+			return Result{}, classify(KindNetwork, fmt.Errorf("failed to download %s: stopped after %d redirects", target, budget))
+		}
+		if opts.RespectRobots && !robotsAllowed(ctx, client, next, &opts) {
+			return Result{}, fmt.Errorf("skipping %s: %w", next, ErrDisallowed)
+		}
+		opts.info("Following meta refresh", "url", next.String())
+		page, err = fetchHTML(ctx, client, next, &opts)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to download %s: %w", next, err)
+		}
+		if redirects += 1 + page.redirects; redirects > budget {
+			return Result{}, classify(KindNetwork, fmt.Errorf("failed to download %s: stopped after %d redirects", target, budget))
+		}
+	}
+	if opts.PreferCanonicalFromAMP && page.isHTML {
+		page = preferCanonical(ctx, client, page, &opts)
+	}
+	if page.url.String() != target.String() {
+		opts.debug("Resolved", "url", page.url.String())
+	}
+
+	res := Result{FinalURL: page.url, FetchedAt: time.Now(), ViaProxy: page.viaProxy, Header: page.header, Validators: page.validators}
+	if page.isHTML {
+		res.HTML = page.body
+		if !page.undecoded {
+			res.HTML = declareUTF8(page.body)
+		}
+	}
+	if !page.isHTML {
+		opts.debug("Using preformatted Markdown response")
+		res.Markdown = finishMarkdown(string(page.body), &opts)
+		return res, nil
+	}
+	res, err = convertPage(ctx, client, page.body, res, &opts)
+	if errors.Is(err, ErrEmptyContent) && opts.ProxyOnEmpty && !opts.NoProxy && page.url.Scheme != "data" {
+		return retryViaProxy(ctx, page.url, res, err, &opts)
+	}
+	return res, err
+}
+
+// retryViaProxy fetches target through the reader proxy for
+// Options.ProxyOnEmpty, after its HTML converted to res and the
+// ErrEmptyContent error convErr. The proxy's Markdown replaces that of res;
+// when the proxy fails, res and convErr are returned as they were.
+func retryViaProxy(ctx context.Context, target *url.URL, res Result, convErr error, opts *Options) (Result, error) {
+	opts.info("Conversion too short, retrying via proxy", "url", target.String(), "min_length", opts.MinLength)
+	proxyStart := time.Now()
+	body, err := fetchViaProxy(ctx, target, opts)
+	opts.debug("Proxy retry done", "duration", since(proxyStart))
+	if err != nil {
+		opts.warn("Proxy retry failed", "error", err)
+		return res, convErr
+	}
+	res.Markdown = finishMarkdown(string(body), opts)
+	res.ViaProxy, res.Header, res.HTML, res.Sections = true, nil, nil, nil
+	return res, checkLength(res.Markdown, opts)
+}
+
+// preferCanonical returns the canonical version of page when page is an AMP
+// document that declares one and it can be fetched, and page otherwise.
+func preferCanonical(ctx context.Context, client *http.Client, page fetchResult, opts *Options) fetchResult {
+	canonical, amp := ampCanonical(page.body, page.url)
+	switch {
+	case !amp:
+		return page
+	case canonical == nil:
+		opts.info("AMP page without a canonical URL, converting it as is")
+		return page
+	case opts.RespectRobots && !robotsAllowed(ctx, client, canonical, opts):
+		opts.info("Canonical URL of the AMP page is disallowed by robots.txt, converting the AMP page", "url", canonical.String())
+		return page
+	}
+	opts.info("AMP page, converting its canonical version", "url", canonical.String())
+	full, err := fetchHTML(ctx, client, canonical, opts)
+	if err != nil {
+		opts.warn("Failed to fetch the canonical page, converting the AMP page", "url", canonical.String(), "error", err)
+		return page
+	}
+	return full
+}
+
+// ConvertHTML converts an HTML document that is already in memory, such as
+// a saved page. source is the URL the document came from and is used to
+// resolve relative links and images; when nil they are left as they are.
+// Nothing is fetched except images with ImagesDownload.
+func ConvertHTML(ctx context.Context, page []byte, source *url.URL, opts Options) (Result, error) {
+	if err := prepareOptions(&opts); err != nil {
+		return Result{}, err
+	}
+	if decoded, err := decodeCharset(page, ""); err != nil {
+		opts.warn("Keeping original bytes", "error", err)
+	} else {
+		page = decoded
+	}
+	res := Result{FinalURL: source, FetchedAt: time.Now()}
+	return convertPage(ctx, newClient(&opts), page, res, &opts)
+}
+
+// prepareOptions validates opts and fills in the defaults for unset fields.
+func prepareOptions(opts *Options) error {
+	if opts.ProxyURL != "" {
+		if err := ValidateProxyURL(opts.ProxyURL); err != nil {
+			return err
+		}
+	}
+	if opts.HTTPProxy != "" {
+		if err := ValidateHTTPProxy(opts.HTTPProxy); err != nil {
+			return err
+		}
+	}
+	if err := ValidateProxyRequest(opts.ProxyMethod, opts.ProxyBodyTemplate); err != nil {
+		return err
+	}
+	if opts.AcceptLanguage != "" {
+		if err := ValidateAcceptLanguage(opts.AcceptLanguage); err != nil {
+			return err
+		}
+	}
+	if opts.Accept != "" {
+		if err := ValidateAccept(opts.Accept); err != nil {
+			return err
+		}
+	}
+	if opts.ConnectTimeout < 0 {
+		return classify(KindInvalidInput, fmt.Errorf("invalid connect timeout %s: must not be negative", opts.ConnectTimeout))
+	}
+	switch opts.HeadingStyle {
+	case "", HeadingATX, HeadingSetext:
+	default:
+		return classify(KindInvalidInput, fmt.Errorf("invalid heading style %q: must be atx or setext", opts.HeadingStyle))
+	}
+	switch opts.LinkStyle {
+	case "", LinkInline, LinkReferenced:
+	default:
+		return classify(KindInvalidInput, fmt.Errorf("invalid link style %q: must be inline or referenced", opts.LinkStyle))
+	}
+	switch opts.DefinitionListStyle {
+	case "", DefinitionListBold, DefinitionListExtra:
+	default:
+		return classify(KindInvalidInput, fmt.Errorf("invalid definition list style %q: must be bold or extra", opts.DefinitionListStyle))
+	}
+	switch opts.BulletChar {
+	case "", "-", "*", "+":
+	default:
+		return classify(KindInvalidInput, fmt.Errorf("invalid bullet character %q: must be -, * or +", opts.BulletChar))
+	}
+	for _, selector := range append(append([]string{opts.Select, opts.Split}, opts.Exclude...), opts.NavSelectors...) {
+		if selector == "" {
+			continue
+		}
+		if err := validateSelector(selector); err != nil {
+			return err
+		}
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	opts.UserAgents = opts.UserAgents.pin()
+	if opts.MaxRedirects == 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.Images == "" {
+		opts.Images = ImagesKeep
+	}
+	return nil
+}
+
+// convertPage runs the HTML of a page through metadata extraction, the
+// selectors, readability and image handling before converting it to
+// Markdown. Relative URLs are resolved against opts.BaseURL, or else
+// res.FinalURL, which may be nil.
+func convertPage(ctx context.Context, client *http.Client, body []byte, res Result, opts *Options) (Result, error) {
+	start := time.Now()
+	meta := extractMetadata(body)
+	res.Title, res.Description, res.Metadata = meta.title, meta.description, meta.openGraph
+	if meta.canonical != "" && res.FinalURL != nil {
+		if canonical, err := res.FinalURL.Parse(meta.canonical); err == nil {
+			res.CanonicalURL = canonical
+			res.FinalURL = useCanonical(res.FinalURL, canonical, opts)
+		}
+	}
+	base := res.FinalURL
+	if base != nil && base.Scheme == "data" {
+		// Relative URLs in a data: document have nothing to resolve against.
+		base = nil
+	}
+	if opts.BaseURL != nil {
+		base = opts.BaseURL
+	}
+	if res.Metadata.Image != "" {
+		res.Metadata.Image = resolveURL(base, res.Metadata.Image)
+	}
+	res.Links = extractLinks(body, base)
+
+	var err error
+	if !opts.KeepComments {
+		if body, err = stripComments(body); err != nil {
+			return Result{}, classify(KindConversion, fmt.Errorf("failed to strip comments: %w", err))
+		}
+	}
+	if opts.Select != "" {
+		if body, err = selectHTML(body, opts.Select); err != nil {
+			return Result{}, err
+		}
+	} else if opts.PickContent != nil {
+		if body, err = pickContent(body, opts); err != nil {
+			return Result{}, err
+		}
+	}
+	if len(opts.Exclude) > 0 {
+		if body, err = excludeHTML(body, opts.Exclude); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.TrimNav {
+		selectors := opts.NavSelectors
+		if len(selectors) == 0 {
+			selectors = DefaultNavSelectors
+		}
+		if body, err = trimNav(body, selectors); err != nil {
+			return Result{}, classify(KindConversion, fmt.Errorf("failed to trim navigation: %w", err))
+		}
+	}
+
+	if opts.Readability {
+		if art, err := extractArticle(body, base); err != nil {
+			opts.warn("Readability extraction failed, converting full document", "error", err)
+		} else {
+			opts.debug("Extracted main content with readability")
+			body = art.html
+			if art.title != "" {
+				res.Title = art.title
+			}
+		}
+	}
+
+	if opts.EmojiImages {
+		if body, err = textEmojiImages(body); err != nil {
+			return Result{}, classify(KindConversion, fmt.Errorf("failed to rewrite emoji images: %w", err))
+		}
+	}
+
+	switch opts.Images {
+	case ImagesStrip:
+		body, err = stripImages(body)
+	case ImagesDownload:
+		assetsDir := filepath.Join(opts.OutputDir, assetsDirName)
+		opts.debug("Downloading images", "dir", assetsDir)
+		imagesStart := time.Now()
+		body, err = downloadImages(ctx, client, body, base, assetsDir, opts)
+		opts.debug("Image downloads done", "duration", since(imagesStart))
+	}
+	if err != nil {
+		return Result{}, classify(KindConversion, fmt.Errorf("failed to process images: %w", err))
+	}
+
+	if opts.AbsoluteLinks {
+		if base == nil {
+			opts.debug("No base URL, leaving relative links unchanged")
+		} else if body, err = absolutizeLinks(body, base); err != nil {
+			return Result{}, classify(KindConversion, fmt.Errorf("failed to rewrite links: %w", err))
+		}
+	}
+
+	if opts.EmojiShortcodes {
+		if body, err = shortcodeEmojiHTML(body); err != nil {
+			return Result{}, classify(KindConversion, fmt.Errorf("failed to rewrite emoji: %w", err))
+		}
+	}
+
+	if opts.Split != "" {
+		if res.Sections, err = convertSections(base, body, opts); err != nil {
+			return Result{}, err
+		}
+	}
+
+	opts.debug("Converting HTML to Markdown")
+	res.Markdown, err = convertToMarkdown(base, body, opts)
+	if err != nil {
+		return Result{}, classify(KindConversion, fmt.Errorf("failed to convert markup: %w", err))
+	}
+	res.Markdown = finishMarkdown(res.Markdown, opts)
+	opts.debug("Conversion done", "duration", since(start))
+	return res, checkLength(res.Markdown, opts)
+}
+
+// checkLength returns an ErrEmptyContent error when markdown is shorter
+// than opts.MinLength.
+func checkLength(markdown string, opts *Options) error {
+	if n := utf8.RuneCountInString(strings.TrimSpace(markdown)); n < opts.MinLength {
+		return classify(KindConversion, fmt.Errorf("%w: only %d characters of Markdown", ErrEmptyContent, n))
+	}
+	return nil
+}
+
+// finishMarkdown applies the options that post-process Markdown, whether
+// converted or served as-is: empty link removal, wrapping and the table of
+// contents.
+func finishMarkdown(markdown string, opts *Options) string {
+	if !opts.KeepEmptyLinks {
+		markdown = removeEmptyLinks(markdown)
+	}
+	markdown = wrapMarkdown(markdown, opts.Wrap)
+	if opts.TOC {
+		if toc := tableOfContents(markdown, opts.BulletChar); toc != "" {
+			markdown = toc + "\n" + markdown
+		}
+	}
+	return markdown
+}
+
+// useCanonical returns the URL a page should be known by: canonical when
+// opts.UseCanonical is set and it is on the same host as fetched, fetched
+// otherwise.
+func useCanonical(fetched, canonical *url.URL, opts *Options) *url.URL {
+	if !opts.UseCanonical || canonical.String() == fetched.String() {
+		return fetched
+	}
+	if (canonical.Scheme != "http" && canonical.Scheme != "https") || !strings.EqualFold(canonical.Host, fetched.Host) {
+		opts.debug("Ignoring canonical URL on another host", "url", canonical.String())
+		return fetched
+	}
+	opts.debug("Using canonical URL", "url", canonical.String())
+	return canonical
+}
+
+// supportedSchemes are the URL schemes Convert can fetch, as listed in
+// ErrUnsupportedScheme errors.
+var supportedSchemes = []string{"http", "https", "data"}
+
+// unsupportedScheme returns the error for a URL with a scheme Convert cannot
+// fetch, such as gemini: or ftp:.
+func unsupportedScheme(scheme string) error {
+	return fmt.Errorf("%w: %s (supported: %s)", ErrUnsupportedScheme, scheme, strings.Join(supportedSchemes, ", "))
+}
+
+// ParseURL parses a URL given on the command line, adding https:// when the
+// scheme is missing. data: URLs are accepted as they are; schemes other
+// than http, https and data fail with ErrUnsupportedScheme.
+func ParseURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "data":
+		return parsed, nil
+	case "":
+		parsed.Scheme = "https"
+	case "http", "https":
+	default:
+		// "localhost:8080/docs" parses with "localhost" as its scheme.
+		if parsed.Opaque != "" && parsed.Opaque[0] >= '0' && parsed.Opaque[0] <= '9' {
+			if guessed, err := url.Parse("https://" + raw); err == nil && guessed.Host != "" {
+				return guessed, nil
+			}
+		}
+		return nil, unsupportedScheme(parsed.Scheme)
+	}
+
+	if parsed.Host == "" {
+		guessed, guessErr := url.Parse(parsed.Scheme + "://" + raw)
+		if guessErr == nil && guessed.Host != "" {
+			return guessed, nil
+		}
+		return nil, errors.New("missing host")
+	}
+	return parsed, nil
+}
@@ -0,0 +1,259 @@
+package url2md
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseURLAddsScheme(t *testing.T) {
+	u, err := ParseURL("example.com/path")
+	if err != nil {
+		t.Fatalf("parseURL returned error: %v", err)
+	}
+
+	if u.Scheme != "https" {
+		t.Fatalf("scheme = %q, expected https", u.Scheme)
+	}
+
+	if u.Host != "example.com" {
+		t.Fatalf("host = %q, expected example.com", u.Host)
+	}
+}
+
+func TestParseURLSchemes(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://example.com/a":  "http://example.com/a",
+		"localhost:8080/docs":   "https://localhost:8080/docs",
+		"example.com:8443":      "https://example.com:8443",
+		"data:text/plain,hello": "data:text/plain,hello",
+	} {
+		u, err := ParseURL(raw)
+		if err != nil {
+			t.Fatalf("ParseURL(%q) returned error: %v", raw, err)
+		}
+		if u.String() != want {
+			t.Fatalf("ParseURL(%q) = %q, expected %q", raw, u, want)
+		}
+	}
+	for _, raw := range []string{"gemini://example.com/", "ftp://example.com/file", "mailto:me@example.com", "file:///tmp/page.html"} {
+		_, err := ParseURL(raw)
+		if !errors.Is(err, ErrUnsupportedScheme) {
+			t.Fatalf("ParseURL(%q) error = %v, expected ErrUnsupportedScheme", raw, err)
+		}
+		if !strings.Contains(err.Error(), "supported: http, https, data") {
+			t.Fatalf("error = %q, expected the supported schemes", err)
+		}
+	}
+}
+
+func TestConvertUnsupportedScheme(t *testing.T) {
+	_, err := Convert(context.Background(), "gemini://example.com/", Options{})
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindInvalidInput {
+		t.Fatalf("Convert error = %v, expected an invalid input error", err)
+	}
+	if want := "invalid url: unsupported scheme: gemini (supported: http, https, data)"; err.Error() != want {
+		t.Fatalf("error = %q, expected %q", err, want)
+	}
+}
+
+func TestConvertUseCanonical(t *testing.T) {
+	canonical := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<head><link rel="canonical" href="%s"></head><body><a href="setup">Setup</a></body>`, canonical)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		canonical string
+		use       bool
+		wantFinal string
+		wantLink  string
+	}{
+		{"/docs/guide/", true, srv.URL + "/docs/guide/", srv.URL + "/docs/guide/setup"},
+		{"/docs/guide/", false, srv.URL + "/page", srv.URL + "/setup"},
+		{"https://mirror.example.org/guide/", true, srv.URL + "/page", srv.URL + "/setup"},
+	}
+	for _, tt := range tests {
+		canonical = tt.canonical
+		res, err := Convert(context.Background(), srv.URL+"/page", Options{UseCanonical: tt.use, NoProxy: true, Logf: t.Logf})
+		if err != nil {
+			t.Fatalf("Convert returned error: %v", err)
+		}
+		if res.FinalURL.String() != tt.wantFinal {
+			t.Fatalf("canonical %q, use %v: FinalURL = %s, expected %s", tt.canonical, tt.use, res.FinalURL, tt.wantFinal)
+		}
+		if !strings.Contains(res.Markdown, "("+tt.wantLink+")") {
+			t.Fatalf("canonical %q, use %v: markdown %q, expected a link to %s", tt.canonical, tt.use, res.Markdown, tt.wantLink)
+		}
+		if res.CanonicalURL == nil {
+			t.Fatalf("canonical %q: CanonicalURL is nil", tt.canonical)
+		}
+	}
+}
+
+func TestConvertReturnsHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Disposition", `inline; filename="guide.html"`)
+		fmt.Fprint(w, "<body><p>Hello</p></body>")
+	}))
+	defer srv.Close()
+
+	res, err := Convert(context.Background(), srv.URL, Options{NoProxy: true, Logf: t.Logf})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if got := res.Header.Get("Content-Disposition"); got != `inline; filename="guide.html"` {
+		t.Fatalf("Content-Disposition = %q, expected the server's header", got)
+	}
+}
+
+func TestConvertHTML(t *testing.T) {
+	page := []byte(`<html><head><title>Saved</title></head><body><h1>Local</h1><a href="other.html">Other</a></body></html>`)
+
+	source, _ := url.Parse("file:///tmp/docs/saved.html")
+	res, err := ConvertHTML(context.Background(), page, source, Options{})
+	if err != nil {
+		t.Fatalf("ConvertHTML returned error: %v", err)
+	}
+	if res.Title != "Saved" || res.FinalURL != source {
+		t.Fatalf("ConvertHTML = title %q, FinalURL %v; expected Saved, %s", res.Title, res.FinalURL, source)
+	}
+	if !strings.Contains(res.Markdown, "# Local") || !strings.Contains(res.Markdown, "(file:///tmp/docs/other.html)") {
+		t.Fatalf("markdown = %q, expected links resolved against the file", res.Markdown)
+	}
+
+	res, err = ConvertHTML(context.Background(), page, nil, Options{})
+	if err != nil {
+		t.Fatalf("ConvertHTML without source returned error: %v", err)
+	}
+	if !strings.Contains(res.Markdown, "[Other](other.html)") {
+		t.Fatalf("markdown = %q, expected the relative link to be kept", res.Markdown)
+	}
+}
+
+func TestConvertBaseURLOverride(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<a href="setup">Setup</a> <img src="/logo.png" alt="logo">`)
+	}))
+	defer srv.Close()
+
+	base, _ := url.Parse("https://docs.example.com/v2/")
+	res, err := Convert(context.Background(), srv.URL+"/page", Options{BaseURL: base, NoProxy: true})
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+	if res.FinalURL.String() != srv.URL+"/page" {
+		t.Fatalf("FinalURL = %s, expected the fetched URL", res.FinalURL)
+	}
+	for _, want := range []string{"(https://docs.example.com/v2/setup)", "(https://docs.example.com/logo.png)"} {
+		if !strings.Contains(res.Markdown, want) {
+			t.Fatalf("markdown = %q, expected %s", res.Markdown, want)
+		}
+	}
+
+	res, err = ConvertHTML(context.Background(), []byte(`<a href="setup">Setup</a>`), nil, Options{BaseURL: base})
+	if err != nil || !strings.Contains(res.Markdown, "(https://docs.example.com/v2/setup)") {
+		t.Fatalf("ConvertHTML = %q, %v; expected links resolved against BaseURL", res.Markdown, err)
+	}
+}
+
+func TestConvertHTMLRejectsInvalidStyles(t *testing.T) {
+	for _, opts := range []Options{{HeadingStyle: "underline"}, {BulletChar: "•"}} {
+		_, err := ConvertHTML(context.Background(), []byte("<h1>Title</h1>"), nil, opts)
+		if Kind(err) != KindInvalidInput {
+			t.Fatalf("ConvertHTML(%+v) error = %v, expected an invalid input error", opts, err)
+		}
+	}
+}
+
+func TestConvertHTMLMinLength(t *testing.T) {
+	page := []byte(`<html><body><div id="app"><p>Loading…</p></div></body></html>`)
+
+	res, err := ConvertHTML(context.Background(), page, nil, Options{MinLength: 20})
+	if !errors.Is(err, ErrEmptyContent) || Kind(err) != KindConversion {
+		t.Fatalf("ConvertHTML error = %v, expected ErrEmptyContent", err)
+	}
+	if res.Markdown != "Loading…" {
+		t.Fatalf("Markdown = %q, expected the short result to be returned", res.Markdown)
+	}
+
+	if _, err := ConvertHTML(context.Background(), page, nil, Options{}); err != nil {
+		t.Fatalf("ConvertHTML without MinLength returned error: %v", err)
+	}
+}
+
+func TestConvertProxyOnEmpty(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>App</title></head><body><div id="root"></div><script src="/app.js"></script></body></html>`)
+	}))
+	defer origin.Close()
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		fmt.Fprint(w, "# App\n\nThe content rendered by the proxy's browser.")
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name         string
+		proxyOnEmpty bool
+		noProxy      bool
+		wantProxy    bool
+	}{
+		{"off", false, false, false},
+		{"on", true, false, true},
+		{"no proxy", true, true, false},
+	}
+	for _, tt := range tests {
+		proxied = 0
+		opts := Options{MinLength: 20, ProxyOnEmpty: tt.proxyOnEmpty, NoProxy: tt.noProxy, ProxyURL: proxy.URL + "/", NoWarmup: true, Logf: t.Logf}
+		res, err := Convert(context.Background(), origin.URL, opts)
+		if !tt.wantProxy {
+			if !errors.Is(err, ErrEmptyContent) || proxied != 0 {
+				t.Fatalf("%s: Convert error = %v after %d proxy requests, expected ErrEmptyContent without the proxy", tt.name, err, proxied)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Convert returned error: %v", tt.name, err)
+		}
+		if !res.ViaProxy || res.Markdown != "# App\n\nThe content rendered by the proxy's browser." || res.Title != "App" {
+			t.Fatalf("%s: Convert = %+v, expected the proxy's Markdown", tt.name, res)
+		}
+	}
+}
+
+func TestOptionsLog(t *testing.T) {
+	var lines []string
+	opts := &Options{Logf: func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}}
+	opts.info("Fetched page", "bytes", 42, "error", errors.New("boom"))
+	if len(lines) != 1 || lines[0] != "Fetched page bytes=42 error=boom" {
+		t.Fatalf("Logf lines = %q, expected one key=value line", lines)
+	}
+
+	var out strings.Builder
+	opts.Logger = slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	opts.debug("Hidden")
+	opts.warn("Skipping image", "src", "a.png")
+	if len(lines) != 1 {
+		t.Fatalf("Logf called with Logger set: %q", lines)
+	}
+	if got := out.String(); strings.Contains(got, "Hidden") || !strings.Contains(got, `level=WARN msg="Skipping image" src=a.png`) {
+		t.Fatalf("Logger output = %q, expected only the warning", got)
+	}
+}
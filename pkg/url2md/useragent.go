@@ -0,0 +1,88 @@
+package url2md
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// UserAgentRotation hands out User-Agent strings in turn, one per
+// conversion, for crawls that a site would block if every page carried the
+// same one. All the requests of a conversion, from the warm-up to the
+// images, send the same User-Agent so that cookies bound to it still match.
+// It is safe for concurrent use; share a single rotation between the
+// conversions of a run so that the list is cycled across all of them.
+type UserAgentRotation struct {
+	agents []string
+	next   atomic.Uint64
+}
+
+// NewUserAgentRotation returns a rotation through agents, starting with the
+// first one. It returns nil when agents is empty.
+func NewUserAgentRotation(agents []string) *UserAgentRotation {
+	if len(agents) == 0 {
+		return nil
+	}
+	return &UserAgentRotation{agents: agents}
+}
+
+// userAgent returns the next User-Agent of the rotation, or fallback when r
+// is nil.
+func (r *UserAgentRotation) userAgent(fallback string) string {
+	if r == nil {
+		return fallback
+	}
+	i := r.next.Add(1) - 1
+	return r.agents[i%uint64(len(r.agents))]
+}
+
+// pin returns a rotation holding only the next User-Agent of r, for the
+// requests of a single conversion, or nil when r is nil.
+func (r *UserAgentRotation) pin() *UserAgentRotation {
+	if r == nil {
+		return nil
+	}
+	return &UserAgentRotation{agents: []string{r.userAgent("")}}
+}
+
+var (
+	chromeVersionRe = regexp.MustCompile(`\bChrome/(\d+)`)
+	edgeVersionRe   = regexp.MustCompile(`\bEdg/(\d+)`)
+)
+
+// clientHints returns the Sec-CH-UA headers that the browser identified by
+// userAgent sends along with it. Only Chromium-based browsers send them:
+// for Firefox, Safari and anything unrecognized ok is false and the headers
+// are left out, since a Firefox UA with Chrome hints gives the client away.
+func clientHints(userAgent string) (brands, mobile, platform string, ok bool) {
+	match := chromeVersionRe.FindStringSubmatch(userAgent)
+	if match == nil {
+		return "", "", "", false
+	}
+	version, brand := match[1], "Google Chrome"
+	if edge := edgeVersionRe.FindStringSubmatch(userAgent); edge != nil {
+		version, brand = edge[1], "Microsoft Edge"
+	}
+	brands = fmt.Sprintf(`"Not/A)Brand";v="8", "Chromium";v="%s", "%s";v="%s"`, match[1], brand, version)
+
+	mobile = "?0"
+	if strings.Contains(userAgent, "Mobile") {
+		mobile = "?1"
+	}
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "CrOS"):
+		platform = "Chrome OS"
+	case strings.Contains(userAgent, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	default:
+		platform = "Unknown"
+	}
+	return brands, mobile, `"` + platform + `"`, true
+}
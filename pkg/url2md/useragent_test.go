@@ -0,0 +1,85 @@
+package url2md
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestUserAgentRotationCycles(t *testing.T) {
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	edge := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36 Edg/125.0.2535.67"
+	opts := &Options{UserAgent: DefaultUserAgent, UserAgents: NewUserAgentRotation([]string{firefox, edge})}
+	target := &url.URL{Scheme: "https", Host: "example.com", Path: "/"}
+
+	for i, want := range []string{firefox, edge, firefox, edge} {
+		req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+		applyBrowserHeaders(req, target, opts, true)
+		if got := req.Header.Get("User-Agent"); got != want {
+			t.Fatalf("request %d User-Agent = %q, expected %q", i, got, want)
+		}
+		brands, platform := req.Header.Get("Sec-CH-UA"), req.Header.Get("Sec-CH-UA-Platform")
+		if want == firefox && (brands != "" || platform != "") {
+			t.Fatalf("request %d sent client hints %q, %q with a Firefox User-Agent", i, brands, platform)
+		}
+		if want == edge {
+			if expected := `"Not/A)Brand";v="8", "Chromium";v="125", "Microsoft Edge";v="125"`; brands != expected {
+				t.Fatalf("request %d Sec-CH-UA = %q, expected %q", i, brands, expected)
+			}
+			if platform != `"Windows"` {
+				t.Fatalf("request %d Sec-CH-UA-Platform = %q, expected \"Windows\"", i, platform)
+			}
+		}
+	}
+}
+
+func TestApplyBrowserHeadersDefaultUserAgent(t *testing.T) {
+	opts := &Options{UserAgent: DefaultUserAgent}
+	target := &url.URL{Scheme: "https", Host: "example.com", Path: "/"}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+		applyBrowserHeaders(req, target, opts, false)
+		if got := req.Header.Get("User-Agent"); got != DefaultUserAgent {
+			t.Fatalf("User-Agent = %q, expected %q", got, DefaultUserAgent)
+		}
+		if got, want := req.Header.Get("Sec-CH-UA"), `"Not/A)Brand";v="8", "Chromium";v="126", "Google Chrome";v="126"`; got != want {
+			t.Fatalf("Sec-CH-UA = %q, expected %q", got, want)
+		}
+		if got := req.Header.Get("Sec-CH-UA-Platform"); got != `"macOS"` {
+			t.Fatalf("Sec-CH-UA-Platform = %q, expected \"macOS\"", got)
+		}
+	}
+	if NewUserAgentRotation(nil) != nil {
+		t.Fatalf("NewUserAgentRotation(nil) is not nil")
+	}
+}
+
+func TestConvertPinsUserAgentPerConversion(t *testing.T) {
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Path+" "+r.Header.Get("User-Agent"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<p>page</p>")
+	}))
+	defer srv.Close()
+
+	opts := Options{UserAgents: NewUserAgentRotation([]string{firefox, DefaultUserAgent}), NoProxy: true}
+	for _, path := range []string{"/a", "/b"} {
+		if _, err := Convert(context.Background(), srv.URL+path, opts); err != nil {
+			t.Fatalf("Convert(%s) returned error: %v", path, err)
+		}
+	}
+	want := []string{"/ " + firefox, "/a " + firefox, "/ " + DefaultUserAgent, "/b " + DefaultUserAgent}
+	if !slices.Equal(seen, want) {
+		t.Fatalf("requests = %q, expected %q", seen, want)
+	}
+}
@@ -0,0 +1,156 @@
+package url2md
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	fenceRe    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	linkRefRe  = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s`)
+	linePrefix = regexp.MustCompile(`^((?:\s*>)*\s*)((?:[-*+]|\d{1,9}[.)])\s+)?`)
+	blockStart = regexp.MustCompile(`^(#{1,6}|[-*+=_]+|\d{1,9}[.)])$`)
+	setextRe   = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+)
+
+// wrapMarkdown hard-wraps paragraph and list text at width columns. Fenced
+// and indented code, tables, headings, raw HTML and link reference
+// definitions are left untouched, and inline code spans are never split.
+func wrapMarkdown(markdown string, width int) string {
+	if width <= 0 {
+		return markdown
+	}
+	var out []string
+	fence := ""
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		if fence != "" {
+			out = append(out, line)
+			if strings.HasPrefix(strings.TrimLeft(line, " "), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			out = append(out, line)
+			continue
+		}
+		setext := i+1 < len(lines) && setextRe.MatchString(lines[i+1])
+		if utf8.RuneCountInString(line) <= width || setext || !wrappable(line) {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, wrapLine(line, width)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+func wrappable(line string) bool {
+	if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") || linkRefRe.MatchString(line) {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return !strings.HasPrefix(trimmed, "|") && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "<")
+}
+
+// wrapLine wraps a single line, repeating its blockquote markers and
+// indenting list item continuations under the item text.
+func wrapLine(line string, width int) []string {
+	m := linePrefix.FindStringSubmatch(line)
+	first := m[0]
+	rest := m[1] + strings.Repeat(" ", len(m[2]))
+
+	var lines []string
+	cur, curLen := first, utf8.RuneCountInString(first)
+	empty := true
+	for _, word := range splitWords(line[len(first):]) {
+		wordLen := utf8.RuneCountInString(word)
+		switch {
+		case empty:
+			cur += word
+			curLen += wordLen
+		case curLen+1+wordLen > width && !startsBlock(word):
+			lines = append(lines, cur)
+			cur, curLen = rest+word, utf8.RuneCountInString(rest)+wordLen
+		default:
+			cur += " " + word
+			curLen += 1 + wordLen
+		}
+		empty = false
+	}
+	if strings.HasSuffix(line, "  ") {
+		// Keep the trailing spaces of a hard line break.
+		cur += "  "
+	}
+	return append(lines, cur)
+}
+
+// splitWords splits text on spaces outside inline code spans and inline
+// links, so neither is ever broken across lines.
+func splitWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	inCode, inLink, inDest := 0, 0, 0
+	for i := 0; i < len(text); {
+		if text[i] == '`' {
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			if inCode == 0 {
+				inCode = n
+			} else if n == inCode {
+				inCode = 0
+			}
+			word.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+		if inCode == 0 {
+			switch text[i] {
+			case '[':
+				inLink++
+			case ']':
+				if inLink > 0 {
+					inLink--
+				}
+				if inLink == 0 && i+1 < len(text) && text[i+1] == '(' {
+					inDest++
+					word.WriteString("](")
+					i += 2
+					continue
+				}
+			case '(':
+				if inDest > 0 {
+					inDest++
+				}
+			case ')':
+				if inDest > 0 {
+					inDest--
+				}
+			}
+		}
+		if (text[i] == ' ' || text[i] == '\t') && inCode == 0 && inLink == 0 && inDest == 0 {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			i++
+			continue
+		}
+		word.WriteByte(text[i])
+		i++
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// startsBlock reports whether word would turn into a heading, list item,
+// blockquote or setext underline if it started a line.
+func startsBlock(word string) bool {
+	return strings.HasPrefix(word, ">") || strings.HasPrefix(word, "|") || blockStart.MatchString(word)
+}
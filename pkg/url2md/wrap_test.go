@@ -0,0 +1,72 @@
+package url2md
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWrapMarkdownParagraphWithLink(t *testing.T) {
+	in := "Read the [installation guide](https://example.com/docs/install) before you start, then continue."
+	got := wrapMarkdown(in, 40)
+	want := "Read the\n[installation guide](https://example.com/docs/install)\nbefore you start, then continue."
+	if got != want {
+		t.Fatalf("wrapMarkdown = %q, expected %q", got, want)
+	}
+
+	// A link ending exactly on the boundary stays on the first line.
+	in = "See [the docs](https://x.io) now."
+	if got, want := wrapMarkdown(in, 28), "See [the docs](https://x.io)\nnow."; got != want {
+		t.Fatalf("wrapMarkdown = %q, expected %q", got, want)
+	}
+}
+
+func TestWrapMarkdownKeepsInlineCodeTogether(t *testing.T) {
+	in := "Run the command `go test -run TestWrap ./...` to check it."
+	got := wrapMarkdown(in, 20)
+	if !strings.Contains(got, "`go test -run TestWrap ./...`") {
+		t.Fatalf("wrapMarkdown split an inline code span: %q", got)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if utf8.RuneCountInString(line) > 20 && !strings.Contains(line, "`") {
+			t.Fatalf("line %q is longer than 20 columns", line)
+		}
+	}
+}
+
+func TestWrapMarkdownLeavesBlocksIntact(t *testing.T) {
+	long := strings.Repeat("word ", 20)
+	in := strings.Join([]string{
+		"```go",
+		"// " + long,
+		"```",
+		"| a | " + long + " |",
+		"|---|---|",
+		"[1]: https://example.com/a/very/long/reference/link/target \"" + long + "\"",
+		"# " + long,
+		"    " + long,
+		"",
+		long,
+		"=====",
+	}, "\n")
+	if got := wrapMarkdown(in, 30); got != in {
+		t.Fatalf("wrapMarkdown changed untouchable blocks:\n%s", got)
+	}
+}
+
+func TestWrapMarkdownListsAndQuotes(t *testing.T) {
+	got := wrapMarkdown("- one two three four five six\n> alpha beta gamma delta epsilon", 16)
+	want := "- one two three\n  four five six\n> alpha beta\n> gamma delta\n> epsilon"
+	if got != want {
+		t.Fatalf("wrapMarkdown = %q, expected %q", got, want)
+	}
+}
+
+func TestWrapMarkdownNeverStartsLineWithBlockMarker(t *testing.T) {
+	got := wrapMarkdown("Prices went from 10 - 20 euro", 19)
+	for _, line := range strings.Split(got, "\n")[1:] {
+		if strings.HasPrefix(line, "- ") {
+			t.Fatalf("wrapMarkdown produced a list item: %q", got)
+		}
+	}
+}